@@ -0,0 +1,128 @@
+// Package job provides a wrapper for instrumenting background work, such as cron tasks and worker pools, that
+// runs outside of a gin request. Each job run is traced, logged and measured in the same way the middlewares
+// instrument an inbound request.
+package job
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+	"time"
+
+	"github.com/twistingmercury/observability/logger"
+	"github.com/twistingmercury/observability/metrics"
+	"github.com/twistingmercury/observability/tracer"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	// NameKey is the attribute key used for the name of the job on spans, logs and metrics.
+	NameKey = "job.name"
+)
+
+// Func is the unit of work executed by Run.
+type Func func(ctx context.Context) error
+
+var (
+	jobDuration  metric.Float64Histogram
+	jobSucceeded metric.Int64Counter
+	jobFailed    metric.Int64Counter
+
+	metricsInitialized bool
+)
+
+// InitializeMetrics creates the instruments used to record job metrics. metrics.Initialize() must be invoked
+// first. If InitializeMetrics is never invoked, jobs are still traced and logged, but no metrics are recorded.
+func InitializeMetrics() error {
+	metricsInitialized = false
+	jd, err := metrics.NewFloat64Histogram("job.duration_seconds", metrics.InstrumentOptions{
+		Unit:        metrics.SecondsUnit,
+		Description: "The duration of a job run in seconds.",
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create duration_seconds histogram: %w", err)
+	}
+
+	js, err := metrics.NewCounter("job.total_succeeded", "The total number of job runs that succeeded.")
+	if err != nil {
+		return fmt.Errorf("failed to create total_succeeded counter: %w", err)
+	}
+
+	jf, err := metrics.NewCounter("job.total_failed", "The total number of job runs that failed.")
+	if err != nil {
+		return fmt.Errorf("failed to create total_failed counter: %w", err)
+	}
+
+	jobDuration = jd
+	jobSucceeded = js
+	jobFailed = jf
+	metricsInitialized = true
+	return nil
+}
+
+// Run executes fn as the job named name. It starts a span that is ended with tracer.EndOK or tracer.EndError
+// depending on the error returned by fn, logs the start and completion of the job, and records the duration
+// and outcome of the job. A panic raised by fn is recovered and returned as an error. If the tracer has not been
+// initialized the span is a no-op, but the job is still run.
+func Run(ctx context.Context, name string, fn Func) (err error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	jCtx, span := tracer.New(ctx, name, trace.SpanKindInternal, attribute.String(NameKey, name))
+	logger.InfoWithSpanContext(jCtx, "job started", logger.Attribute{Key: NameKey, Value: name})
+
+	defer func(s time.Time) {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("job %s panicked: %v", name, r)
+			logger.ErrorWithSpanContext(jCtx, err, "job panicked",
+				logger.Attribute{Key: NameKey, Value: name},
+				logger.Attribute{Key: "stack", Value: string(debug.Stack())})
+		}
+
+		elapsed := time.Since(s)
+		record(jCtx, name, elapsed, err)
+
+		attribs := []logger.Attribute{
+			{Key: NameKey, Value: name},
+			{Key: "job.duration", Value: elapsed.String()},
+		}
+		if err != nil {
+			logger.ErrorWithSpanContext(jCtx, err, "job failed", attribs...)
+			tracer.EndError(span, err)
+			return
+		}
+		logger.InfoWithSpanContext(jCtx, "job completed", attribs...)
+		tracer.EndOK(span)
+	}(time.Now())
+
+	return fn(jCtx)
+}
+
+// Go executes the job named name in a new goroutine using Run. The returned channel receives the result of
+// the job and is then closed.
+func Go(ctx context.Context, name string, fn Func) <-chan error {
+	errc := make(chan error, 1)
+	go func() {
+		defer close(errc)
+		errc <- Run(ctx, name, fn)
+	}()
+	return errc
+}
+
+// record records the duration and outcome of a job run, if the job metrics have been initialized.
+func record(ctx context.Context, name string, elapsed time.Duration, err error) {
+	if !metricsInitialized {
+		return
+	}
+
+	attrs := metric.WithAttributes(attribute.String(NameKey, name))
+	jobDuration.Record(ctx, elapsed.Seconds(), attrs)
+	if err != nil {
+		jobFailed.Add(ctx, 1, attrs)
+		return
+	}
+	jobSucceeded.Add(ctx, 1, attrs)
+}
@@ -0,0 +1,95 @@
+package job_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/twistingmercury/observability/job"
+	"github.com/twistingmercury/observability/logger"
+	"github.com/twistingmercury/observability/logger/hooks"
+	"github.com/twistingmercury/observability/metrics"
+	"github.com/twistingmercury/observability/testTools"
+	"github.com/twistingmercury/observability/tracer"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// TestRun_TracerNotInitialized must run before any test that initializes the tracer.
+func TestRun_TracerNotInitialized(t *testing.T) {
+	ran := false
+	err := job.Run(context.Background(), "job", func(context.Context) error {
+		ran = true
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.True(t, ran, "the job must run even if it cannot be traced")
+}
+
+func TestRun(t *testing.T) {
+	logBuf := &bytes.Buffer{}
+	logger.Initialize(logBuf, logrus.DebugLevel, hooks.NewTraceHook())
+
+	ctx := context.Background()
+	conn, err := testTools.DialContext(ctx)
+	assert.NoError(t, err)
+
+	ts, err := tracer.Initialize(conn)
+	assert.NoError(t, err)
+	ms, err := metrics.Initialize("unit.test", conn)
+	assert.NoError(t, err)
+	defer func() {
+		_ = ms(ctx)
+		_ = ts(ctx)
+		testTools.Reset(ctx)
+	}()
+	assert.NoError(t, job.InitializeMetrics())
+
+	t.Run("ok", func(t *testing.T) {
+		logBuf.Reset()
+		var spanCtx trace.SpanContext
+		err := job.Run(ctx, "ok-job", func(jCtx context.Context) error {
+			spanCtx = trace.SpanContextFromContext(jCtx)
+			return nil
+		})
+		assert.NoError(t, err)
+		assert.True(t, spanCtx.IsValid())
+		assert.Contains(t, logBuf.String(), "job started")
+		assert.Contains(t, logBuf.String(), "job completed")
+		assert.Contains(t, logBuf.String(), spanCtx.TraceID().String())
+	})
+
+	t.Run("error", func(t *testing.T) {
+		logBuf.Reset()
+		jobErr := errors.New("job error")
+		err := job.Run(ctx, "error-job", func(context.Context) error {
+			return jobErr
+		})
+		assert.ErrorIs(t, err, jobErr)
+		assert.Contains(t, logBuf.String(), "job failed")
+	})
+
+	t.Run("panic", func(t *testing.T) {
+		logBuf.Reset()
+		err := job.Run(ctx, "panic-job", func(context.Context) error {
+			panic("boom")
+		})
+		assert.EqualError(t, err, "job panic-job panicked: boom")
+		assert.Contains(t, logBuf.String(), "job panicked")
+		assert.Contains(t, logBuf.String(), "job failed")
+	})
+
+	t.Run("nil_context", func(t *testing.T) {
+		assert.NoError(t, job.Run(nil, "nil-ctx-job", func(context.Context) error { return nil }))
+	})
+
+	t.Run("go", func(t *testing.T) {
+		jobErr := errors.New("job error")
+		errc := job.Go(ctx, "go-job", func(context.Context) error { return jobErr })
+		assert.ErrorIs(t, <-errc, jobErr)
+		_, ok := <-errc
+		assert.False(t, ok)
+	})
+}
@@ -0,0 +1,94 @@
+// Package localExport provides the exporters used during local development, when no collector is running: spans
+// and metric snapshots are either pretty-printed to stdout, or written as JSON lines to files that are rotated once
+// they reach their maximum size. The files can be followed with `tail -f` while iterating, and read by tests to
+// assert on the exported telemetry.
+package localExport
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"go.opentelemetry.io/otel/exporters/stdout/stdoutmetric"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	sdkMetric "go.opentelemetry.io/otel/sdk/metric"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// NewConsoleTraceExporter returns an exporter that pretty-prints spans to w. If w is nil, os.Stdout is used.
+func NewConsoleTraceExporter(w io.Writer) (sdktrace.SpanExporter, error) {
+	if w == nil {
+		w = os.Stdout
+	}
+	exp, err := stdouttrace.New(stdouttrace.WithWriter(w), stdouttrace.WithPrettyPrint())
+	if err != nil {
+		return nil, fmt.Errorf("failed to create the console trace exporter: %w", err)
+	}
+	return exp, nil
+}
+
+// NewFileTraceExporter returns an exporter that writes each span as a JSON line to the file in opts. The file is
+// closed when the exporter is shut down.
+func NewFileTraceExporter(opts FileOptions) (sdktrace.SpanExporter, error) {
+	f, err := NewRotatingFile(opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create the file trace exporter: %w", err)
+	}
+	exp, err := stdouttrace.New(stdouttrace.WithWriter(f))
+	if err != nil {
+		_ = f.Close()
+		return nil, fmt.Errorf("failed to create the file trace exporter: %w", err)
+	}
+	return &fileTraceExporter{SpanExporter: exp, file: f}, nil
+}
+
+// NewConsoleMetricExporter returns an exporter that pretty-prints metric snapshots to w. If w is nil, os.Stdout
+// is used.
+func NewConsoleMetricExporter(w io.Writer) (sdkMetric.Exporter, error) {
+	if w == nil {
+		w = os.Stdout
+	}
+	exp, err := stdoutmetric.New(stdoutmetric.WithWriter(w), stdoutmetric.WithPrettyPrint())
+	if err != nil {
+		return nil, fmt.Errorf("failed to create the console metrics exporter: %w", err)
+	}
+	return exp, nil
+}
+
+// NewFileMetricExporter returns an exporter that writes each metric snapshot as a JSON line to the file in opts.
+// The file is closed when the exporter is shut down.
+func NewFileMetricExporter(opts FileOptions) (sdkMetric.Exporter, error) {
+	f, err := NewRotatingFile(opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create the file metrics exporter: %w", err)
+	}
+	exp, err := stdoutmetric.New(stdoutmetric.WithEncoder(json.NewEncoder(f)))
+	if err != nil {
+		_ = f.Close()
+		return nil, fmt.Errorf("failed to create the file metrics exporter: %w", err)
+	}
+	return &fileMetricExporter{Exporter: exp, file: f}, nil
+}
+
+// fileTraceExporter closes its file once the trace exporter has been shut down.
+type fileTraceExporter struct {
+	sdktrace.SpanExporter
+	file io.Closer
+}
+
+func (e *fileTraceExporter) Shutdown(ctx context.Context) error {
+	return errors.Join(e.SpanExporter.Shutdown(ctx), e.file.Close())
+}
+
+// fileMetricExporter closes its file once the metrics exporter has been shut down.
+type fileMetricExporter struct {
+	sdkMetric.Exporter
+	file io.Closer
+}
+
+func (e *fileMetricExporter) Shutdown(ctx context.Context) error {
+	return errors.Join(e.Exporter.Shutdown(ctx), e.file.Close())
+}
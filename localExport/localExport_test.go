@@ -0,0 +1,122 @@
+package localExport_test
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/twistingmercury/observability/localExport"
+	sdkMetric "go.opentelemetry.io/otel/sdk/metric"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+func readLines(t *testing.T, path string) []string {
+	f, err := os.Open(path)
+	require.NoError(t, err)
+	defer f.Close()
+
+	var lines []string
+	s := bufio.NewScanner(f)
+	s.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for s.Scan() {
+		lines = append(lines, s.Text())
+	}
+	require.NoError(t, s.Err())
+	return lines
+}
+
+func TestRotatingFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "nested", "out.jsonl")
+	f, err := localExport.NewRotatingFile(localExport.FileOptions{Path: path, MaxSizeMB: 1, MaxBackups: 2})
+	require.NoError(t, err)
+
+	// each line is half of the maximum size, so the file is rotated every two lines.
+	line := append(bytes.Repeat([]byte("x"), 512*1024-1), '\n')
+	for i := 0; i < 7; i++ {
+		_, err := f.Write(line)
+		require.NoError(t, err)
+	}
+	require.NoError(t, f.Close())
+
+	assert.Len(t, readLines(t, path), 1)
+	assert.Len(t, readLines(t, path+".1"), 2)
+	assert.Len(t, readLines(t, path+".2"), 2)
+	assert.NoFileExists(t, path+".3", "only MaxBackups rotated files are kept")
+
+	_, err = f.Write(line)
+	assert.ErrorIs(t, err, os.ErrClosed)
+
+	_, err = localExport.NewRotatingFile(localExport.FileOptions{})
+	assert.Error(t, err)
+}
+
+func TestFileTraceExporter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "traces.jsonl")
+	exp, err := localExport.NewFileTraceExporter(localExport.FileOptions{Path: path})
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exp))
+	for _, name := range []string{"first", "second"} {
+		_, span := tp.Tracer("unit-test").Start(ctx, name)
+		span.End()
+	}
+	require.NoError(t, tp.Shutdown(ctx))
+
+	lines := readLines(t, path)
+	require.Len(t, lines, 2, "each span is written on its own line")
+	for i, name := range []string{"first", "second"} {
+		var span struct{ Name string }
+		require.NoError(t, json.Unmarshal([]byte(lines[i]), &span))
+		assert.Equal(t, name, span.Name)
+	}
+}
+
+func TestFileMetricExporter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "metrics.jsonl")
+	exp, err := localExport.NewFileMetricExporter(localExport.FileOptions{Path: path})
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	mp := sdkMetric.NewMeterProvider(sdkMetric.WithReader(sdkMetric.NewPeriodicReader(exp)))
+	c, err := mp.Meter("unit-test").Int64Counter("local.counter")
+	require.NoError(t, err)
+	c.Add(ctx, 5)
+	require.NoError(t, mp.Shutdown(ctx))
+
+	lines := readLines(t, path)
+	require.Len(t, lines, 1, "each snapshot is written on its own line")
+	assert.Contains(t, lines[0], `"Name":"local.counter"`)
+}
+
+func TestConsoleExporters(t *testing.T) {
+	ctx := context.Background()
+	buf := &bytes.Buffer{}
+
+	texp, err := localExport.NewConsoleTraceExporter(buf)
+	require.NoError(t, err)
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(texp))
+	_, span := tp.Tracer("unit-test").Start(ctx, "console-span")
+	span.End()
+	require.NoError(t, tp.Shutdown(ctx))
+
+	mexp, err := localExport.NewConsoleMetricExporter(buf)
+	require.NoError(t, err)
+	mp := sdkMetric.NewMeterProvider(sdkMetric.WithReader(sdkMetric.NewPeriodicReader(mexp)))
+	c, err := mp.Meter("unit-test").Int64Counter("console.counter")
+	require.NoError(t, err)
+	c.Add(ctx, 1)
+	require.NoError(t, mp.Shutdown(ctx))
+
+	out := buf.String()
+	assert.Contains(t, out, `"Name": "console-span"`, "spans are pretty-printed")
+	assert.Contains(t, out, `"Name": "console.counter"`, "metrics are pretty-printed")
+	assert.True(t, strings.Contains(out, "\n\t"))
+}
@@ -0,0 +1,130 @@
+package localExport
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+const (
+	defaultMaxSizeMB  = 10
+	defaultMaxBackups = 3
+)
+
+// FileOptions are the options of the JSONL file exporters.
+type FileOptions struct {
+	// Path is the file the telemetry is written to. Its directory is created if it does not exist.
+	Path string
+	// MaxSizeMB is the size, in megabytes, the file may reach before it is rotated. Defaults to 10.
+	MaxSizeMB int
+	// MaxBackups is the number of rotated files that are kept, e.g. `traces.jsonl.1` to `traces.jsonl.3`.
+	// Defaults to 3.
+	MaxBackups int
+}
+
+// RotatingFile is an io.WriteCloser that appends to a file, and rotates the file once it reaches its maximum size.
+// The file is rotated between writes so that a line is never split across files.
+type RotatingFile struct {
+	mu         sync.Mutex
+	path       string
+	maxSize    int64
+	maxBackups int
+	file       *os.File
+	size       int64
+}
+
+// NewRotatingFile opens, or creates, the file in opts for appending.
+func NewRotatingFile(opts FileOptions) (*RotatingFile, error) {
+	if len(opts.Path) == 0 {
+		return nil, errors.New("the file path is empty")
+	}
+	if opts.MaxSizeMB <= 0 {
+		opts.MaxSizeMB = defaultMaxSizeMB
+	}
+	if opts.MaxBackups <= 0 {
+		opts.MaxBackups = defaultMaxBackups
+	}
+
+	f := &RotatingFile{
+		path:       opts.Path,
+		maxSize:    int64(opts.MaxSizeMB) * 1024 * 1024,
+		maxBackups: opts.MaxBackups,
+	}
+	if err := os.MkdirAll(filepath.Dir(opts.Path), 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create the directory of %s: %w", opts.Path, err)
+	}
+	if err := f.open(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// Write appends p to the file. If the file would exceed its maximum size, it is rotated first.
+func (f *RotatingFile) Write(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.file == nil {
+		return 0, os.ErrClosed
+	}
+	if f.size > 0 && f.size+int64(len(p)) > f.maxSize {
+		if err := f.rotate(); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := f.file.Write(p)
+	f.size += int64(n)
+	return n, err
+}
+
+// Close closes the file.
+func (f *RotatingFile) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.file == nil {
+		return nil
+	}
+	err := f.file.Close()
+	f.file = nil
+	return err
+}
+
+func (f *RotatingFile) open() error {
+	file, err := os.OpenFile(f.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", f.path, err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+		return fmt.Errorf("failed to stat %s: %w", f.path, err)
+	}
+	f.file = file
+	f.size = info.Size()
+	return nil
+}
+
+// rotate renames the file to `<path>.1`, shifting the existing backups and removing the oldest, and opens a new file.
+func (f *RotatingFile) rotate() error {
+	if err := f.file.Close(); err != nil {
+		return fmt.Errorf("failed to close %s: %w", f.path, err)
+	}
+	f.file = nil
+
+	_ = os.Remove(backupName(f.path, f.maxBackups))
+	for i := f.maxBackups - 1; i > 0; i-- {
+		_ = os.Rename(backupName(f.path, i), backupName(f.path, i+1))
+	}
+	if err := os.Rename(f.path, backupName(f.path, 1)); err != nil {
+		return fmt.Errorf("failed to rotate %s: %w", f.path, err)
+	}
+	return f.open()
+}
+
+func backupName(path string, i int) string {
+	return fmt.Sprintf("%s.%d", path, i)
+}
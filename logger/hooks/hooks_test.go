@@ -10,6 +10,7 @@ import (
 	"github.com/twistingmercury/observability/logger"
 	"github.com/twistingmercury/observability/logger/hooks"
 	"github.com/twistingmercury/observability/observeCfg"
+	"github.com/twistingmercury/observability/resources"
 	"github.com/twistingmercury/observability/testTools"
 	"github.com/twistingmercury/observability/tracer"
 	"go.opentelemetry.io/otel/trace"
@@ -23,8 +24,8 @@ var (
 
 func setup(t *testing.T) {
 	os.Setenv(observeCfg.LogLevelEnvVar, "debug")
-	os.Setenv(observeCfg.TraceEndpointEnvVar, "traceEndpoint")
-	os.Setenv(observeCfg.MetricsEndpointEnvVar, "metricsEndpoint")
+	os.Setenv(observeCfg.TraceEndpointEnvVar, "localhost:4317")
+	os.Setenv(observeCfg.MetricsEndpointEnvVar, "localhost:4317")
 	os.Setenv(observeCfg.EnvironEnvVar, "localhost")
 	err := observeCfg.Initialize("unit-tests", "2023-01-01T00:00:00.000", "0.0.0", "abcd0123")
 	assert.NoError(t, err)
@@ -79,6 +80,7 @@ func TestStdFieldsHook_FireHook(t *testing.T) {
 			assert.NotEmpty(t, logEntry[hooks.HostDataKey], "host data should not be empty")
 			assert.NotEmpty(t, logEntry[hooks.BuildDateDataKey], "build date data should not be empty")
 			assert.NotEmpty(t, logEntry[hooks.CommitHashDataKey], "commit hash data should not be empty")
+			assert.Equal(t, false, logEntry[hooks.VCSDirtyDataKey], "vcs dirty data should be set")
 		})
 	}
 }
@@ -208,3 +210,44 @@ func TestTraceHook_Fire_Nil_Empty_Context(t *testing.T) {
 		})
 	}
 }
+
+func TestStdFieldsHook_Platform(t *testing.T) {
+	setup(t)
+	defer tearDown()
+	os.Setenv(resources.PodNameEnvVar, "unit-test-pod")
+	os.Setenv(resources.NamespaceEnvVar, "unit-test-ns")
+	defer func() {
+		os.Unsetenv(resources.PodNameEnvVar)
+		os.Unsetenv(resources.NamespaceEnvVar)
+	}()
+
+	hook := hooks.NewStdFieldsHook()
+	logger.Initialize(&buf, logrus.InfoLevel, hook)
+	logger.Info("test message")
+
+	var logEntry map[string]interface{}
+	assert.NoError(t, json.Unmarshal(buf.Bytes(), &logEntry))
+	assert.Equal(t, resources.Detected().PodName, logEntry[hooks.PodNameDataKey])
+	assert.Equal(t, resources.Detected().Namespace, logEntry[hooks.NamespaceDataKey])
+	assert.Nil(t, logEntry[hooks.NodeNameDataKey], "undetected fields should be omitted")
+}
+
+func TestRedactionHook_Fire(t *testing.T) {
+	os.Setenv(observeCfg.LogRedactedKeysEnvVar, "Authorization, password")
+	defer os.Unsetenv(observeCfg.LogRedactedKeysEnvVar)
+	setup(t)
+	defer tearDown()
+	logger.Initialize(&buf, logrus.DebugLevel, hooks.NewRedactionHook())
+
+	logger.Info("test message",
+		logger.Attribute{Key: "authorization", Value: []string{"Bearer secret"}},
+		logger.Attribute{Key: "Password", Value: "secret"},
+		logger.Attribute{Key: "user", Value: "alice"})
+
+	var logEntry map[string]interface{}
+	assert.NoError(t, json.Unmarshal(buf.Bytes(), &logEntry))
+	assert.Equal(t, hooks.RedactedValue, logEntry["authorization"])
+	assert.Equal(t, hooks.RedactedValue, logEntry["Password"], "the keys are compared ignoring the case")
+	assert.Equal(t, "alice", logEntry["user"])
+	assert.NotContains(t, buf.String(), "secret")
+}
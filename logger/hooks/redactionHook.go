@@ -0,0 +1,31 @@
+package hooks
+
+import (
+	"github.com/sirupsen/logrus"
+	"github.com/twistingmercury/observability/observeCfg"
+)
+
+// RedactedValue replaces the values of the redacted fields.
+const RedactedValue = "[REDACTED]"
+
+// NewRedactionHook returns a hook that replaces the values of the fields whose keys are observeCfg.LogRedactedKeys,
+// e.g. the `authorization` header logged by logger.LoggingMiddleware. The keys are read when each entry is logged, so
+// they follow the changes of the config file.
+func NewRedactionHook() logrus.Hook {
+	return &redactionHook{}
+}
+
+type redactionHook struct{}
+
+func (h *redactionHook) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+func (h *redactionHook) Fire(entry *logrus.Entry) (err error) {
+	for k := range entry.Data {
+		if observeCfg.LogKeyRedacted(k) {
+			entry.Data[k] = RedactedValue
+		}
+	}
+	return
+}
@@ -3,21 +3,29 @@ package hooks
 import (
 	"github.com/sirupsen/logrus"
 	"github.com/twistingmercury/observability/observeCfg"
+	"github.com/twistingmercury/observability/resources"
 )
 
 const (
 	ServiceDataKey     = "service"
 	VersionDataKey     = "version"
 	CommitHashDataKey  = "commit_hash"
+	VCSDirtyDataKey    = "vcs_dirty"
 	EnvironmentDataKey = "env"
 	BuildDateDataKey   = "build_date"
 	HostDataKey        = "host"
+	ContainerIDDataKey = "container_id"
+	PodNameDataKey     = "pod_name"
+	NamespaceDataKey   = "namespace"
+	NodeNameDataKey    = "node_name"
 )
 
-type stdFieldsHook struct{}
+type stdFieldsHook struct {
+	platform resources.Platform
+}
 
 func NewStdFieldsHook() logrus.Hook {
-	return &stdFieldsHook{}
+	return &stdFieldsHook{platform: resources.Detected()}
 }
 
 func (h *stdFieldsHook) Levels() []logrus.Level {
@@ -28,8 +36,19 @@ func (h *stdFieldsHook) Fire(entry *logrus.Entry) (err error) {
 	entry.Data[ServiceDataKey] = observeCfg.ServiceName()
 	entry.Data[VersionDataKey] = observeCfg.Version()
 	entry.Data[CommitHashDataKey] = observeCfg.CommitHash()
+	entry.Data[VCSDirtyDataKey] = observeCfg.VCSDirty()
 	entry.Data[EnvironmentDataKey] = observeCfg.Environment()
 	entry.Data[BuildDateDataKey] = observeCfg.BuildDate()
 	entry.Data[HostDataKey] = observeCfg.HostName()
+	setIfNotEmpty(entry, ContainerIDDataKey, h.platform.ContainerID)
+	setIfNotEmpty(entry, PodNameDataKey, h.platform.PodName)
+	setIfNotEmpty(entry, NamespaceDataKey, h.platform.Namespace)
+	setIfNotEmpty(entry, NodeNameDataKey, h.platform.NodeName)
 	return
 }
+
+func setIfNotEmpty(entry *logrus.Entry, key, value string) {
+	if len(value) > 0 {
+		entry.Data[key] = value
+	}
+}
@@ -51,8 +51,10 @@ func (rw *httpWriter) Write(p []byte) (n int, err error) {
 
 	req, _ := http.NewRequest("POST", rw.endpoint, rw.buffer)
 	req.Header.Set("Content-Type", "application/json")
-	resp, _ := rw.client.Do(req)
-
+	resp, err := rw.client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("failed to write to the RESTful endpoint %s: %w", rw.endpoint, err)
+	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
@@ -36,6 +36,11 @@ func Initialize(out io.Writer, level logrus.Level, hooks ...logrus.Hook) {
 	isInitialized = true
 }
 
+// SetLevel changes the log level, e.g. when it is reloaded from the config file.
+func SetLevel(level logrus.Level) {
+	logrus.SetLevel(level)
+}
+
 // Debug logs a message at the debug level and any additional fields passed in as attributes.
 func Debug(msg string, attribs ...Attribute) {
 	logrus.WithFields(
@@ -76,8 +76,8 @@ var (
 
 func setup() {
 	os.Setenv(observeCfg.LogLevelEnvVar, "debug")
-	os.Setenv(observeCfg.TraceEndpointEnvVar, "traceEndpoint")
-	os.Setenv(observeCfg.MetricsEndpointEnvVar, "metricsEndpoint")
+	os.Setenv(observeCfg.TraceEndpointEnvVar, "localhost:4317")
+	os.Setenv(observeCfg.MetricsEndpointEnvVar, "localhost:4317")
 	os.Setenv(observeCfg.EnvironEnvVar, "localhost")
 }
 
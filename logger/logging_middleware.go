@@ -3,18 +3,49 @@ package logger
 import (
 	"fmt"
 	"github.com/sirupsen/logrus"
+	"slices"
 	"strings"
+	"sync/atomic"
 
 	"github.com/gin-gonic/gin"
 	"github.com/mileusna/useragent"
 )
 
-// LoggingMiddleware logs the incoming request and starts the trace.
+// excludedRoutes are the routes LoggingMiddleware does not log.
+var excludedRoutes atomic.Pointer[[]string]
+
+// SetExcludedRoutes sets the routes LoggingMiddleware does not log, e.g. `/health`, replacing those set before. The
+// routes are route templates, or the paths of the requests that match no route. observability.Start sets them to
+// observeCfg.HttpExcludedRoutes, and changes them when the config file is reloaded.
+func SetExcludedRoutes(routes ...string) {
+	r := append([]string(nil), routes...)
+	excludedRoutes.Store(&r)
+}
+
+// excluded returns true if the route of the request is one of the excluded routes.
+func excluded(ctx *gin.Context) bool {
+	routes := excludedRoutes.Load()
+	if routes == nil {
+		return false
+	}
+	route := ctx.FullPath()
+	if len(route) == 0 {
+		route = ctx.Request.URL.Path
+	}
+	return slices.Contains(*routes, route)
+}
+
+// LoggingMiddleware logs the incoming request and starts the trace, except the requests of the routes set by
+// SetExcludedRoutes. If the logger has not been initialized, the logrus defaults are used.
 func LoggingMiddleware() gin.HandlerFunc {
 	if !IsInitialized() {
-		logrus.Fatal("logger.Initialize() must be invoked before using the logging middleware")
+		logrus.Warn("logger.Initialize() has not been invoked; the logrus defaults will be used")
 	}
 	return func(ctx *gin.Context) {
+		if excluded(ctx) {
+			ctx.Next()
+			return
+		}
 		attribs := []Attribute{
 			{Key: "http.method", Value: ctx.Request.Method},
 			{Key: "http.path", Value: ctx.Request.URL.Path},
@@ -228,6 +228,25 @@ func TestParseHeaders(t *testing.T) {
 	}
 }
 
+func TestLogRequestMiddleware_ExcludedRoutes(t *testing.T) {
+	buf := &bytes.Buffer{}
+	logger.Initialize(buf, logrus.DebugLevel)
+	logger.SetExcludedRoutes("/health")
+	defer logger.SetExcludedRoutes()
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(logger.LoggingMiddleware())
+	router.GET("/health", func(c *gin.Context) { c.Status(http.StatusOK) })
+	router.GET("/test", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/health", nil))
+	assert.Empty(t, buf.String(), "an excluded route is not logged")
+
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/test", nil))
+	assert.Contains(t, buf.String(), "inbound-request")
+}
+
 func TestLogRequestMiddleware(t *testing.T) {
 	gin.SetMode(gin.TestMode)
 	router := gin.New()
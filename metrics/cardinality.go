@@ -0,0 +1,239 @@
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+
+	"github.com/twistingmercury/observability/logger"
+	"github.com/twistingmercury/observability/observeCfg"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/metric/noop"
+	sdkMetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+// OverflowAttribute is the attribute of the series the measurements exceeding the cardinality limit of an
+// instrument are folded into.
+var OverflowAttribute = attribute.Bool("otel.metric.overflow", true)
+
+var overflowSet = attribute.NewSet(OverflowAttribute)
+
+var (
+	// cardinalityLimit is the limit of the instruments created without their own limit. Zero or less means unlimited.
+	cardinalityLimit = observeCfg.DefaultCardinalityLimit
+	// overflowMeasurements counts the measurements folded into the overflow series, per instrument.
+	overflowMeasurements metric.Int64Counter = noop.Int64Counter{}
+
+	// cycle counts the exports of the periodic reader, one per collection cycle.
+	cycle atomic.Uint64
+	// deltaKinds are the kinds of the instruments exported with delta temporality. The SDK forgets their attribute
+	// sets after each collection cycle, so their limiters do too. It is empty if the Prometheus reader, which is
+	// cumulative, reads the instruments as well.
+	deltaKinds = map[sdkMetric.InstrumentKind]bool{}
+)
+
+// WithCardinalityLimit sets the number of unique attribute sets each synchronous instrument records, by default
+// observeCfg.DefaultCardinalityLimit. The measurements with any other attribute set are folded into a single series
+// with the OverflowAttribute. A limit of zero or less disables the limit. The instruments exported with delta
+// temporality record up to limit attribute sets per collection cycle.
+func WithCardinalityLimit(limit int) Option {
+	return func(c *config) {
+		c.cardinalityLimit = &limit
+	}
+}
+
+// initializeCardinality sets the default limit and the instrument kinds exported by exp with delta temporality, and
+// creates the self-metric counting the measurements folded into the overflow series. exp is nil if the metrics are
+// only pulled by Prometheus.
+func initializeCardinality(cfg *config, exp sdkMetric.Exporter) error {
+	cardinalityLimit = observeCfg.DefaultCardinalityLimit
+	if cfg.cardinalityLimit != nil {
+		cardinalityLimit = *cfg.cardinalityLimit
+	}
+	deltaKinds = map[sdkMetric.InstrumentKind]bool{}
+	if exp != nil && !cfg.prometheus {
+		for _, kind := range instrumentKinds {
+			deltaKinds[kind] = exp.Temporality(kind) == metricdata.DeltaTemporality
+		}
+	}
+
+	om, err := NewInt64Counter("metrics.overflow_measurements", InstrumentOptions{
+		Unit: "{measurement}",
+		Description: "The number of measurements folded into the overflow series because the cardinality limit of " +
+			"their instrument was reached.",
+		CardinalityLimit: -1,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create overflow_measurements counter: %w", err)
+	}
+	overflowMeasurements = om
+	return nil
+}
+
+func resetCardinality() {
+	cardinalityLimit = observeCfg.DefaultCardinalityLimit
+	overflowMeasurements = noop.Int64Counter{}
+	deltaKinds = map[sdkMetric.InstrumentKind]bool{}
+}
+
+// cycleExporter counts the collection cycles of the periodic reader, which exports once per cycle.
+type cycleExporter struct {
+	sdkMetric.Exporter
+}
+
+func (e cycleExporter) Export(ctx context.Context, rm *metricdata.ResourceMetrics) error {
+	cycle.Add(1)
+	return e.Exporter.Export(ctx, rm)
+}
+
+// limiter caps the number of unique attribute sets recorded by an instrument.
+type limiter struct {
+	name  string
+	limit int
+	// delta is true if the instrument is exported with delta temporality; seen is then cleared each cycle.
+	delta  bool
+	mu     sync.RWMutex
+	seen   map[attribute.Distinct]struct{}
+	cycle  uint64
+	warned bool
+}
+
+// newLimiter returns the limiter of the instrument fname of the given kind, or nil if the instrument is unlimited.
+func newLimiter(fname string, kind sdkMetric.InstrumentKind, limit int) *limiter {
+	if limit == 0 {
+		limit = cardinalityLimit
+	}
+	if limit <= 0 {
+		return nil
+	}
+	return &limiter{
+		name:  fname,
+		limit: limit,
+		delta: deltaKinds[kind],
+		seen:  make(map[attribute.Distinct]struct{}),
+		cycle: cycle.Load(),
+	}
+}
+
+// attributes returns set if it has already been recorded or the limit has not been reached, and the overflow set
+// otherwise.
+func (l *limiter) attributes(ctx context.Context, set attribute.Set) attribute.Set {
+	key := set.Equivalent()
+	current := cycle.Load()
+	l.mu.RLock()
+	_, ok := l.seen[key]
+	stale := l.delta && l.cycle != current
+	l.mu.RUnlock()
+	if ok && !stale {
+		return set
+	}
+
+	l.mu.Lock()
+	if l.delta && l.cycle != current {
+		l.cycle = current
+		clear(l.seen)
+	}
+	if _, ok = l.seen[key]; !ok && len(l.seen) < l.limit {
+		l.seen[key] = struct{}{}
+		ok = true
+	}
+	warn := !ok && !l.warned
+	if warn {
+		l.warned = true
+	}
+	l.mu.Unlock()
+	if ok {
+		return set
+	}
+
+	if warn {
+		logger.Warn("the cardinality limit of the instrument has been reached; new attribute sets are folded into the overflow series",
+			logger.Attribute{Key: "name", Value: l.name},
+			logger.Attribute{Key: "limit", Value: l.limit})
+	}
+	overflowMeasurements.Add(ctx, 1, metric.WithAttributes(attribute.String("metric.name", l.name)))
+	return overflowSet
+}
+
+func (l *limiter) addOption(ctx context.Context, opts []metric.AddOption) metric.MeasurementOption {
+	return metric.WithAttributeSet(l.attributes(ctx, metric.NewAddConfig(opts).Attributes()))
+}
+
+func (l *limiter) recordOption(ctx context.Context, opts []metric.RecordOption) metric.MeasurementOption {
+	return metric.WithAttributeSet(l.attributes(ctx, metric.NewRecordConfig(opts).Attributes()))
+}
+
+type limitedInt64Counter struct {
+	metric.Int64Counter
+	l *limiter
+}
+
+func (c limitedInt64Counter) Add(ctx context.Context, incr int64, opts ...metric.AddOption) {
+	c.Int64Counter.Add(ctx, incr, c.l.addOption(ctx, opts))
+}
+
+type limitedFloat64Counter struct {
+	metric.Float64Counter
+	l *limiter
+}
+
+func (c limitedFloat64Counter) Add(ctx context.Context, incr float64, opts ...metric.AddOption) {
+	c.Float64Counter.Add(ctx, incr, c.l.addOption(ctx, opts))
+}
+
+type limitedInt64UpDownCounter struct {
+	metric.Int64UpDownCounter
+	l *limiter
+}
+
+func (c limitedInt64UpDownCounter) Add(ctx context.Context, incr int64, opts ...metric.AddOption) {
+	c.Int64UpDownCounter.Add(ctx, incr, c.l.addOption(ctx, opts))
+}
+
+type limitedFloat64UpDownCounter struct {
+	metric.Float64UpDownCounter
+	l *limiter
+}
+
+func (c limitedFloat64UpDownCounter) Add(ctx context.Context, incr float64, opts ...metric.AddOption) {
+	c.Float64UpDownCounter.Add(ctx, incr, c.l.addOption(ctx, opts))
+}
+
+type limitedInt64Histogram struct {
+	metric.Int64Histogram
+	l *limiter
+}
+
+func (h limitedInt64Histogram) Record(ctx context.Context, incr int64, opts ...metric.RecordOption) {
+	h.Int64Histogram.Record(ctx, incr, h.l.recordOption(ctx, opts))
+}
+
+type limitedFloat64Histogram struct {
+	metric.Float64Histogram
+	l *limiter
+}
+
+func (h limitedFloat64Histogram) Record(ctx context.Context, incr float64, opts ...metric.RecordOption) {
+	h.Float64Histogram.Record(ctx, incr, h.l.recordOption(ctx, opts))
+}
+
+type limitedInt64Gauge struct {
+	metric.Int64Gauge
+	l *limiter
+}
+
+func (g limitedInt64Gauge) Record(ctx context.Context, value int64, opts ...metric.RecordOption) {
+	g.Int64Gauge.Record(ctx, value, g.l.recordOption(ctx, opts))
+}
+
+type limitedFloat64Gauge struct {
+	metric.Float64Gauge
+	l *limiter
+}
+
+func (g limitedFloat64Gauge) Record(ctx context.Context, value float64, opts ...metric.RecordOption) {
+	g.Float64Gauge.Record(ctx, value, g.l.recordOption(ctx, opts))
+}
@@ -0,0 +1,103 @@
+package metrics_test
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/twistingmercury/observability/logger"
+	"github.com/twistingmercury/observability/metrics"
+	"github.com/twistingmercury/observability/observeCfg"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+func TestCardinalityLimit(t *testing.T) {
+	collected := collect(t, metrics.WithCardinalityLimit(2))
+	logBuf := &bytes.Buffer{}
+	logger.Initialize(logBuf, logrus.DebugLevel)
+	ctx := context.Background()
+
+	c, err := metrics.NewInt64Counter("requests", metrics.InstrumentOptions{})
+	require.NoError(t, err)
+	for _, user := range []string{"a", "b", "a", "c", "d", "c"} {
+		c.Add(ctx, 1, metric.WithAttributes(attribute.String("user", user)))
+	}
+
+	h, err := metrics.NewFloat64Histogram("latency", metrics.InstrumentOptions{CardinalityLimit: -1})
+	require.NoError(t, err)
+	for _, user := range []string{"a", "b", "c", "d"} {
+		h.Record(ctx, 1, metric.WithAttributes(attribute.String("user", user)))
+	}
+
+	m := collected()
+	assert.Equal(t, map[string]int64{
+		"user=a":                    2,
+		"user=b":                    1,
+		"otel.metric.overflow=true": 3,
+	}, attributeSets(t, m[collectedPrefix+"requests"]))
+	assert.Len(t, m[collectedPrefix+"latency"].Data.(metricdata.Histogram[float64]).DataPoints, 4,
+		"an unlimited instrument records every attribute set")
+	assert.Equal(t, map[string]int64{
+		"metric.name=" + collectedPrefix + "requests": 3,
+	}, attributeSets(t, m[collectedPrefix+"metrics.overflow_measurements"]))
+	assert.Equal(t, 1, strings.Count(logBuf.String(), "the cardinality limit of the instrument has been reached"),
+		"the warning is logged once")
+}
+
+func TestCardinalityLimit_Delta(t *testing.T) {
+	selector, err := metrics.TemporalitySelector(observeCfg.DeltaTemporality, nil)
+	require.NoError(t, err)
+	collected := collect(t, metrics.WithCardinalityLimit(1), metrics.WithTemporality(selector))
+	ctx := context.Background()
+
+	c, err := metrics.NewInt64Counter("requests", metrics.InstrumentOptions{})
+	require.NoError(t, err)
+	udc, err := metrics.NewInt64UpDownCounter("sessions", metrics.InstrumentOptions{})
+	require.NoError(t, err)
+	c.Add(ctx, 1, metric.WithAttributes(attribute.String("user", "a")))
+	udc.Add(ctx, 1, metric.WithAttributes(attribute.String("user", "a")))
+	require.NoError(t, metrics.ForceFlush(ctx))
+	for _, user := range []string{"b", "c"} {
+		c.Add(ctx, 1, metric.WithAttributes(attribute.String("user", user)))
+		udc.Add(ctx, 1, metric.WithAttributes(attribute.String("user", user)))
+	}
+
+	m := collected()
+	assert.Equal(t, map[string]int64{
+		"user=a":                    1,
+		"user=b":                    1,
+		"otel.metric.overflow=true": 1,
+	}, attributeSets(t, m[collectedPrefix+"requests"]), "a delta instrument records up to the limit each cycle")
+	assert.Equal(t, map[string]int64{
+		"user=a":                    1,
+		"otel.metric.overflow=true": 2,
+	}, attributeSets(t, m[collectedPrefix+"sessions"]), "a cumulative instrument is limited across cycles")
+}
+
+func TestCardinalityLimit_Default(t *testing.T) {
+	collected := collect(t)
+	ctx := context.Background()
+
+	g, err := metrics.NewInt64Gauge("connections", metrics.InstrumentOptions{CardinalityLimit: 1})
+	require.NoError(t, err)
+	g.Record(ctx, 1, metric.WithAttributes(attribute.String("peer", "a")))
+	g.Record(ctx, 2, metric.WithAttributes(attribute.String("peer", "b")))
+
+	c, err := metrics.NewFloat64Counter("bytes", metrics.InstrumentOptions{})
+	require.NoError(t, err)
+	for i := 0; i < 10; i++ {
+		c.Add(ctx, 1, metric.WithAttributes(attribute.Int("i", i)))
+	}
+
+	m := collected()
+	gauge := m[collectedPrefix+"connections"].Data.(metricdata.Gauge[int64]).DataPoints
+	require.Len(t, gauge, 2)
+	assert.Len(t, m[collectedPrefix+"bytes"].Data.(metricdata.Sum[float64]).DataPoints, 10,
+		"the default limit is far above the attribute sets of the test")
+}
@@ -0,0 +1,33 @@
+package metrics
+
+import (
+	"fmt"
+
+	"github.com/twistingmercury/observability/observeCfg"
+	"go.opentelemetry.io/otel/sdk/metric/exemplar"
+)
+
+// WithExemplarFilter sets the filter selecting the measurements that may be sampled as exemplars. An exemplar holds
+// the trace_id and span_id of the span active in the context the measurement was recorded with, so that a spike of a
+// histogram, e.g. the request duration recorded by Middleware, links to an example trace. By default, only the
+// measurements recorded in a sampled span are sampled, i.e. exemplar.TraceBasedFilter.
+func WithExemplarFilter(filter exemplar.Filter) Option {
+	return func(c *config) {
+		c.exemplarFilter = filter
+	}
+}
+
+// ExemplarFilter returns the exemplar filter named by observeCfg.MetricsExemplarFilter: `always_on`, `always_off`
+// or `trace_based`.
+func ExemplarFilter(name string) (exemplar.Filter, error) {
+	switch name {
+	case observeCfg.AlwaysOnExemplarFilter:
+		return exemplar.AlwaysOnFilter, nil
+	case observeCfg.AlwaysOffExemplarFilter:
+		return exemplar.AlwaysOffFilter, nil
+	case observeCfg.TraceBasedExemplarFilter:
+		return exemplar.TraceBasedFilter, nil
+	default:
+		return nil, fmt.Errorf("invalid exemplar filter: %s", name)
+	}
+}
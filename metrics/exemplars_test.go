@@ -0,0 +1,90 @@
+package metrics_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/twistingmercury/observability/metrics"
+	"github.com/twistingmercury/observability/observeCfg"
+	"go.opentelemetry.io/otel/sdk/metric/exemplar"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// serveTraced serves a request in a span started by a sampled tracer, and returns the span context.
+func serveTraced(t *testing.T, h gin.HandlerFunc, sampler sdktrace.Sampler) trace.SpanContext {
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSampler(sampler))
+	t.Cleanup(func() { _ = tp.Shutdown(context.Background()) })
+
+	_, e := gin.CreateTestContext(httptest.NewRecorder())
+	e.Use(h)
+	var sc trace.SpanContext
+	e.GET("/ok", func(c *gin.Context) {
+		ctx, span := tp.Tracer("unit-test").Start(c.Request.Context(), "handler")
+		defer span.End()
+		sc = span.SpanContext()
+		c.Request = c.Request.WithContext(ctx)
+		c.Status(http.StatusOK)
+	})
+	e.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/ok", nil))
+	return sc
+}
+
+func durationExemplars(t *testing.T, collected func() map[string]metricdata.Metrics) []metricdata.Exemplar[float64] {
+	dps := collected()[durationName].Data.(metricdata.Histogram[float64]).DataPoints
+	require.Len(t, dps, 1)
+	return dps[0].Exemplars
+}
+
+func TestExemplars_TraceBased(t *testing.T) {
+	collected := collect(t)
+	require.NoError(t, metrics.InitializeMetrics())
+
+	sc := serveTraced(t, metrics.Middleware(), sdktrace.AlwaysSample())
+
+	ex := durationExemplars(t, collected)
+	require.Len(t, ex, 1)
+	tid, sid := sc.TraceID(), sc.SpanID()
+	assert.Equal(t, tid[:], ex[0].TraceID)
+	assert.Equal(t, sid[:], ex[0].SpanID)
+}
+
+func TestExemplars_TraceBased_NotSampled(t *testing.T) {
+	collected := collect(t)
+	require.NoError(t, metrics.InitializeMetrics())
+
+	serveTraced(t, metrics.Middleware(), sdktrace.NeverSample())
+	assert.Empty(t, durationExemplars(t, collected), "only the measurements of sampled spans are exemplars")
+}
+
+func TestExemplars_Filters(t *testing.T) {
+	on, err := metrics.ExemplarFilter(observeCfg.AlwaysOnExemplarFilter)
+	require.NoError(t, err)
+	collected := collect(t, metrics.WithExemplarFilter(on))
+
+	h, err := metrics.NewHistogram("order.value", "The value of an order.")
+	require.NoError(t, err)
+	h.Record(context.Background(), 12.5)
+
+	dps := collected()[collectedPrefix+"order.value"].Data.(metricdata.Histogram[float64]).DataPoints
+	require.Len(t, dps[0].Exemplars, 1, "always_on samples measurements recorded outside of a span")
+	assert.Equal(t, 12.5, dps[0].Exemplars[0].Value)
+	assert.Empty(t, dps[0].Exemplars[0].TraceID)
+
+	_, err = metrics.ExemplarFilter("sometimes")
+	assert.Error(t, err)
+}
+
+func TestExemplars_Off(t *testing.T) {
+	collected := collect(t, metrics.WithExemplarFilter(exemplar.AlwaysOffFilter))
+	require.NoError(t, metrics.InitializeMetrics())
+
+	serveTraced(t, metrics.Middleware(), sdktrace.AlwaysSample())
+	assert.Empty(t, durationExemplars(t, collected))
+}
@@ -0,0 +1,114 @@
+package metrics
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/twistingmercury/observability/observeCfg"
+	sdkMetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+// instrumentKinds maps the instrument kinds of observeCfg to those of the SDK.
+var instrumentKinds = map[string]sdkMetric.InstrumentKind{
+	observeCfg.CounterKind:                 sdkMetric.InstrumentKindCounter,
+	observeCfg.UpDownCounterKind:           sdkMetric.InstrumentKindUpDownCounter,
+	observeCfg.HistogramKind:               sdkMetric.InstrumentKindHistogram,
+	observeCfg.GaugeKind:                   sdkMetric.InstrumentKindGauge,
+	observeCfg.ObservableCounterKind:       sdkMetric.InstrumentKindObservableCounter,
+	observeCfg.ObservableUpDownCounterKind: sdkMetric.InstrumentKindObservableUpDownCounter,
+	observeCfg.ObservableGaugeKind:         sdkMetric.InstrumentKindObservableGauge,
+}
+
+// WithExportInterval sets the interval between two exports of the periodic reader. The SDK default is 60 seconds.
+func WithExportInterval(d time.Duration) Option {
+	return func(c *config) {
+		c.exportInterval = d
+	}
+}
+
+// WithExportTimeout sets the maximum time an export of the periodic reader may take. The SDK default is 30 seconds.
+func WithExportTimeout(d time.Duration) Option {
+	return func(c *config) {
+		c.exportTimeout = d
+	}
+}
+
+// WithTemporality sets the temporality of the exported metrics per instrument kind, e.g. the selector returned by
+// TemporalitySelector. It is ignored by the Prometheus reader, which is always cumulative.
+func WithTemporality(selector sdkMetric.TemporalitySelector) Option {
+	return func(c *config) {
+		c.temporality = selector
+	}
+}
+
+// TemporalitySelector returns the temporality selector of a preference, `cumulative`, `delta` or `lowmemory` as
+// defined by the OpenTelemetry specification, and of the overrides of individual instrument kinds, e.g.
+// `counter=delta`.
+//
+// With `delta`, the counters, histograms and observable counters are delta and the other instruments cumulative.
+// With `lowmemory`, only the synchronous counters and histograms are delta.
+func TemporalitySelector(preference string, overrides map[string]string) (sdkMetric.TemporalitySelector, error) {
+	var delta map[sdkMetric.InstrumentKind]bool
+	switch preference {
+	case observeCfg.CumulativeTemporality, "":
+		delta = map[sdkMetric.InstrumentKind]bool{}
+	case observeCfg.DeltaTemporality:
+		delta = map[sdkMetric.InstrumentKind]bool{
+			sdkMetric.InstrumentKindCounter:           true,
+			sdkMetric.InstrumentKindHistogram:         true,
+			sdkMetric.InstrumentKindObservableCounter: true,
+		}
+	case observeCfg.LowMemoryTemporality:
+		delta = map[sdkMetric.InstrumentKind]bool{
+			sdkMetric.InstrumentKindCounter:   true,
+			sdkMetric.InstrumentKindHistogram: true,
+		}
+	default:
+		return nil, fmt.Errorf("invalid temporality preference: %s", preference)
+	}
+
+	for k, t := range overrides {
+		kind, ok := instrumentKinds[k]
+		if !ok {
+			return nil, fmt.Errorf("invalid temporality override: unknown instrument kind %s", k)
+		}
+		switch t {
+		case observeCfg.CumulativeTemporality:
+			delta[kind] = false
+		case observeCfg.DeltaTemporality:
+			delta[kind] = true
+		default:
+			return nil, fmt.Errorf("invalid temporality override: %s=%s", k, t)
+		}
+	}
+
+	return func(kind sdkMetric.InstrumentKind) metricdata.Temporality {
+		if delta[kind] {
+			return metricdata.DeltaTemporality
+		}
+		return metricdata.CumulativeTemporality
+	}, nil
+}
+
+// temporalExporter overrides the temporality of an exporter; the periodic reader reads it from its exporter.
+type temporalExporter struct {
+	sdkMetric.Exporter
+	selector sdkMetric.TemporalitySelector
+}
+
+func (e temporalExporter) Temporality(kind sdkMetric.InstrumentKind) metricdata.Temporality {
+	return e.selector(kind)
+}
+
+// periodicReaderOptions returns the interval and timeout of the periodic reader, if they are set.
+func (c *config) periodicReaderOptions() []sdkMetric.PeriodicReaderOption {
+	var opts []sdkMetric.PeriodicReaderOption
+	if c.exportInterval > 0 {
+		opts = append(opts, sdkMetric.WithInterval(c.exportInterval))
+	}
+	if c.exportTimeout > 0 {
+		opts = append(opts, sdkMetric.WithTimeout(c.exportTimeout))
+	}
+	return opts
+}
@@ -0,0 +1,224 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/twistingmercury/observability/logger"
+	"github.com/twistingmercury/observability/observeCfg"
+	"go.opentelemetry.io/otel/metric"
+	sdkMetric "go.opentelemetry.io/otel/sdk/metric"
+)
+
+// InstrumentOptions are the options used to create an instrument.
+type InstrumentOptions struct {
+	// Description describes what the instrument measures.
+	Description string
+	// Unit is the UCUM unit of the measurements, e.g. `s`, `By` or `{request}`. Units in curly braces are
+	// annotations; they are not appended to Prometheus names.
+	Unit string
+	// Buckets are the explicit bucket boundaries of a histogram. If empty, the default boundaries are used:
+	// DefaultLatencyBuckets for float64 histograms whose unit is SecondsUnit, or the SDK defaults otherwise. They are
+	// ignored by the other instruments.
+	Buckets []float64
+	// CardinalityLimit is the number of unique attribute sets a synchronous instrument records; the measurements
+	// with any other attribute set are folded into the overflow series. If zero, the limit set by
+	// WithCardinalityLimit is used. If negative, the instrument is unlimited.
+	CardinalityLimit int
+}
+
+// instrumentName returns the fully qualified name of an instrument: `namespace.service.name`.
+func instrumentName(name string) string {
+	return fmt.Sprintf("%s.%s.%s", namespace, observeCfg.ServiceName(), name)
+}
+
+func logCreated(kind, fname string) {
+	logger.Debug(fmt.Sprintf("new %s created", kind), logger.Attribute{Key: "name", Value: fname})
+}
+
+// limit wraps the instrument i, unless it failed to be created or it is unlimited, so that its measurements are
+// capped by a limiter.
+func limit[I any](i I, err error, fname string, kind sdkMetric.InstrumentKind, cardinality int, wrap func(I, *limiter) I) (I, error) {
+	if err != nil {
+		return i, err
+	}
+	if l := newLimiter(fname, kind, cardinality); l != nil {
+		return wrap(i, l), nil
+	}
+	return i, nil
+}
+
+// NewInt64Counter creates a counter of int64 values using the given name and options.
+func NewInt64Counter(name string, opts InstrumentOptions) (metric.Int64Counter, error) {
+	fname := instrumentName(name)
+	logCreated("counter", fname)
+	i, err := meter.Int64Counter(fname, metric.WithDescription(opts.Description), metric.WithUnit(opts.Unit))
+	return limit(i, err, fname, sdkMetric.InstrumentKindCounter, opts.CardinalityLimit, func(i metric.Int64Counter, l *limiter) metric.Int64Counter {
+		return limitedInt64Counter{i, l}
+	})
+}
+
+// NewFloat64Counter creates a counter of float64 values using the given name and options.
+func NewFloat64Counter(name string, opts InstrumentOptions) (metric.Float64Counter, error) {
+	fname := instrumentName(name)
+	logCreated("counter", fname)
+	i, err := meter.Float64Counter(fname, metric.WithDescription(opts.Description), metric.WithUnit(opts.Unit))
+	return limit(i, err, fname, sdkMetric.InstrumentKindCounter, opts.CardinalityLimit, func(i metric.Float64Counter, l *limiter) metric.Float64Counter {
+		return limitedFloat64Counter{i, l}
+	})
+}
+
+// NewInt64UpDownCounter creates an up/down counter of int64 values using the given name and options.
+func NewInt64UpDownCounter(name string, opts InstrumentOptions) (metric.Int64UpDownCounter, error) {
+	fname := instrumentName(name)
+	logCreated("up/down counter", fname)
+	i, err := meter.Int64UpDownCounter(fname, metric.WithDescription(opts.Description), metric.WithUnit(opts.Unit))
+	return limit(i, err, fname, sdkMetric.InstrumentKindUpDownCounter, opts.CardinalityLimit, func(i metric.Int64UpDownCounter, l *limiter) metric.Int64UpDownCounter {
+		return limitedInt64UpDownCounter{i, l}
+	})
+}
+
+// NewFloat64UpDownCounter creates an up/down counter of float64 values using the given name and options.
+func NewFloat64UpDownCounter(name string, opts InstrumentOptions) (metric.Float64UpDownCounter, error) {
+	fname := instrumentName(name)
+	logCreated("up/down counter", fname)
+	i, err := meter.Float64UpDownCounter(fname, metric.WithDescription(opts.Description), metric.WithUnit(opts.Unit))
+	return limit(i, err, fname, sdkMetric.InstrumentKindUpDownCounter, opts.CardinalityLimit, func(i metric.Float64UpDownCounter, l *limiter) metric.Float64UpDownCounter {
+		return limitedFloat64UpDownCounter{i, l}
+	})
+}
+
+// NewInt64Histogram creates a histogram of int64 values using the given name and options.
+func NewInt64Histogram(name string, opts InstrumentOptions) (metric.Int64Histogram, error) {
+	fname := instrumentName(name)
+	logCreated("histogram", fname)
+	hOpts := []metric.Int64HistogramOption{metric.WithDescription(opts.Description), metric.WithUnit(opts.Unit)}
+	if len(opts.Buckets) > 0 {
+		setExplicitBuckets(fname)
+		hOpts = append(hOpts, metric.WithExplicitBucketBoundaries(opts.Buckets...))
+	}
+	i, err := meter.Int64Histogram(fname, hOpts...)
+	return limit(i, err, fname, sdkMetric.InstrumentKindHistogram, opts.CardinalityLimit, func(i metric.Int64Histogram, l *limiter) metric.Int64Histogram {
+		return limitedInt64Histogram{i, l}
+	})
+}
+
+// NewFloat64Histogram creates a histogram of float64 values using the given name and options.
+func NewFloat64Histogram(name string, opts InstrumentOptions) (metric.Float64Histogram, error) {
+	fname := instrumentName(name)
+	logCreated("histogram", fname)
+	hOpts := []metric.Float64HistogramOption{metric.WithDescription(opts.Description), metric.WithUnit(opts.Unit)}
+	switch {
+	case len(opts.Buckets) > 0:
+		setExplicitBuckets(fname)
+		hOpts = append(hOpts, metric.WithExplicitBucketBoundaries(opts.Buckets...))
+	case opts.Unit == SecondsUnit:
+		hOpts = append(hOpts, metric.WithExplicitBucketBoundaries(DefaultLatencyBuckets...))
+	}
+	i, err := meter.Float64Histogram(fname, hOpts...)
+	return limit(i, err, fname, sdkMetric.InstrumentKindHistogram, opts.CardinalityLimit, func(i metric.Float64Histogram, l *limiter) metric.Float64Histogram {
+		return limitedFloat64Histogram{i, l}
+	})
+}
+
+// NewInt64Gauge creates a synchronous gauge of int64 values using the given name and options. Unlike an observable
+// gauge, its value is recorded when it changes rather than read by a callback at collection.
+func NewInt64Gauge(name string, opts InstrumentOptions) (metric.Int64Gauge, error) {
+	fname := instrumentName(name)
+	logCreated("gauge", fname)
+	i, err := meter.Int64Gauge(fname, metric.WithDescription(opts.Description), metric.WithUnit(opts.Unit))
+	return limit(i, err, fname, sdkMetric.InstrumentKindGauge, opts.CardinalityLimit, func(i metric.Int64Gauge, l *limiter) metric.Int64Gauge {
+		return limitedInt64Gauge{i, l}
+	})
+}
+
+// NewFloat64Gauge creates a synchronous gauge of float64 values using the given name and options. Unlike an
+// observable gauge, its value is recorded when it changes rather than read by a callback at collection.
+func NewFloat64Gauge(name string, opts InstrumentOptions) (metric.Float64Gauge, error) {
+	fname := instrumentName(name)
+	logCreated("gauge", fname)
+	i, err := meter.Float64Gauge(fname, metric.WithDescription(opts.Description), metric.WithUnit(opts.Unit))
+	return limit(i, err, fname, sdkMetric.InstrumentKindGauge, opts.CardinalityLimit, func(i metric.Float64Gauge, l *limiter) metric.Float64Gauge {
+		return limitedFloat64Gauge{i, l}
+	})
+}
+
+// NewInt64ObservableGauge creates an observable gauge of int64 values using the given name and options. The
+// callbacks are invoked, and must observe the current value, each time the metrics are collected.
+func NewInt64ObservableGauge(name string, opts InstrumentOptions, callbacks ...metric.Int64Callback) (metric.Int64ObservableGauge, error) {
+	fname := instrumentName(name)
+	logCreated("observable gauge", fname)
+	return meter.Int64ObservableGauge(fname, metric.WithDescription(opts.Description), metric.WithUnit(opts.Unit), int64Callbacks(callbacks))
+}
+
+// NewFloat64ObservableGauge creates an observable gauge of float64 values using the given name and options. The
+// callbacks are invoked, and must observe the current value, each time the metrics are collected.
+func NewFloat64ObservableGauge(name string, opts InstrumentOptions, callbacks ...metric.Float64Callback) (metric.Float64ObservableGauge, error) {
+	fname := instrumentName(name)
+	logCreated("observable gauge", fname)
+	return meter.Float64ObservableGauge(fname, metric.WithDescription(opts.Description), metric.WithUnit(opts.Unit), float64Callbacks(callbacks))
+}
+
+// NewInt64ObservableCounter creates an observable counter of int64 values using the given name and options. The
+// callbacks are invoked, and must observe the current total, each time the metrics are collected.
+func NewInt64ObservableCounter(name string, opts InstrumentOptions, callbacks ...metric.Int64Callback) (metric.Int64ObservableCounter, error) {
+	fname := instrumentName(name)
+	logCreated("observable counter", fname)
+	return meter.Int64ObservableCounter(fname, metric.WithDescription(opts.Description), metric.WithUnit(opts.Unit), int64Callbacks(callbacks))
+}
+
+// NewFloat64ObservableCounter creates an observable counter of float64 values using the given name and options.
+// The callbacks are invoked, and must observe the current total, each time the metrics are collected.
+func NewFloat64ObservableCounter(name string, opts InstrumentOptions, callbacks ...metric.Float64Callback) (metric.Float64ObservableCounter, error) {
+	fname := instrumentName(name)
+	logCreated("observable counter", fname)
+	return meter.Float64ObservableCounter(fname, metric.WithDescription(opts.Description), metric.WithUnit(opts.Unit), float64Callbacks(callbacks))
+}
+
+// NewInt64ObservableUpDownCounter creates an observable up/down counter of int64 values using the given name and
+// options. The callbacks are invoked, and must observe the current total, each time the metrics are collected.
+func NewInt64ObservableUpDownCounter(name string, opts InstrumentOptions, callbacks ...metric.Int64Callback) (metric.Int64ObservableUpDownCounter, error) {
+	fname := instrumentName(name)
+	logCreated("observable up/down counter", fname)
+	return meter.Int64ObservableUpDownCounter(fname, metric.WithDescription(opts.Description), metric.WithUnit(opts.Unit), int64Callbacks(callbacks))
+}
+
+// NewFloat64ObservableUpDownCounter creates an observable up/down counter of float64 values using the given name
+// and options. The callbacks are invoked, and must observe the current total, each time the metrics are collected.
+func NewFloat64ObservableUpDownCounter(name string, opts InstrumentOptions, callbacks ...metric.Float64Callback) (metric.Float64ObservableUpDownCounter, error) {
+	fname := instrumentName(name)
+	logCreated("observable up/down counter", fname)
+	return meter.Float64ObservableUpDownCounter(fname, metric.WithDescription(opts.Description), metric.WithUnit(opts.Unit), float64Callbacks(callbacks))
+}
+
+// RegisterCallback registers a callback that observes several observable instruments at once, e.g. values that
+// are read together from the same source. The callback is invoked each time the metrics are collected, until it
+// is unregistered.
+func RegisterCallback(callback metric.Callback, instruments ...metric.Observable) (metric.Registration, error) {
+	return meter.RegisterCallback(callback, instruments...)
+}
+
+// int64Callbacks returns the option registering the callbacks of an observable int64 instrument. It implements the
+// options of every observable int64 instrument.
+func int64Callbacks(callbacks []metric.Int64Callback) metric.Int64ObservableOption {
+	return metric.WithInt64Callback(func(ctx context.Context, o metric.Int64Observer) error {
+		var errs []error
+		for _, cb := range callbacks {
+			errs = append(errs, cb(ctx, o))
+		}
+		return errors.Join(errs...)
+	})
+}
+
+// float64Callbacks returns the option registering the callbacks of an observable float64 instrument. It implements
+// the options of every observable float64 instrument.
+func float64Callbacks(callbacks []metric.Float64Callback) metric.Float64ObservableOption {
+	return metric.WithFloat64Callback(func(ctx context.Context, o metric.Float64Observer) error {
+		var errs []error
+		for _, cb := range callbacks {
+			errs = append(errs, cb(ctx, o))
+		}
+		return errors.Join(errs...)
+	})
+}
@@ -0,0 +1,160 @@
+package metrics_test
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/twistingmercury/observability/logger"
+	"github.com/twistingmercury/observability/metrics"
+	"github.com/twistingmercury/observability/observeCfg"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	sdkMetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+// collectedPrefix is the prefix of the names of the metrics collected by collect: the namespace and the service.
+const collectedPrefix = "unit.test.instrument-tests."
+
+// initializeInstrumentsConfig initializes observeCfg for the tests of the instruments.
+func initializeInstrumentsConfig(t *testing.T) {
+	os.Setenv(observeCfg.LogLevelEnvVar, "debug")
+	os.Setenv(observeCfg.TraceEndpointEnvVar, "localhost:4317")
+	os.Setenv(observeCfg.MetricsEndpointEnvVar, "localhost:4317")
+	os.Setenv(observeCfg.EnvironEnvVar, "localhost")
+	t.Cleanup(func() {
+		os.Unsetenv(observeCfg.LogLevelEnvVar)
+		os.Unsetenv(observeCfg.TraceEndpointEnvVar)
+		os.Unsetenv(observeCfg.MetricsEndpointEnvVar)
+		os.Unsetenv(observeCfg.EnvironEnvVar)
+	})
+	require.NoError(t, observeCfg.Initialize("instrument-tests", "2023-01-01T00:00:00.000", "0.0.0", "abcd0123"))
+}
+
+// collect initializes the metrics with a manual reader, and returns a func that collects the metrics by name.
+func collect(t *testing.T, opts ...metrics.Option) func() map[string]metricdata.Metrics {
+	logger.Initialize(&bytes.Buffer{}, logrus.DebugLevel)
+	initializeInstrumentsConfig(t)
+
+	ctx := context.Background()
+	reader := sdkMetric.NewManualReader()
+	shutdown, err := metrics.InitializeConsole("unit.test", io.Discard, append(opts, metrics.WithReader(reader))...)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = shutdown(ctx)
+		metrics.Reset()
+	})
+
+	return func() map[string]metricdata.Metrics {
+		rm := metricdata.ResourceMetrics{}
+		require.NoError(t, reader.Collect(ctx, &rm))
+		byName := map[string]metricdata.Metrics{}
+		for _, sm := range rm.ScopeMetrics {
+			for _, m := range sm.Metrics {
+				byName[m.Name] = m
+			}
+		}
+		return byName
+	}
+}
+
+func TestSynchronousInstruments(t *testing.T) {
+	collected := collect(t)
+	ctx := context.Background()
+
+	fc, err := metrics.NewFloat64Counter("bytes.sent", metrics.InstrumentOptions{Unit: "By", Description: "The bytes sent."})
+	require.NoError(t, err)
+	fc.Add(ctx, 1.5)
+	fc.Add(ctx, 2)
+
+	ih, err := metrics.NewInt64Histogram("batch.size", metrics.InstrumentOptions{Buckets: []float64{10, 100}})
+	require.NoError(t, err)
+	ih.Record(ctx, 5)
+	ih.Record(ctx, 50)
+	ih.Record(ctx, 500)
+
+	g, err := metrics.NewFloat64Gauge("temperature", metrics.InstrumentOptions{Unit: "Cel"})
+	require.NoError(t, err)
+	g.Record(ctx, 20)
+	g.Record(ctx, 21.5)
+
+	m := collected()
+
+	sum := m[collectedPrefix+"bytes.sent"]
+	assert.Equal(t, "By", sum.Unit)
+	assert.Equal(t, "The bytes sent.", sum.Description)
+	assert.Equal(t, 3.5, sum.Data.(metricdata.Sum[float64]).DataPoints[0].Value)
+
+	hist := m[collectedPrefix+"batch.size"].Data.(metricdata.Histogram[int64]).DataPoints[0]
+	assert.Equal(t, []float64{10, 100}, hist.Bounds)
+	assert.Equal(t, []uint64{1, 1, 1}, hist.BucketCounts)
+
+	gauge := m[collectedPrefix+"temperature"]
+	assert.Equal(t, "Cel", gauge.Unit)
+	assert.Equal(t, 21.5, gauge.Data.(metricdata.Gauge[float64]).DataPoints[0].Value, "a gauge keeps the last value")
+}
+
+func TestObservableInstruments(t *testing.T) {
+	collected := collect(t)
+
+	queued := int64(3)
+	_, err := metrics.NewInt64ObservableGauge("queue.length", metrics.InstrumentOptions{},
+		func(_ context.Context, o metric.Int64Observer) error {
+			o.Observe(queued)
+			return nil
+		})
+	require.NoError(t, err)
+
+	_, err = metrics.NewFloat64ObservableCounter("cpu.time", metrics.InstrumentOptions{Unit: "s"},
+		func(_ context.Context, o metric.Float64Observer) error {
+			o.Observe(1.25)
+			return nil
+		})
+	require.NoError(t, err)
+
+	// every callback is invoked.
+	_, err = metrics.NewInt64ObservableUpDownCounter("workers", metrics.InstrumentOptions{},
+		func(_ context.Context, o metric.Int64Observer) error {
+			o.Observe(1, metric.WithAttributes(attribute.String("pool", "a")))
+			return nil
+		},
+		func(_ context.Context, o metric.Int64Observer) error {
+			o.Observe(2, metric.WithAttributes(attribute.String("pool", "b")))
+			return nil
+		})
+	require.NoError(t, err)
+
+	hits, err := metrics.NewInt64ObservableCounter("cache.hits", metrics.InstrumentOptions{})
+	require.NoError(t, err)
+	entries, err := metrics.NewInt64ObservableUpDownCounter("cache.entries", metrics.InstrumentOptions{})
+	require.NoError(t, err)
+	reg, err := metrics.RegisterCallback(func(_ context.Context, o metric.Observer) error {
+		o.ObserveInt64(hits, 10)
+		o.ObserveInt64(entries, 4)
+		return nil
+	}, hits, entries)
+	require.NoError(t, err)
+
+	m := collected()
+	assert.Equal(t, int64(3), m[collectedPrefix+"queue.length"].Data.(metricdata.Gauge[int64]).DataPoints[0].Value)
+	assert.Equal(t, 1.25, m[collectedPrefix+"cpu.time"].Data.(metricdata.Sum[float64]).DataPoints[0].Value)
+	assert.Equal(t, map[string]int64{"pool=a": 1, "pool=b": 2}, attributeSets(t, m[collectedPrefix+"workers"]))
+	assert.Equal(t, int64(10), m[collectedPrefix+"cache.hits"].Data.(metricdata.Sum[int64]).DataPoints[0].Value)
+	entriesSum := m[collectedPrefix+"cache.entries"].Data.(metricdata.Sum[int64])
+	assert.False(t, entriesSum.IsMonotonic)
+	assert.Equal(t, int64(4), entriesSum.DataPoints[0].Value)
+
+	queued = 7
+	m = collected()
+	assert.Equal(t, int64(7), m[collectedPrefix+"queue.length"].Data.(metricdata.Gauge[int64]).DataPoints[0].Value, "callbacks are invoked on every collection")
+
+	require.NoError(t, reg.Unregister())
+	m = collected()
+	assert.NotContains(t, m, collectedPrefix+"cache.hits", "an unregistered callback is no longer invoked")
+}
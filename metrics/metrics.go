@@ -5,41 +5,116 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/twistingmercury/observability/localExport"
 	"github.com/twistingmercury/observability/logger"
 	"github.com/twistingmercury/observability/observeCfg"
-	"go.opentelemetry.io/otel/attribute"
+	"github.com/twistingmercury/observability/otlpHttp"
+	"github.com/twistingmercury/observability/resources"
+	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetricgrpc"
 	"go.opentelemetry.io/otel/metric"
-	"go.opentelemetry.io/otel/metric/global"
+	"go.opentelemetry.io/otel/metric/noop"
 	sdkMetric "go.opentelemetry.io/otel/sdk/metric"
-	"go.opentelemetry.io/otel/sdk/resource"
+	"go.opentelemetry.io/otel/sdk/metric/exemplar"
 	"google.golang.org/grpc"
+	"io"
+	"sync/atomic"
+	"time"
 )
 
 var (
 	isInitialized bool
-	meter         metric.Meter
-	reader        sdkMetric.Reader
-	exporter      sdkMetric.Exporter
-	provider      *sdkMetric.MeterProvider
+	// meter is a no-op meter until Initialize succeeds, so instruments can always be created.
+	meter    metric.Meter = noop.NewMeterProvider().Meter("")
+	reader   sdkMetric.Reader
+	exporter sdkMetric.Exporter
+	provider *sdkMetric.MeterProvider
+
+	// promRegistry is the registry of the Prometheus pull reader, if it is enabled. PrometheusHandler reads it while
+	// the metrics are initialized.
+	promRegistry atomic.Pointer[prometheus.Registry]
 
 	namespace string
-	attribs   = []attribute.KeyValue{
-		{Key: "service", Value: attribute.StringValue(observeCfg.ServiceName())},
-		{Key: "host", Value: attribute.StringValue(observeCfg.HostName())},
-		{Key: "container_id", Value: attribute.StringValue(observeCfg.HostName())},
-		{Key: "env", Value: attribute.StringValue(observeCfg.Environment())},
-		{Key: "service_version", Value: attribute.StringValue(observeCfg.Version())},
-		{Key: "build_date", Value: attribute.StringValue(observeCfg.BuildDate())},
-		{Key: "commit_hash", Value: attribute.StringValue(observeCfg.CommitHash())}}
 )
 
 func reset() {
+	resetRuntimeMetrics()
 	isInitialized = false
-	_ = exporter.Shutdown(context.Background())
-	exporter = nil
-	_ = reader.Shutdown(context.Background())
-	_ = provider.Shutdown(context.Background())
+	middlewareInitialized = false
+	activeReq = noop.Int64UpDownCounter{}
+	totalReq = noop.Int64Counter{}
+	avgReqDur = noop.Float64Histogram{}
+	reqSize = noop.Int64Histogram{}
+	respSize = noop.Int64Histogram{}
+	meter = noop.NewMeterProvider().Meter("")
+	if exporter != nil {
+		_ = exporter.Shutdown(context.Background())
+		exporter = nil
+	}
+	if reader != nil {
+		_ = reader.Shutdown(context.Background())
+		reader = nil
+	}
+	if provider != nil {
+		_ = provider.Shutdown(context.Background())
+		provider = nil
+	}
+	promRegistry.Store(nil)
+	resetCardinality()
+	resetExplicitBuckets()
+}
+
+// Option configures the readers and the views registered by the Initialize funcs.
+type Option func(*config)
+
+type config struct {
+	prometheus     bool
+	readers        []sdkMetric.Reader
+	latencyBuckets []float64
+	exponential    bool
+	// cardinalityLimit is nil unless set by WithCardinalityLimit.
+	cardinalityLimit *int
+	exemplarFilter   exemplar.Filter
+	views            []observeCfg.MetricView
+	exportInterval   time.Duration
+	exportTimeout    time.Duration
+	temporality      sdkMetric.TemporalitySelector
+	// headers is nil unless set by WithExporterHeaders.
+	headers map[string]string
+}
+
+func newConfig(opts ...Option) *config {
+	cfg := &config{}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	return cfg
+}
+
+// WithReader registers an additional reader, e.g. a sdkMetric.ManualReader used by tests to collect the metrics on
+// demand.
+func WithReader(r sdkMetric.Reader) Option {
+	return func(c *config) {
+		c.readers = append(c.readers, r)
+	}
+}
+
+// WithPrometheus registers a Prometheus pull reader alongside the periodic OTLP reader. The metrics are then
+// served by PrometheusHandler.
+func WithPrometheus() Option {
+	return func(c *config) {
+		c.prometheus = true
+	}
+}
+
+// WithExporterHeaders sets the headers sent by the exporter created by Initialize, instead of those of the
+// environment variable `OTEL_EXPORTER_OTLP_HEADERS`. An empty map sends no header, e.g. when they are sent by the
+// per-RPC credentials of the connection, see observeCfg.ExporterCredentials.
+func WithExporterHeaders(headers map[string]string) Option {
+	return func(c *config) {
+		c.headers = headers
+	}
 }
 
 // IsInitialized returns true if the metrics have been successfully initialized.
@@ -47,8 +122,9 @@ func IsInitialized() bool {
 	return isInitialized
 }
 
-// Initialize sets up the metrics using the given grpc connection and namespace.
-func Initialize(ns string, conn *grpc.ClientConn) (func(context context.Context) error, error) {
+// Initialize sets up the metrics using the given grpc connection and namespace. Until it succeeds, the
+// instruments created by this package are no-ops.
+func Initialize(ns string, conn *grpc.ClientConn, opts ...Option) (func(context context.Context) error, error) {
 	if conn == nil {
 		return nil, errors.New("failed to create the metrics exporter: the grpc connection is nil")
 	}
@@ -56,72 +132,146 @@ func Initialize(ns string, conn *grpc.ClientConn) (func(context context.Context)
 		return nil, errors.New("failed to create the metrics exporter: the namespace is empty")
 	}
 
-	namespace = ns
 	ctx := context.Background()
-	exp, err := otlpmetricgrpc.New(ctx,
-		otlpmetricgrpc.WithInsecure(),
-		otlpmetricgrpc.WithGRPCConn(conn),
-	)
+	cfg := newConfig(opts...)
+	eOpts := []otlpmetricgrpc.Option{otlpmetricgrpc.WithGRPCConn(conn)}
+	if cfg.headers != nil {
+		eOpts = append(eOpts, otlpmetricgrpc.WithHeaders(cfg.headers))
+	}
+	exp, err := otlpmetricgrpc.New(ctx, eOpts...)
 	if err != nil {
 		isInitialized = false
 		return nil, err
 	}
-	exporter = exp
+	return initialize(ctx, ns, exp, cfg)
+}
+
+// InitializeHttp sets up the metrics using OTLP over HTTP, encoded as either protobuf or JSON, instead of gRPC.
+// Until it succeeds, the instruments created by this package are no-ops.
+func InitializeHttp(ns string, hOpts otlpHttp.Options, opts ...Option) (func(context context.Context) error, error) {
+	if len(ns) == 0 {
+		return nil, errors.New("failed to create the metrics exporter: the namespace is empty")
+	}
+
+	ctx := context.Background()
+	exp, err := otlpHttp.NewMetricExporter(ctx, hOpts)
+	if err != nil {
+		isInitialized = false
+		return nil, err
+	}
+	return initialize(ctx, ns, exp, newConfig(opts...))
+}
+
+// InitializeConsole sets up the metrics to pretty-print metric snapshots to w, or to os.Stdout if w is nil. It is
+// intended for local development, when no collector is running.
+func InitializeConsole(ns string, w io.Writer, opts ...Option) (func(context context.Context) error, error) {
+	if len(ns) == 0 {
+		return nil, errors.New("failed to create the metrics exporter: the namespace is empty")
+	}
+	isInitialized = false
+	exp, err := localExport.NewConsoleMetricExporter(w)
+	if err != nil {
+		return nil, err
+	}
+	return initialize(context.Background(), ns, exp, newConfig(opts...))
+}
 
-	res, err := resource.New(ctx, resource.WithAttributes(attribs...))
+// InitializeFile sets up the metrics to write each metric snapshot as a JSON line to a rotating file. It is intended
+// for local development, when no collector is running.
+func InitializeFile(ns string, fOpts localExport.FileOptions, opts ...Option) (func(context context.Context) error, error) {
+	if len(ns) == 0 {
+		return nil, errors.New("failed to create the metrics exporter: the namespace is empty")
+	}
+	isInitialized = false
+	exp, err := localExport.NewFileMetricExporter(fOpts)
 	if err != nil {
 		return nil, err
 	}
+	return initialize(context.Background(), ns, exp, newConfig(opts...))
+}
+
+// InitializePrometheus sets up the metrics using only a Prometheus pull reader; nothing is pushed to a collector.
+// The metrics are served by PrometheusHandler. Until it succeeds, the instruments created by this package are
+// no-ops.
+func InitializePrometheus(ns string, opts ...Option) (func(context context.Context) error, error) {
+	if len(ns) == 0 {
+		return nil, errors.New("failed to create the prometheus reader: the namespace is empty")
+	}
+	isInitialized = false
+	return initialize(context.Background(), ns, nil, newConfig(append(opts, WithPrometheus())...))
+}
 
-	reader = sdkMetric.NewPeriodicReader(exporter)
+// initialize creates the meter provider. exp may be nil if the metrics are only pulled by Prometheus.
+func initialize(ctx context.Context, ns string, exp sdkMetric.Exporter, cfg *config) (func(context context.Context) error, error) {
+	res, err := resources.New(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	namespace = ns
 	option := []sdkMetric.Option{
-		sdkMetric.WithReader(reader),
 		sdkMetric.WithResource(res),
 	}
+	if exp != nil {
+		if cfg.temporality != nil {
+			exp = temporalExporter{Exporter: exp, selector: cfg.temporality}
+		}
+		exp = cycleExporter{Exporter: exp}
+		exporter = exp
+		reader = sdkMetric.NewPeriodicReader(exporter, cfg.periodicReaderOptions()...)
+		option = append(option, sdkMetric.WithReader(reader))
+	}
+	if cfg.prometheus {
+		promReader, registry, err := newPrometheusReader()
+		if err != nil {
+			return nil, err
+		}
+		promRegistry.Store(registry)
+		option = append(option, sdkMetric.WithReader(promReader))
+	}
+	for _, r := range cfg.readers {
+		option = append(option, sdkMetric.WithReader(r))
+	}
+	if view := cfg.view(); view != nil {
+		option = append(option, sdkMetric.WithView(view))
+	}
+	if cfg.exemplarFilter != nil {
+		option = append(option, sdkMetric.WithExemplarFilter(cfg.exemplarFilter))
+	}
 
 	meterProvider := sdkMetric.NewMeterProvider(option...)
-	global.SetMeterProvider(meterProvider)
-	meter = global.Meter(
+	provider = meterProvider
+	otel.SetMeterProvider(meterProvider)
+	meter = otel.Meter(
 		fmt.Sprintf("%s.%s", namespace, observeCfg.ServiceName()),
 		metric.WithInstrumentationVersion(observeCfg.Version()),
-		metric.WithInstrumentationAttributes(attribs...),
+		metric.WithInstrumentationAttributes(resources.LegacyAttributes()...),
 	)
+	if err := initializeCardinality(cfg, exp); err != nil {
+		_ = meterProvider.Shutdown(ctx)
+		isInitialized = false
+		return nil, err
+	}
 
 	logger.Info("metrics initialized")
 	isInitialized = true
 	return meterProvider.Shutdown, nil
 }
 
-// NewUpDownCounter creates a new up/down counter using the given name and description.
+// NewUpDownCounter creates a new up/down counter using the given name and description. It is equivalent to
+// NewInt64UpDownCounter with the unit "1".
 func NewUpDownCounter(name, description string) (c metric.Int64UpDownCounter, err error) {
-	opt := []metric.Int64UpDownCounterOption{
-		metric.WithDescription(description),
-		metric.WithUnit("1"),
-	}
-	fname := fmt.Sprintf("%s.%s.%s", namespace, observeCfg.ServiceName(), name)
-	logger.Debug("new up/down counter created", logger.Attribute{Key: "name", Value: fname})
-	return meter.Int64UpDownCounter(fname, opt...)
+	return NewInt64UpDownCounter(name, InstrumentOptions{Description: description, Unit: "1"})
 }
 
-// NewCounter creates a new counter using the given name and description.
+// NewCounter creates a new counter using the given name and description. It is equivalent to NewInt64Counter
+// with the unit "1".
 func NewCounter(name, description string) (c metric.Int64Counter, err error) {
-	opt := []metric.Int64CounterOption{
-		metric.WithDescription(description),
-		metric.WithUnit("1"),
-	}
-	fname := fmt.Sprintf("%s.%s.%s", namespace, observeCfg.ServiceName(), name)
-	logger.Debug("new up/down counter created", logger.Attribute{Key: "name", Value: fname})
-	return meter.Int64Counter(fname, opt...)
+	return NewInt64Counter(name, InstrumentOptions{Description: description, Unit: "1"})
 }
 
-// NewHistogram creates a new histogram using the given name and description.
+// NewHistogram creates a new histogram using the given name and description. It is equivalent to
+// NewFloat64Histogram with the unit "1", using the default bucket boundaries.
 func NewHistogram(name, description string) (c metric.Float64Histogram, err error) {
-	opt := []metric.Float64HistogramOption{
-		metric.WithDescription(description),
-		metric.WithUnit("1"),
-	}
-
-	fname := fmt.Sprintf("%s.%s.%s", namespace, observeCfg.ServiceName(), name)
-	logger.Debug("new histogram created", logger.Attribute{Key: "name", Value: fname})
-	return meter.Float64Histogram(fname, opt...)
+	return NewFloat64Histogram(name, InstrumentOptions{Description: description, Unit: "1"})
 }
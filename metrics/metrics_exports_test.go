@@ -0,0 +1,10 @@
+package metrics
+
+import "context"
+
+var Reset = reset
+
+// ForceFlush collects and exports the metrics of the periodic reader.
+func ForceFlush(ctx context.Context) error {
+	return provider.ForceFlush(ctx)
+}
@@ -3,60 +3,222 @@ package metrics
 import (
 	"fmt"
 	"github.com/gin-gonic/gin"
-	"github.com/sirupsen/logrus"
+	"github.com/twistingmercury/observability/logger"
+	"github.com/twistingmercury/observability/observeCfg"
+	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/metric/noop"
+	"net/http"
+	"strconv"
 	"time"
 )
 
+// the instruments are no-ops until InitializeMetrics succeeds.
 var (
-	activeReq metric.Int64UpDownCounter
-	totalReq  metric.Int64Counter
-	avgReqDur metric.Float64Histogram
+	activeReq metric.Int64UpDownCounter = noop.Int64UpDownCounter{}
+	totalReq  metric.Int64Counter       = noop.Int64Counter{}
+	avgReqDur metric.Float64Histogram   = noop.Float64Histogram{}
+	reqSize   metric.Int64Histogram     = noop.Int64Histogram{}
+	respSize  metric.Int64Histogram     = noop.Int64Histogram{}
 )
 
 var middlewareInitialized bool
 
+// HttpAttribute is an attribute Middleware adds to its measurements.
+type HttpAttribute string
+
+const (
+	// RouteAttribute is the route template matched by the request, e.g. `/orders/:id`, rather than the raw path, so
+	// that the number of series stays bounded. Requests that match no route have the route `unmatched`.
+	RouteAttribute HttpAttribute = "http.route"
+	// MethodAttribute is the HTTP method of the request. Methods other than those defined by RFC 9110 and PATCH are
+	// recorded as `_OTHER`, so that arbitrary methods sent by clients do not create new series.
+	MethodAttribute HttpAttribute = "http.method"
+	// StatusCodeAttribute is the status code of the response. It is not added to the active requests.
+	StatusCodeAttribute HttpAttribute = "http.status_code"
+	// StatusClassAttribute is the class of the status code of the response, e.g. `2xx` or `5xx`. It is not added to
+	// the active requests.
+	StatusClassAttribute HttpAttribute = "http.status_class"
+)
+
+const (
+	unmatchedRoute = "unmatched"
+	otherMethod    = "_OTHER"
+)
+
+// knownMethods are the methods recorded as is by MethodAttribute.
+var knownMethods = map[string]bool{
+	http.MethodConnect: true,
+	http.MethodDelete:  true,
+	http.MethodGet:     true,
+	http.MethodHead:    true,
+	http.MethodOptions: true,
+	http.MethodPatch:   true,
+	http.MethodPost:    true,
+	http.MethodPut:     true,
+	http.MethodTrace:   true,
+}
+
+// DefaultHttpAttributes are the attributes Middleware adds to its measurements unless WithHttpAttributes is used.
+var DefaultHttpAttributes = []HttpAttribute{RouteAttribute, MethodAttribute, StatusCodeAttribute, StatusClassAttribute}
+
+// MiddlewareOption configures Middleware.
+type MiddlewareOption func(*middlewareConfig)
+
+type middlewareConfig struct {
+	attributes map[HttpAttribute]bool
+}
+
+// WithHttpAttributes sets the attributes Middleware adds to its measurements. Without attributes, the requests of
+// all routes are aggregated together.
+func WithHttpAttributes(attrs ...HttpAttribute) MiddlewareOption {
+	return func(c *middlewareConfig) {
+		c.attributes = make(map[HttpAttribute]bool, len(attrs))
+		for _, a := range attrs {
+			c.attributes[a] = true
+		}
+	}
+}
+
+// route returns the route template matched by the request, or its path if it matches no route.
+func route(ctx *gin.Context) string {
+	if r := ctx.FullPath(); len(r) > 0 {
+		return r
+	}
+	return ctx.Request.URL.Path
+}
+
+// requestAttributes returns the attributes known before the request is served.
+func (c *middlewareConfig) requestAttributes(ctx *gin.Context) []attribute.KeyValue {
+	var attrs []attribute.KeyValue
+	if c.attributes[RouteAttribute] {
+		route := ctx.FullPath()
+		if len(route) == 0 {
+			route = unmatchedRoute
+		}
+		attrs = append(attrs, attribute.String(string(RouteAttribute), route))
+	}
+	if c.attributes[MethodAttribute] {
+		method := ctx.Request.Method
+		if !knownMethods[method] {
+			method = otherMethod
+		}
+		attrs = append(attrs, attribute.String(string(MethodAttribute), method))
+	}
+	return attrs
+}
+
+// responseAttributes returns the request attributes, and the attributes of the response once it has been served.
+func (c *middlewareConfig) responseAttributes(ctx *gin.Context, reqAttrs []attribute.KeyValue) []attribute.KeyValue {
+	attrs := append([]attribute.KeyValue(nil), reqAttrs...)
+	status := ctx.Writer.Status()
+	if c.attributes[StatusCodeAttribute] {
+		attrs = append(attrs, attribute.String(string(StatusCodeAttribute), strconv.Itoa(status)))
+	}
+	if c.attributes[StatusClassAttribute] {
+		attrs = append(attrs, attribute.String(string(StatusClassAttribute), fmt.Sprintf("%dxx", status/100)))
+	}
+	return attrs
+}
+
 // InitializeMetrics initializes the metrics middleware
 func InitializeMetrics() error {
 	middlewareInitialized = false
-	cr, err := NewUpDownCounter("http.active_requests", "The current number of active requests being served.")
+	// the annotation unit adds no suffix to the Prometheus names, unlike the unit "1" named `_ratio`.
+	cr, err := NewInt64UpDownCounter("http.active_requests", InstrumentOptions{
+		Unit:        RequestUnit,
+		Description: "The current number of active requests being served.",
+	})
 	if err != nil {
 		return fmt.Errorf("failed to create active_requests up down counter: %w", err)
 	}
 
-	tr, err := NewCounter("http.total_requests_served", "The total number of requests served.")
+	tr, err := NewInt64Counter("http.total_requests_served", InstrumentOptions{
+		Unit:        RequestUnit,
+		Description: "The total number of requests served.",
+	})
 	if err != nil {
 		return fmt.Errorf("failed to create total_requests_served counter: %w", err)
 	}
 
-	ar, err := NewHistogram("http.request_duration_seconds", "The request duration in seconds.")
+	ar, err := NewFloat64Histogram("http.request_duration_seconds", InstrumentOptions{
+		Unit:        SecondsUnit,
+		Description: "The request duration in seconds.",
+	})
 	if err != nil {
 		return fmt.Errorf("failed to create request_duration_seconds histogram: %w", err)
 	}
+
+	rqs, err := NewInt64Histogram("http.request.size", InstrumentOptions{
+		Unit:        "By",
+		Description: "The size of the request bodies in bytes.",
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create request.size histogram: %w", err)
+	}
+
+	rss, err := NewInt64Histogram("http.response.size", InstrumentOptions{
+		Unit:        "By",
+		Description: "The size of the response bodies in bytes.",
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create response.size histogram: %w", err)
+	}
 	activeReq = cr
 	totalReq = tr
 	avgReqDur = ar
-	isInitialized = true
+	reqSize = rqs
+	respSize = rss
+	middlewareInitialized = true
 	return nil
 }
 
-// Middleware records metrics for the request.
-func Middleware() gin.HandlerFunc {
+// Middleware records metrics for the request: the active requests, the requests served, their duration, and the
+// size of the request and response bodies. The measurements carry the DefaultHttpAttributes, unless other attributes
+// are set by WithHttpAttributes. The requests of the observeCfg.HttpExcludedRoutes are not measured. If the metrics
+// have not been initialized, the measurements are no-ops and the request is served as usual.
+func Middleware(opts ...MiddlewareOption) gin.HandlerFunc {
 	if !IsInitialized() {
-		logrus.Fatal("metrics.Initialize() must be called before using the metrics middleware")
+		logger.Warn("metrics.Initialize() has not been invoked; request metrics will not be recorded")
 	}
 	if !middlewareInitialized {
-		logrus.Fatal("middleware.InitializeMetrics() must be called before before using the metrics middleware")
+		if err := InitializeMetrics(); err != nil {
+			logger.Error(err, "failed to initialize the metrics middleware; request metrics will not be recorded")
+		}
+	}
+
+	cfg := &middlewareConfig{}
+	WithHttpAttributes(DefaultHttpAttributes...)(cfg)
+	for _, opt := range opts {
+		opt(cfg)
 	}
 
 	return func(ctx *gin.Context) {
+		if observeCfg.HttpRouteExcluded(route(ctx)) {
+			ctx.Next()
+			return
+		}
+		rctx := ctx.Request.Context()
+		reqAttrs := cfg.requestAttributes(ctx)
+		active := metric.WithAttributes(reqAttrs...)
+
 		defer func(s time.Time) {
-			activeReq.Add(ctx.Request.Context(), -1)
-			avgReqDur.Record(ctx.Request.Context(), float64(time.Since(s).Microseconds()))
+			activeReq.Add(rctx, -1, active)
+
+			// the request context of the handlers holds the span of the request, even if the tracing middleware
+			// comes after this one, so that the measurements are sampled as exemplars of that span.
+			sctx := ctx.Request.Context()
+			served := metric.WithAttributes(cfg.responseAttributes(ctx, reqAttrs)...)
+			totalReq.Add(sctx, 1, served)
+			avgReqDur.Record(sctx, time.Since(s).Seconds(), served)
+			// the size of the request body is unknown, e.g. if it is chunked, when the content length is negative.
+			if ctx.Request.ContentLength >= 0 {
+				reqSize.Record(sctx, ctx.Request.ContentLength, served)
+			}
+			respSize.Record(sctx, int64(max(ctx.Writer.Size(), 0)), served)
 		}(time.Now())
 
-		activeReq.Add(ctx.Request.Context(), 1)
-		totalReq.Add(ctx.Request.Context(), 1)
+		activeReq.Add(rctx, 1, active)
 
 		ctx.Next()
 	}
@@ -1 +1,177 @@
 package metrics_test
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/twistingmercury/observability/logger"
+	"github.com/twistingmercury/observability/metrics"
+	"github.com/twistingmercury/observability/observeCfg"
+	"github.com/twistingmercury/observability/testTools"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+func serve(t *testing.T, h gin.HandlerFunc) int {
+	w := httptest.NewRecorder()
+	_, e := gin.CreateTestContext(w)
+	e.Use(h)
+	e.GET("/ok", func(c *gin.Context) { c.Status(http.StatusOK) })
+	req, _ := http.NewRequest(http.MethodGet, "/ok", nil)
+	e.ServeHTTP(w, req)
+	return w.Code
+}
+
+func TestMiddleware_NotInitialized(t *testing.T) {
+	logBuf := &bytes.Buffer{}
+	logger.Initialize(logBuf, logrus.DebugLevel)
+	metrics.Reset()
+
+	var fatal = false
+	orgExitFunc := logrus.StandardLogger().ExitFunc
+	logrus.StandardLogger().ExitFunc = func(int) { fatal = true }
+	defer func() {
+		logrus.StandardLogger().ExitFunc = orgExitFunc
+	}()
+
+	m := metrics.Middleware()
+	assert.False(t, fatal, "uninitialized metrics must not stop the service")
+	assert.Contains(t, logBuf.String(), "request metrics will not be recorded")
+	assert.Equal(t, http.StatusOK, serve(t, m))
+}
+
+func TestMiddleware(t *testing.T) {
+	logBuf := &bytes.Buffer{}
+	logger.Initialize(logBuf, logrus.DebugLevel)
+
+	ctx := context.Background()
+	conn, err := testTools.DialContext(ctx)
+	assert.NoError(t, err)
+	shutdown, err := metrics.Initialize("unit.test", conn)
+	assert.NoError(t, err)
+	defer func() {
+		_ = shutdown(ctx)
+		testTools.Reset(ctx)
+		metrics.Reset()
+	}()
+	assert.NoError(t, metrics.InitializeMetrics())
+
+	assert.Equal(t, http.StatusOK, serve(t, metrics.Middleware()))
+}
+
+func serveRoutes(t *testing.T, h gin.HandlerFunc, requests ...*http.Request) {
+	_, e := gin.CreateTestContext(httptest.NewRecorder())
+	e.Use(h)
+	e.POST("/orders/:id", func(c *gin.Context) { c.String(http.StatusCreated, "created") })
+	e.GET("/orders/:id", func(c *gin.Context) { c.Status(http.StatusInternalServerError) })
+	for _, req := range requests {
+		e.ServeHTTP(httptest.NewRecorder(), req)
+	}
+}
+
+// attributeSets returns the attributes of the data points of a counter, keyed by their encoded attribute set.
+func attributeSets(t *testing.T, m metricdata.Metrics) map[string]int64 {
+	sets := map[string]int64{}
+	for _, dp := range m.Data.(metricdata.Sum[int64]).DataPoints {
+		sets[dp.Attributes.Encoded(attribute.DefaultEncoder())] = dp.Value
+	}
+	return sets
+}
+
+func TestMiddleware_Attributes(t *testing.T) {
+	collected := collect(t)
+	require.NoError(t, metrics.InitializeMetrics())
+
+	serveRoutes(t, metrics.Middleware(),
+		httptest.NewRequest(http.MethodPost, "/orders/1", strings.NewReader("order-1")),
+		httptest.NewRequest(http.MethodPost, "/orders/2", strings.NewReader("order-2")),
+		httptest.NewRequest(http.MethodGet, "/orders/1", nil),
+		httptest.NewRequest(http.MethodGet, "/unknown", nil),
+	)
+
+	m := collected()
+	assert.Equal(t, map[string]int64{
+		"http.method=POST,http.route=/orders/:id,http.status_class=2xx,http.status_code=201": 2,
+		"http.method=GET,http.route=/orders/:id,http.status_class=5xx,http.status_code=500":  1,
+		"http.method=GET,http.route=unmatched,http.status_class=4xx,http.status_code=404":    1,
+	}, attributeSets(t, m[collectedPrefix+"http.total_requests_served"]))
+	assert.Equal(t, map[string]int64{
+		"http.method=POST,http.route=/orders/:id": 0,
+		"http.method=GET,http.route=/orders/:id":  0,
+		"http.method=GET,http.route=unmatched":    0,
+	}, attributeSets(t, m[collectedPrefix+"http.active_requests"]), "the status is not known while a request is active")
+
+	for _, name := range []string{"http.request.size", "http.response.size"} {
+		assert.Equal(t, "By", m[collectedPrefix+name].Unit)
+		for _, dp := range m[collectedPrefix+name].Data.(metricdata.Histogram[int64]).DataPoints {
+			if dp.Attributes.Encoded(attribute.DefaultEncoder()) == "http.method=POST,http.route=/orders/:id,http.status_class=2xx,http.status_code=201" {
+				assert.Equal(t, int64(14), dp.Sum, "%s is the sum of the body sizes", name)
+			}
+		}
+	}
+}
+
+func TestMiddleware_Methods(t *testing.T) {
+	collected := collect(t)
+	require.NoError(t, metrics.InitializeMetrics())
+
+	chunked := httptest.NewRequest(http.MethodPost, "/orders/1", strings.NewReader("order-1"))
+	chunked.ContentLength = -1
+	serveRoutes(t, metrics.Middleware(metrics.WithHttpAttributes(metrics.MethodAttribute)),
+		httptest.NewRequest(http.MethodGet, "/orders/1", nil),
+		httptest.NewRequest("PROPFIND", "/orders/1", nil),
+		httptest.NewRequest("FOO", "/orders/1", nil),
+		chunked,
+	)
+
+	m := collected()
+	assert.Equal(t, map[string]int64{
+		"http.method=GET":    1,
+		"http.method=POST":   1,
+		"http.method=_OTHER": 2,
+	}, attributeSets(t, m[collectedPrefix+"http.total_requests_served"]), "unknown methods are recorded as _OTHER")
+
+	for _, dp := range m[collectedPrefix+"http.request.size"].Data.(metricdata.Histogram[int64]).DataPoints {
+		assert.NotEqual(t, "http.method=POST", dp.Attributes.Encoded(attribute.DefaultEncoder()),
+			"the size of a request body of unknown length is not recorded")
+	}
+}
+
+func TestMiddleware_ExcludedRoutes(t *testing.T) {
+	t.Setenv(observeCfg.HttpExcludedRoutesEnvVar, "/orders/:id")
+	collected := collect(t)
+	require.NoError(t, metrics.InitializeMetrics())
+
+	serveRoutes(t, metrics.Middleware(metrics.WithHttpAttributes(metrics.RouteAttribute)),
+		httptest.NewRequest(http.MethodGet, "/orders/1", nil),
+		httptest.NewRequest(http.MethodGet, "/unknown", nil),
+	)
+
+	assert.Equal(t, map[string]int64{
+		"http.route=unmatched": 1,
+	}, attributeSets(t, collected()[collectedPrefix+"http.total_requests_served"]), "the excluded routes are not measured")
+}
+
+func TestMiddleware_WithHttpAttributes(t *testing.T) {
+	collected := collect(t)
+	require.NoError(t, metrics.InitializeMetrics())
+
+	serveRoutes(t, metrics.Middleware(metrics.WithHttpAttributes(metrics.StatusClassAttribute)),
+		httptest.NewRequest(http.MethodPost, "/orders/1", nil),
+		httptest.NewRequest(http.MethodGet, "/orders/1", nil),
+		httptest.NewRequest(http.MethodGet, "/orders/2", nil),
+	)
+
+	assert.Equal(t, map[string]int64{
+		"http.status_class=2xx": 1,
+		"http.status_class=5xx": 2,
+	}, attributeSets(t, collected()[collectedPrefix+"http.total_requests_served"]))
+}
@@ -15,20 +15,20 @@ func TestMetrics(t *testing.T) {
 	logBuf := &bytes.Buffer{}
 	logger.Initialize(logBuf, logrus.DebugLevel)
 
-	t.Run("NewUpDownCounter_panics", func(t *testing.T) {
-		assert.Panics(t, func() {
-			_, _ = metrics.NewUpDownCounter("my-counter", "does stuff")
-		})
+	t.Run("NewUpDownCounter_noop_before_initialize", func(t *testing.T) {
+		c, err := metrics.NewUpDownCounter("my-counter", "does stuff")
+		assert.NoError(t, err)
+		assert.NotPanics(t, func() { c.Add(context.Background(), 1) })
 	})
-	t.Run("NewCounter_panics", func(t *testing.T) {
-		assert.Panics(t, func() {
-			_, _ = metrics.NewCounter("my-counter", "does stuff")
-		})
+	t.Run("NewCounter_noop_before_initialize", func(t *testing.T) {
+		c, err := metrics.NewCounter("my-counter", "does stuff")
+		assert.NoError(t, err)
+		assert.NotPanics(t, func() { c.Add(context.Background(), 1) })
 	})
-	t.Run("NewHistogram_panics", func(t *testing.T) {
-		assert.Panics(t, func() {
-			_, _ = metrics.NewHistogram("my-counter", "does stuff")
-		})
+	t.Run("NewHistogram_noop_before_initialize", func(t *testing.T) {
+		h, err := metrics.NewHistogram("my-counter", "does stuff")
+		assert.NoError(t, err)
+		assert.NotPanics(t, func() { h.Record(context.Background(), 1) })
 	})
 
 	ctx := context.Background()
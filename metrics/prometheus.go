@@ -0,0 +1,42 @@
+package metrics
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	otelPrometheus "go.opentelemetry.io/otel/exporters/prometheus"
+)
+
+// newPrometheusReader creates the Prometheus pull reader and the registry it is registered with. The exporter
+// maps the namespaced instrument names to valid Prometheus names: invalid characters, such as `.` and `-`, are
+// replaced by `_`, the unit is appended, e.g. `_seconds` or `_bytes`, and counters are suffixed with `_total`.
+func newPrometheusReader() (*otelPrometheus.Exporter, *prometheus.Registry, error) {
+	registry := prometheus.NewRegistry()
+	exp, err := otelPrometheus.New(
+		otelPrometheus.WithRegisterer(registry),
+		otelPrometheus.WithoutScopeInfo(),
+	)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create the prometheus reader: %w", err)
+	}
+	return exp, registry, nil
+}
+
+// PrometheusHandler returns a gin handler that serves the metrics in the Prometheus exposition format, or in the
+// OpenMetrics format, which includes the exemplars, when the scraper accepts it. It is typically registered as
+// `router.GET("/metrics", metrics.PrometheusHandler())`. The metrics are read when the request is served, so the
+// handler can be registered before the metrics are initialized; until a Prometheus reader is enabled by
+// WithPrometheus or InitializePrometheus it responds with 404 Not Found.
+func PrometheusHandler() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		registry := promRegistry.Load()
+		if registry == nil {
+			ctx.String(http.StatusNotFound, "prometheus metrics are not enabled")
+			return
+		}
+		promhttp.HandlerFor(registry, promhttp.HandlerOpts{EnableOpenMetrics: true}).ServeHTTP(ctx.Writer, ctx.Request)
+	}
+}
@@ -0,0 +1,153 @@
+package metrics_test
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/twistingmercury/observability/logger"
+	"github.com/twistingmercury/observability/metrics"
+	"github.com/twistingmercury/observability/observeCfg"
+	"github.com/twistingmercury/observability/testTools"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+func initializeConfig(t *testing.T) {
+	os.Setenv(observeCfg.LogLevelEnvVar, "debug")
+	os.Setenv(observeCfg.TraceEndpointEnvVar, "localhost:4317")
+	os.Setenv(observeCfg.MetricsEndpointEnvVar, "localhost:4317")
+	os.Setenv(observeCfg.EnvironEnvVar, "localhost")
+	t.Cleanup(func() {
+		os.Unsetenv(observeCfg.LogLevelEnvVar)
+		os.Unsetenv(observeCfg.TraceEndpointEnvVar)
+		os.Unsetenv(observeCfg.MetricsEndpointEnvVar)
+		os.Unsetenv(observeCfg.EnvironEnvVar)
+	})
+	require.NoError(t, observeCfg.Initialize("prom-tests", "2023-01-01T00:00:00.000", "0.0.0", "abcd0123"))
+}
+
+func scrape(t *testing.T) (int, string) {
+	w := httptest.NewRecorder()
+	_, e := gin.CreateTestContext(w)
+	e.GET("/metrics", metrics.PrometheusHandler())
+	req, _ := http.NewRequest(http.MethodGet, "/metrics", nil)
+	e.ServeHTTP(w, req)
+	return w.Code, w.Body.String()
+}
+
+func TestPrometheusHandler_NotEnabled(t *testing.T) {
+	logger.Initialize(&bytes.Buffer{}, logrus.DebugLevel)
+	metrics.Reset()
+
+	code, _ := scrape(t)
+	assert.Equal(t, http.StatusNotFound, code)
+}
+
+func TestInitializePrometheus(t *testing.T) {
+	logger.Initialize(&bytes.Buffer{}, logrus.DebugLevel)
+	initializeConfig(t)
+
+	ctx := context.Background()
+	shutdown, err := metrics.InitializePrometheus("unit.test")
+	require.NoError(t, err)
+	defer func() {
+		_ = shutdown(ctx)
+		metrics.Reset()
+	}()
+	assert.True(t, metrics.IsInitialized())
+
+	c, err := metrics.NewCounter("orders.placed", "The number of orders placed.")
+	require.NoError(t, err)
+	c.Add(ctx, 3)
+	h, err := metrics.NewHistogram("order.value", "The value of an order.")
+	require.NoError(t, err)
+	h.Record(ctx, 12.5)
+
+	code, body := scrape(t)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Contains(t, body, "# HELP unit_test_prom_tests_orders_placed_ratio_total The number of orders placed.")
+	assert.Contains(t, body, "# TYPE unit_test_prom_tests_orders_placed_ratio_total counter")
+	assert.Contains(t, body, "unit_test_prom_tests_orders_placed_ratio_total 3")
+	assert.Contains(t, body, "unit_test_prom_tests_order_value_ratio_count 1")
+	assert.Contains(t, body, `target_info{`)
+	assert.Contains(t, body, `service_name="prom-tests"`)
+
+	_, err = metrics.InitializePrometheus("")
+	assert.Error(t, err)
+}
+
+func TestPrometheusHandler_ConcurrentInitialize(t *testing.T) {
+	logger.Initialize(&bytes.Buffer{}, logrus.DebugLevel)
+	initializeConfig(t)
+	defer metrics.Reset()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 20; i++ {
+			code, _ := scrape(t)
+			assert.Contains(t, []int{http.StatusOK, http.StatusNotFound}, code)
+		}
+	}()
+	shutdown, err := metrics.InitializePrometheus("unit.test")
+	require.NoError(t, err)
+	<-done
+	assert.NoError(t, shutdown(context.Background()))
+}
+
+func TestInitialize_WithPrometheus(t *testing.T) {
+	logger.Initialize(&bytes.Buffer{}, logrus.DebugLevel)
+	initializeConfig(t)
+
+	ctx := context.Background()
+	conn, err := testTools.DialContext(ctx)
+	require.NoError(t, err)
+	shutdown, err := metrics.Initialize("unit.test", conn, metrics.WithPrometheus())
+	require.NoError(t, err)
+	defer func() {
+		_ = shutdown(ctx)
+		testTools.Reset(ctx)
+		metrics.Reset()
+	}()
+	require.NoError(t, metrics.InitializeMetrics())
+
+	assert.Equal(t, http.StatusOK, serve(t, metrics.Middleware()))
+
+	code, body := scrape(t)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Contains(t, body, `unit_test_prom_tests_http_total_requests_served_total{http_method="GET",http_route="/ok",http_status_class="2xx",http_status_code="200"} 1`)
+	assert.Contains(t, body, `unit_test_prom_tests_http_active_requests{http_method="GET",http_route="/ok"} 0`)
+}
+
+func TestPrometheusHandler_Exemplars(t *testing.T) {
+	logger.Initialize(&bytes.Buffer{}, logrus.DebugLevel)
+	initializeConfig(t)
+
+	ctx := context.Background()
+	shutdown, err := metrics.InitializePrometheus("unit.test")
+	require.NoError(t, err)
+	defer func() {
+		_ = shutdown(ctx)
+		metrics.Reset()
+	}()
+	require.NoError(t, metrics.InitializeMetrics())
+	sc := serveTraced(t, metrics.Middleware(), sdktrace.AlwaysSample())
+
+	w := httptest.NewRecorder()
+	_, e := gin.CreateTestContext(w)
+	e.GET("/metrics", metrics.PrometheusHandler())
+	req, _ := http.NewRequest(http.MethodGet, "/metrics", nil)
+	req.Header.Set("Accept", "application/openmetrics-text; version=1.0.0")
+	e.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `trace_id="`+sc.TraceID().String()+`"`,
+		"the exemplars are served in the OpenMetrics format")
+}
@@ -0,0 +1,331 @@
+package metrics
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	rtmetrics "runtime/metrics"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/twistingmercury/observability/logger"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// the runtime/metrics samples read on each collection.
+const (
+	goroutinesSample  = "/sched/goroutines:goroutines"
+	heapObjectsSample = "/memory/classes/heap/objects:bytes"
+	heapUnusedSample  = "/memory/classes/heap/unused:bytes"
+	heapCountSample   = "/gc/heap/objects:objects"
+	gcCyclesSample    = "/gc/cycles/total:gc-cycles"
+	gcPausesSample    = "/gc/pauses:seconds"
+)
+
+const (
+	procStatPath = "/proc/self/stat"
+	procFdPath   = "/proc/self/fd"
+	procAuxvPath = "/proc/self/auxv"
+	// atClockTicks is the key of the auxiliary vector entry holding USER_HZ, the unit of the cpu times in
+	// /proc/self/stat.
+	atClockTicks = 17
+)
+
+// GCPauseBuckets are the bucket boundaries, in seconds, of the GC pause histogram. They range from 10µs to 100ms.
+var GCPauseBuckets = []float64{0.00001, 0.00005, 0.0001, 0.00025, 0.0005, 0.001, 0.0025, 0.005, 0.01, 0.05, 0.1}
+
+var (
+	runtimeMu sync.Mutex
+	// runtimeStarted is the registration of the runtime metrics callback, if they have been started.
+	runtimeStarted *runtimeRegistration
+)
+
+type runtimeRegistration struct {
+	reg metric.Registration
+}
+
+// runtimeCollector reads the runtime and process metrics when the metrics are collected.
+type runtimeCollector struct {
+	mu      sync.Mutex
+	samples []rtmetrics.Sample
+	index   map[string]int
+	// pauses are the counts of the GC pause histogram at the previous collection.
+	pauses []uint64
+	proc   bool
+	// clockTicks is the unit of the cpu times in /proc/self/stat, or zero if it could not be read.
+	clockTicks uint64
+
+	goroutines  metric.Int64ObservableGauge
+	heapAlloc   metric.Int64ObservableGauge
+	heapInuse   metric.Int64ObservableGauge
+	heapObjects metric.Int64ObservableGauge
+	gcCycles    metric.Int64ObservableCounter
+	gcPause     metric.Float64Histogram
+
+	cpuTime metric.Float64ObservableCounter
+	rss     metric.Int64ObservableGauge
+	fds     metric.Int64ObservableGauge
+}
+
+// StartRuntimeMetrics publishes the Go runtime metrics: the number of goroutines, the GC pauses, the heap and the
+// number of GC cycles, and, when /proc is available, the process cpu time, resident memory and open file
+// descriptors. They are named `namespace.service.runtime.go.*` and `namespace.service.process.*`, and share the
+// resource of the metrics.
+//
+// The values are read once per collection, by a single callback, rather than polled. The metrics must have been
+// initialized; the returned func stops publishing the runtime metrics.
+func StartRuntimeMetrics() (stop func(context.Context) error, err error) {
+	runtimeMu.Lock()
+	defer runtimeMu.Unlock()
+
+	if !IsInitialized() {
+		return nil, errors.New("failed to start the runtime metrics: the metrics have not been initialized")
+	}
+	if runtimeStarted != nil {
+		return nil, errors.New("failed to start the runtime metrics: they have already been started")
+	}
+
+	c, err := newRuntimeCollector()
+	if err != nil {
+		return nil, fmt.Errorf("failed to start the runtime metrics: %w", err)
+	}
+
+	observables := []metric.Observable{c.goroutines, c.heapAlloc, c.heapInuse, c.heapObjects, c.gcCycles}
+	if c.proc {
+		observables = append(observables, c.rss, c.fds)
+		if c.cpuTime != nil {
+			observables = append(observables, c.cpuTime)
+		}
+	} else {
+		logger.Info("/proc is not available; the process metrics will not be published")
+	}
+	reg, err := RegisterCallback(c.collect, observables...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start the runtime metrics: %w", err)
+	}
+	started := &runtimeRegistration{reg: reg}
+	runtimeStarted = started
+	logger.Debug("runtime metrics started")
+
+	return func(context.Context) error {
+		runtimeMu.Lock()
+		defer runtimeMu.Unlock()
+		return stopRuntimeMetrics(started)
+	}, nil
+}
+
+// stopRuntimeMetrics unregisters the callback of r, if it is still the registration of the runtime metrics.
+func stopRuntimeMetrics(r *runtimeRegistration) error {
+	if r == nil || r != runtimeStarted {
+		return nil
+	}
+	runtimeStarted = nil
+	return r.reg.Unregister()
+}
+
+func resetRuntimeMetrics() {
+	runtimeMu.Lock()
+	defer runtimeMu.Unlock()
+	_ = stopRuntimeMetrics(runtimeStarted)
+}
+
+func newRuntimeCollector() (c *runtimeCollector, err error) {
+	c = &runtimeCollector{index: make(map[string]int)}
+	for i, name := range []string{goroutinesSample, heapObjectsSample, heapUnusedSample, heapCountSample, gcCyclesSample, gcPausesSample} {
+		c.samples = append(c.samples, rtmetrics.Sample{Name: name})
+		c.index[name] = i
+	}
+	// the pauses of the GC cycles completed before the runtime metrics are started are not recorded.
+	rtmetrics.Read(c.samples)
+	c.pauses = c.pauseCounts()
+
+	if c.goroutines, err = NewInt64ObservableGauge("runtime.go.goroutines", InstrumentOptions{
+		Unit: "{goroutine}", Description: "The number of goroutines that currently exist.",
+	}); err != nil {
+		return nil, err
+	}
+	if c.heapAlloc, err = NewInt64ObservableGauge("runtime.go.mem.heap_alloc", InstrumentOptions{
+		Unit: "By", Description: "The bytes of allocated heap objects, including the unreachable objects not yet freed.",
+	}); err != nil {
+		return nil, err
+	}
+	if c.heapInuse, err = NewInt64ObservableGauge("runtime.go.mem.heap_inuse", InstrumentOptions{
+		Unit: "By", Description: "The bytes of the heap spans in use.",
+	}); err != nil {
+		return nil, err
+	}
+	if c.heapObjects, err = NewInt64ObservableGauge("runtime.go.mem.heap_objects", InstrumentOptions{
+		Unit: "{object}", Description: "The number of allocated heap objects.",
+	}); err != nil {
+		return nil, err
+	}
+	if c.gcCycles, err = NewInt64ObservableCounter("runtime.go.gc.count", InstrumentOptions{
+		Unit: "{gc_cycle}", Description: "The number of completed GC cycles.",
+	}); err != nil {
+		return nil, err
+	}
+	if c.gcPause, err = NewFloat64Histogram("runtime.go.gc.pause", InstrumentOptions{
+		Unit: SecondsUnit, Description: "The stop-the-world pauses of the GC cycles in seconds.", Buckets: GCPauseBuckets,
+	}); err != nil {
+		return nil, err
+	}
+
+	if _, statErr := os.Stat(procStatPath); statErr != nil {
+		return c, nil
+	}
+	c.proc = true
+	if c.clockTicks, err = readClockTicks(); err != nil {
+		logger.Info("the clock ticks of the process are unknown; the process cpu time will not be published",
+			logger.Attribute{Key: "error", Value: err.Error()})
+	} else if c.cpuTime, err = NewFloat64ObservableCounter("process.cpu.time", InstrumentOptions{
+		Unit: SecondsUnit, Description: "The user and system cpu time of the process in seconds.",
+	}); err != nil {
+		return nil, err
+	}
+	if c.rss, err = NewInt64ObservableGauge("process.memory.usage", InstrumentOptions{
+		Unit: "By", Description: "The resident memory of the process.",
+	}); err != nil {
+		return nil, err
+	}
+	if c.fds, err = NewInt64ObservableGauge("process.open_file_descriptor.count", InstrumentOptions{
+		Unit: "{file_descriptor}", Description: "The number of file descriptors the process has open.",
+	}); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// collect observes the runtime and process metrics. It is invoked on each collection.
+func (c *runtimeCollector) collect(ctx context.Context, o metric.Observer) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	rtmetrics.Read(c.samples)
+	o.ObserveInt64(c.goroutines, c.sample(goroutinesSample))
+	o.ObserveInt64(c.heapAlloc, c.sample(heapObjectsSample))
+	o.ObserveInt64(c.heapInuse, c.sample(heapObjectsSample)+c.sample(heapUnusedSample))
+	o.ObserveInt64(c.heapObjects, c.sample(heapCountSample))
+	o.ObserveInt64(c.gcCycles, c.sample(gcCyclesSample))
+	c.recordGCPauses(ctx)
+
+	if !c.proc {
+		return nil
+	}
+	var errs []error
+	if ticks, rss, err := readProcStat(); err != nil {
+		errs = append(errs, err)
+	} else {
+		if c.cpuTime != nil {
+			o.ObserveFloat64(c.cpuTime, float64(ticks)/float64(c.clockTicks))
+		}
+		o.ObserveInt64(c.rss, rss)
+	}
+	if fds, err := os.ReadDir(procFdPath); err != nil {
+		errs = append(errs, fmt.Errorf("failed to read %s: %w", procFdPath, err))
+	} else {
+		o.ObserveInt64(c.fds, int64(len(fds)))
+	}
+	return errors.Join(errs...)
+}
+
+func (c *runtimeCollector) sample(name string) int64 {
+	v := c.samples[c.index[name]].Value
+	if v.Kind() != rtmetrics.KindUint64 {
+		return 0
+	}
+	return int64(v.Uint64())
+}
+
+// pauseCounts returns the counts of the GC pause histogram, or nil if the runtime does not support it.
+func (c *runtimeCollector) pauseCounts() []uint64 {
+	v := c.samples[c.index[gcPausesSample]].Value
+	if v.Kind() != rtmetrics.KindFloat64Histogram {
+		return nil
+	}
+	return append([]uint64(nil), v.Float64Histogram().Counts...)
+}
+
+// recordGCPauses records the GC pauses counted by the runtime since the previous collection. The runtime only keeps
+// a histogram of the pauses, so each pause is recorded as the middle of its runtime bucket.
+func (c *runtimeCollector) recordGCPauses(ctx context.Context) {
+	v := c.samples[c.index[gcPausesSample]].Value
+	if v.Kind() != rtmetrics.KindFloat64Histogram {
+		return
+	}
+	h := v.Float64Histogram()
+	for i, count := range h.Counts {
+		var prev uint64
+		if i < len(c.pauses) {
+			prev = c.pauses[i]
+		}
+		for n := prev; n < count; n++ {
+			c.gcPause.Record(ctx, bucketValue(h.Buckets[i], h.Buckets[i+1]))
+		}
+	}
+	c.pauses = append(c.pauses[:0], h.Counts...)
+}
+
+// bucketValue returns the middle of the runtime histogram bucket [lower, upper), or its finite boundary if the
+// other is infinite.
+func bucketValue(lower, upper float64) float64 {
+	switch {
+	case math.IsInf(lower, -1):
+		return max(upper, 0)
+	case math.IsInf(upper, 1):
+		return lower
+	default:
+		return (lower + upper) / 2
+	}
+}
+
+// readClockTicks returns USER_HZ, the unit of the cpu times in /proc/self/stat, read from the auxiliary vector of
+// the process, a sequence of native-endian (key, value) words.
+func readClockTicks() (uint64, error) {
+	b, err := os.ReadFile(procAuxvPath)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read %s: %w", procAuxvPath, err)
+	}
+	size := strconv.IntSize / 8
+	word := func(b []byte) uint64 {
+		if size == 8 {
+			return binary.NativeEndian.Uint64(b)
+		}
+		return uint64(binary.NativeEndian.Uint32(b))
+	}
+	for i := 0; i+2*size <= len(b); i += 2 * size {
+		if word(b[i:]) == atClockTicks {
+			if ticks := word(b[i+size:]); ticks > 0 {
+				return ticks, nil
+			}
+			break
+		}
+	}
+	return 0, fmt.Errorf("failed to parse %s: the clock ticks are missing", procAuxvPath)
+}
+
+// readProcStat returns the user and system cpu time, in clock ticks, and the resident memory, in bytes, of the
+// process.
+func readProcStat() (ticks uint64, rss int64, err error) {
+	b, err := os.ReadFile(procStatPath)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to read %s: %w", procStatPath, err)
+	}
+	// the second field, the executable name, is in parentheses and may contain spaces; the fields are counted from
+	// the state, the third field.
+	s := string(b)
+	fields := strings.Fields(s[strings.LastIndexByte(s, ')')+1:])
+	if len(fields) < 22 {
+		return 0, 0, fmt.Errorf("failed to parse %s: unexpected format", procStatPath)
+	}
+	utime, uErr := strconv.ParseUint(fields[11], 10, 64)
+	stime, sErr := strconv.ParseUint(fields[12], 10, 64)
+	pages, rErr := strconv.ParseInt(fields[21], 10, 64)
+	if err = errors.Join(uErr, sErr, rErr); err != nil {
+		return 0, 0, fmt.Errorf("failed to parse %s: %w", procStatPath, err)
+	}
+	return utime + stime, pages * int64(os.Getpagesize()), nil
+}
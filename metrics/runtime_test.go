@@ -0,0 +1,55 @@
+package metrics_test
+
+import (
+	"context"
+	"os"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/twistingmercury/observability/metrics"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+func TestStartRuntimeMetrics_NotInitialized(t *testing.T) {
+	metrics.Reset()
+	_, err := metrics.StartRuntimeMetrics()
+	assert.Error(t, err)
+}
+
+func TestStartRuntimeMetrics(t *testing.T) {
+	collected := collect(t)
+	stop, err := metrics.StartRuntimeMetrics()
+	require.NoError(t, err)
+	_, err = metrics.StartRuntimeMetrics()
+	assert.Error(t, err, "the runtime metrics are started once")
+
+	m := collected()
+	gauge := func(name string) int64 {
+		require.Contains(t, m, collectedPrefix+name)
+		return m[collectedPrefix+name].Data.(metricdata.Gauge[int64]).DataPoints[0].Value
+	}
+	assert.Positive(t, gauge("runtime.go.goroutines"))
+	assert.Positive(t, gauge("runtime.go.mem.heap_alloc"))
+	assert.GreaterOrEqual(t, gauge("runtime.go.mem.heap_inuse"), gauge("runtime.go.mem.heap_alloc"))
+	assert.Positive(t, gauge("runtime.go.mem.heap_objects"))
+	assert.Contains(t, m, collectedPrefix+"runtime.go.gc.count")
+
+	runtime.GC()
+	runtime.GC()
+	m = collected()
+	pauses := m[collectedPrefix+"runtime.go.gc.pause"].Data.(metricdata.Histogram[float64]).DataPoints
+	require.Len(t, pauses, 1)
+	assert.GreaterOrEqual(t, pauses[0].Count, uint64(2), "the pauses of the completed GC cycles are recorded")
+	assert.Equal(t, metrics.GCPauseBuckets, pauses[0].Bounds)
+
+	if _, err := os.Stat("/proc/self/stat"); err == nil {
+		assert.Positive(t, gauge("process.memory.usage"))
+		assert.Positive(t, gauge("process.open_file_descriptor.count"))
+		assert.Contains(t, m, collectedPrefix+"process.cpu.time")
+	}
+
+	require.NoError(t, stop(context.Background()))
+	assert.NotContains(t, collected(), collectedPrefix+"runtime.go.goroutines", "stopped runtime metrics are not published")
+}
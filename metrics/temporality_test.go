@@ -0,0 +1,87 @@
+package metrics_test
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/twistingmercury/observability/logger"
+	"github.com/twistingmercury/observability/metrics"
+	"github.com/twistingmercury/observability/observeCfg"
+	sdkMetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+func TestTemporalitySelector(t *testing.T) {
+	tests := []struct {
+		name       string
+		preference string
+		overrides  map[string]string
+		delta      []sdkMetric.InstrumentKind
+	}{
+		{name: "cumulative", preference: observeCfg.CumulativeTemporality},
+		{name: "delta", preference: observeCfg.DeltaTemporality, delta: []sdkMetric.InstrumentKind{
+			sdkMetric.InstrumentKindCounter, sdkMetric.InstrumentKindHistogram, sdkMetric.InstrumentKindObservableCounter,
+		}},
+		{name: "lowmemory", preference: observeCfg.LowMemoryTemporality, delta: []sdkMetric.InstrumentKind{
+			sdkMetric.InstrumentKindCounter, sdkMetric.InstrumentKindHistogram,
+		}},
+		{name: "overrides", preference: observeCfg.DeltaTemporality,
+			overrides: map[string]string{observeCfg.HistogramKind: observeCfg.CumulativeTemporality, observeCfg.UpDownCounterKind: observeCfg.DeltaTemporality},
+			delta: []sdkMetric.InstrumentKind{
+				sdkMetric.InstrumentKindCounter, sdkMetric.InstrumentKindUpDownCounter, sdkMetric.InstrumentKindObservableCounter,
+			}},
+	}
+	kinds := []sdkMetric.InstrumentKind{
+		sdkMetric.InstrumentKindCounter, sdkMetric.InstrumentKindUpDownCounter, sdkMetric.InstrumentKindHistogram,
+		sdkMetric.InstrumentKindGauge, sdkMetric.InstrumentKindObservableCounter,
+		sdkMetric.InstrumentKindObservableUpDownCounter, sdkMetric.InstrumentKindObservableGauge,
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			selector, err := metrics.TemporalitySelector(tt.preference, tt.overrides)
+			require.NoError(t, err)
+			for _, k := range kinds {
+				want := metricdata.CumulativeTemporality
+				for _, d := range tt.delta {
+					if d == k {
+						want = metricdata.DeltaTemporality
+					}
+				}
+				assert.Equal(t, want, selector(k), k.String())
+			}
+		})
+	}
+
+	_, err := metrics.TemporalitySelector("invalid", nil)
+	assert.Error(t, err)
+	_, err = metrics.TemporalitySelector(observeCfg.DeltaTemporality, map[string]string{"invalid": observeCfg.DeltaTemporality})
+	assert.Error(t, err)
+}
+
+func TestWithTemporality(t *testing.T) {
+	logger.Initialize(&bytes.Buffer{}, logrus.DebugLevel)
+	initializeInstrumentsConfig(t)
+	defer metrics.Reset()
+
+	selector, err := metrics.TemporalitySelector(observeCfg.DeltaTemporality, nil)
+	require.NoError(t, err)
+	console := &bytes.Buffer{}
+	shutdown, err := metrics.InitializeConsole("unit.test", console,
+		metrics.WithTemporality(selector),
+		metrics.WithExportInterval(time.Hour),
+		metrics.WithExportTimeout(time.Second))
+	require.NoError(t, err)
+
+	c, err := metrics.NewCounter("delta.counter", "a delta counter")
+	require.NoError(t, err)
+	c.Add(context.Background(), 1)
+	assert.Empty(t, console.String(), "nothing is exported before the interval elapses")
+
+	require.NoError(t, shutdown(context.Background()))
+	assert.Contains(t, console.String(), `"Temporality": "DeltaTemporality"`)
+}
@@ -0,0 +1,125 @@
+package metrics
+
+import (
+	"path"
+	"sync"
+
+	"github.com/twistingmercury/observability/observeCfg"
+	"go.opentelemetry.io/otel/attribute"
+	sdkMetric "go.opentelemetry.io/otel/sdk/metric"
+)
+
+// SecondsUnit is the unit of the histograms measuring durations.
+const SecondsUnit = "s"
+
+// RequestUnit is the annotation unit of the instruments counting HTTP requests.
+const RequestUnit = "{request}"
+
+// DefaultLatencyBuckets are the bucket boundaries, in seconds, of the float64 histograms whose unit is SecondsUnit
+// and that are created without explicit buckets, e.g. the request duration recorded by Middleware. They range from
+// 5ms to 10s.
+var DefaultLatencyBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.075, 0.1, 0.25, 0.5, 0.75, 1, 2.5, 5, 7.5, 10}
+
+const (
+	// exponentialMaxSize and exponentialMaxScale are the defaults of the base-2 exponential histogram aggregation:
+	// 160 buckets, scaled down from the highest resolution until the recorded range fits.
+	exponentialMaxSize  = 160
+	exponentialMaxScale = 20
+)
+
+var (
+	// explicitBuckets are the names of the histograms created with InstrumentOptions.Buckets. The SDK does not pass
+	// the bucket boundaries of an instrument to the views, so they are recorded when the histogram is created.
+	explicitBucketsMu sync.RWMutex
+	explicitBuckets   = map[string]bool{}
+)
+
+func setExplicitBuckets(name string) {
+	explicitBucketsMu.Lock()
+	defer explicitBucketsMu.Unlock()
+	explicitBuckets[name] = true
+}
+
+func hasExplicitBuckets(name string) bool {
+	explicitBucketsMu.RLock()
+	defer explicitBucketsMu.RUnlock()
+	return explicitBuckets[name]
+}
+
+func resetExplicitBuckets() {
+	explicitBucketsMu.Lock()
+	defer explicitBucketsMu.Unlock()
+	explicitBuckets = map[string]bool{}
+}
+
+// WithLatencyBuckets overrides the bucket boundaries, in seconds, of every histogram whose unit is SecondsUnit,
+// including the buckets given when the histogram was created. It is ignored by WithExponentialHistograms.
+func WithLatencyBuckets(buckets ...float64) Option {
+	return func(c *config) {
+		c.latencyBuckets = append([]float64(nil), buckets...)
+	}
+}
+
+// WithExponentialHistograms aggregates the histograms as base-2 exponential histograms instead of explicit buckets.
+// Exponential histograms adjust their resolution to the recorded values, which gives accurate percentiles without
+// choosing the bucket boundaries up front. Histograms created with InstrumentOptions.Buckets keep their explicit
+// buckets.
+func WithExponentialHistograms() Option {
+	return func(c *config) {
+		c.exponential = true
+	}
+}
+
+// WithViews applies views to the instruments, to rename them, drop some of their attributes or disable them
+// entirely. The instrument names of the views exclude the namespace and service name.
+func WithViews(views ...observeCfg.MetricView) Option {
+	return func(c *config) {
+		c.views = append(c.views, views...)
+	}
+}
+
+// view returns a single view applying the histogram aggregation and the views of c, or nil if there is nothing to
+// apply. The SDK creates a stream per matching view, so they are combined rather than registered one by one.
+func (c *config) view() sdkMetric.View {
+	if !c.exponential && len(c.latencyBuckets) == 0 && len(c.views) == 0 {
+		return nil
+	}
+	return func(i sdkMetric.Instrument) (sdkMetric.Stream, bool) {
+		s := sdkMetric.Stream{Name: i.Name, Description: i.Description, Unit: i.Unit}
+		matched := false
+		if i.Kind == sdkMetric.InstrumentKindHistogram {
+			switch {
+			case c.exponential && !hasExplicitBuckets(i.Name):
+				s.Aggregation = sdkMetric.AggregationBase2ExponentialHistogram{
+					MaxSize:  exponentialMaxSize,
+					MaxScale: exponentialMaxScale,
+				}
+				matched = true
+			case len(c.latencyBuckets) > 0 && i.Unit == SecondsUnit:
+				s.Aggregation = sdkMetric.AggregationExplicitBucketHistogram{Boundaries: c.latencyBuckets}
+				matched = true
+			}
+		}
+
+		var drop []attribute.Key
+		for _, v := range c.views {
+			if ok, _ := path.Match(instrumentName(v.Instrument), i.Name); !ok {
+				continue
+			}
+			matched = true
+			if len(v.Rename) > 0 {
+				s.Name = instrumentName(v.Rename)
+			}
+			for _, k := range v.DropAttributes {
+				drop = append(drop, attribute.Key(k))
+			}
+			if v.Disabled {
+				s.Aggregation = sdkMetric.AggregationDrop{}
+			}
+		}
+		if len(drop) > 0 {
+			s.AttributeFilter = attribute.NewDenyKeysFilter(drop...)
+		}
+		return s, matched
+	}
+}
@@ -0,0 +1,87 @@
+package metrics_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/twistingmercury/observability/metrics"
+	"github.com/twistingmercury/observability/observeCfg"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+const durationName = collectedPrefix + "http.request_duration_seconds"
+
+func TestMiddleware_DurationInSeconds(t *testing.T) {
+	collected := collect(t)
+	require.NoError(t, metrics.InitializeMetrics())
+	assert.Equal(t, http.StatusOK, serve(t, metrics.Middleware()))
+
+	m := collected()[durationName]
+	assert.Equal(t, metrics.SecondsUnit, m.Unit)
+	dp := m.Data.(metricdata.Histogram[float64]).DataPoints[0]
+	assert.Equal(t, metrics.DefaultLatencyBuckets, dp.Bounds)
+	assert.Equal(t, uint64(1), dp.Count)
+	assert.Less(t, dp.Sum, 1.0, "the duration is recorded in seconds")
+	assert.Equal(t, uint64(1), dp.BucketCounts[0], "an instant request falls in the first bucket")
+}
+
+func TestWithLatencyBuckets(t *testing.T) {
+	collected := collect(t, metrics.WithLatencyBuckets(0.5, 1))
+	require.NoError(t, metrics.InitializeMetrics())
+	assert.Equal(t, http.StatusOK, serve(t, metrics.Middleware()))
+
+	other, err := metrics.NewFloat64Histogram("order.value", metrics.InstrumentOptions{Buckets: []float64{10, 20}})
+	require.NoError(t, err)
+	other.Record(context.Background(), 15)
+
+	m := collected()
+	assert.Equal(t, []float64{0.5, 1}, m[durationName].Data.(metricdata.Histogram[float64]).DataPoints[0].Bounds)
+	assert.Equal(t, []float64{10, 20}, m[collectedPrefix+"order.value"].Data.(metricdata.Histogram[float64]).DataPoints[0].Bounds,
+		"only the histograms measuring durations are affected")
+}
+
+func TestWithExponentialHistograms(t *testing.T) {
+	collected := collect(t, metrics.WithExponentialHistograms(), metrics.WithLatencyBuckets(0.5, 1))
+	require.NoError(t, metrics.InitializeMetrics())
+	assert.Equal(t, http.StatusOK, serve(t, metrics.Middleware()))
+
+	h, err := metrics.NewFloat64Histogram("order.value", metrics.InstrumentOptions{Buckets: []float64{10, 20}})
+	require.NoError(t, err)
+	h.Record(context.Background(), 15)
+
+	m := collected()
+	dp := m[durationName].Data.(metricdata.ExponentialHistogram[float64]).DataPoints
+	require.Len(t, dp, 1)
+	assert.Equal(t, uint64(1), dp[0].Count)
+
+	explicit := m[collectedPrefix+"order.value"].Data.(metricdata.Histogram[float64]).DataPoints
+	require.Len(t, explicit, 1)
+	assert.Equal(t, []float64{10, 20}, explicit[0].Bounds, "explicit buckets are kept")
+}
+
+func TestWithViews(t *testing.T) {
+	collected := collect(t, metrics.WithViews(
+		observeCfg.MetricView{Instrument: "http.request_duration_seconds", Rename: "http.server.duration", DropAttributes: []string{"http.status_code"}},
+		observeCfg.MetricView{Instrument: "http.*.size", Disabled: true},
+	), metrics.WithLatencyBuckets(0.5, 1))
+	require.NoError(t, metrics.InitializeMetrics())
+	assert.Equal(t, http.StatusOK, serve(t, metrics.Middleware()))
+
+	m := collected()
+	assert.NotContains(t, m, durationName)
+	assert.NotContains(t, m, collectedPrefix+"http.request.size", "the instrument is disabled")
+	assert.NotContains(t, m, collectedPrefix+"http.response.size", "the instrument is disabled")
+	assert.Contains(t, m, collectedPrefix+"http.total_requests_served")
+
+	require.Contains(t, m, collectedPrefix+"http.server.duration")
+	dp := m[collectedPrefix+"http.server.duration"].Data.(metricdata.Histogram[float64]).DataPoints[0]
+	assert.Equal(t, []float64{0.5, 1}, dp.Bounds, "the histogram aggregation still applies to a renamed instrument")
+	_, ok := dp.Attributes.Value(attribute.Key(metrics.StatusCodeAttribute))
+	assert.False(t, ok, "the status code is dropped")
+	_, ok = dp.Attributes.Value(attribute.Key(metrics.RouteAttribute))
+	assert.True(t, ok)
+}
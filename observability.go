@@ -2,40 +2,335 @@ package observability
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
 	"fmt"
-	"github.com/sirupsen/logrus"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/sirupsen/logrus"
+	"github.com/twistingmercury/observability/logger"
+	"github.com/twistingmercury/observability/observeCfg"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/credentials"
-	"time"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/encoding/gzip"
+	"google.golang.org/grpc/keepalive"
+	"google.golang.org/grpc/metadata"
+)
+
+const (
+	defaultWaitTimeout = 10 * time.Second
+
+	defaultRetryInitialBackoff    = 500 * time.Millisecond
+	defaultRetryMaxBackoff        = 5 * time.Second
+	defaultRetryBackoffMultiplier = 2.0
 )
 
-// GrpcConnectionOptions are the options for connecting to the OpenTelemetry collector via gRPC.
+// GrpcConnectionOptions are the options for connecting to the OpenTelemetry collector via gRPC. The options that are
+// not set keep the defaults of gRPC; GrpcConnectionOptionsFromConfig returns the options set by observeCfg.
 type GrpcConnectionOptions struct {
-	TransportCreds  credentials.TransportCredentials
-	WaitForConnect  bool
+	// URL is the target dialed, e.g. `collector:4317`.
+	URL string
+
+	// TransportCreds secure the connection. If nil, they are built from TLS; one of them must be set, e.g. to
+	// insecure.NewCredentials().
+	TransportCreds credentials.TransportCredentials
+	// TLS builds the transport credentials from certificate files when TransportCreds is nil.
+	TLS *GrpcTLSOptions
+	// PerRPCCreds, if set, add their metadata, e.g. the API key of the collector, to every RPC.
+	PerRPCCreds credentials.PerRPCCredentials
+	// Headers are static metadata sent with every export.
+	Headers map[string]string
+	// Compression is either observeCfg.GzipCompression or observeCfg.NoCompression, the default.
+	Compression string
+
+	// Keepalive, if set, pings the collector to detect broken connections, e.g. dropped by a load balancer.
+	Keepalive *keepalive.ClientParameters
+	// Retry, if set, is the retry policy of the exports, set by the service config of the connection.
+	Retry *GrpcRetryPolicy
+	// LoadBalancingPolicy is the load balancing policy of the connection, e.g. `round_robin`. It defaults to
+	// `pick_first`.
+	LoadBalancingPolicy string
+	// ProxyURL is the URL of the HTTP CONNECT proxy the connection goes through, e.g. `http://proxy:3128`. If empty,
+	// the proxy is read from `HTTPS_PROXY` and `NO_PROXY`.
+	ProxyURL string
+
+	// WaitForConnect blocks the dial until the connection is established, or WaitTimeout elapses.
+	WaitForConnect bool
+	// WaitTimeout is the maximum time the dial takes. It defaults to WaitTimeSeconds, or else 10 seconds.
+	WaitTimeout time.Duration
+	// WaitTimeSeconds is the maximum time the dial takes, in seconds, e.g. 10 rather than 10 * time.Second.
+	//
+	// Deprecated: use WaitTimeout, a time.Duration that is not multiplied by a second.
 	WaitTimeSeconds time.Duration
-	URL             string
+
+	// interceptors are added by Start to track the exports of a signal.
+	interceptors []grpc.UnaryClientInterceptor
+}
+
+// GrpcTLSOptions are the certificate files the TLS credentials of a gRPC connection are built from.
+type GrpcTLSOptions struct {
+	// CAFile is the CA certificate the certificate of the collector is verified with. If empty, the system roots are
+	// used.
+	CAFile string
+	// CertFile and KeyFile are the certificate and key presented to the collector, for mutual TLS.
+	CertFile string
+	KeyFile  string
+	// ServerName is the name the certificate of the collector is verified against, if it differs from the host of
+	// the URL.
+	ServerName string
+}
+
+// GrpcRetryPolicy is the policy used to retry the exports that fail with one of RetryableCodes. The zero values
+// default to 0.5s, 5s, 2, and codes.Unavailable.
+type GrpcRetryPolicy struct {
+	// MaxAttempts is the maximum number of attempts, the first one included. gRPC caps it to 5; less than 2
+	// disables the retries.
+	MaxAttempts       int
+	InitialBackoff    time.Duration
+	MaxBackoff        time.Duration
+	BackoffMultiplier float64
+	RetryableCodes    []codes.Code
+}
+
+// GrpcConnectionOptionsFromConfig returns the GrpcConnectionOptions for the given endpoint that are defined by
+// observeCfg. The connection uses TLS if the endpoint is an `https://` URL, or a certificate or server name is set,
+// unless `OTEL_EXPORTER_OTLP_INSECURE` is true; it is insecure otherwise. The exporter headers are sent by the
+// per-RPC credentials of the connection, see observeCfg.ExporterCredentials, but not over an insecure connection
+// unless `OTEL_EXPORTER_OTLP_INSECURE` is true.
+func GrpcConnectionOptionsFromConfig(endpoint string) GrpcConnectionOptions {
+	return grpcConnectionOptionsFromConfig(endpoint, nil)
+}
+
+// grpcConnectionOptionsFromConfig returns the GrpcConnectionOptions for the given endpoint, using creds instead of
+// the transport credentials defined by observeCfg if it is not nil.
+func grpcConnectionOptionsFromConfig(endpoint string, creds credentials.TransportCredentials) GrpcConnectionOptions {
+	opts := GrpcConnectionOptions{
+		URL:                 grpcTarget(endpoint),
+		Compression:         observeCfg.ExporterCompression(),
+		LoadBalancingPolicy: observeCfg.GrpcLBPolicy(),
+		ProxyURL:            observeCfg.GrpcProxy(),
+	}
+
+	tlsOpts := GrpcTLSOptions{
+		CAFile:     observeCfg.ExporterCertificate(),
+		CertFile:   observeCfg.ExporterClientCertificate(),
+		KeyFile:    observeCfg.ExporterClientKey(),
+		ServerName: observeCfg.GrpcServerName(),
+	}
+	switch {
+	case creds != nil:
+		opts.TransportCreds = creds
+	case !observeCfg.ExporterInsecure() && (strings.HasPrefix(endpoint, "https://") || tlsOpts != GrpcTLSOptions{}):
+		opts.TLS = &tlsOpts
+	default:
+		opts.TransportCreds = insecure.NewCredentials()
+	}
+
+	if len(observeCfg.ExporterHeaders()) > 0 {
+		// the secrets of the headers would be sent in plaintext over an insecure connection.
+		if opts.TLS == nil && opts.TransportCreds.Info().SecurityProtocol == "insecure" && !observeCfg.ExporterInsecure() {
+			logger.Warn("the exporter headers are not sent over an insecure connection; set OTEL_EXPORTER_OTLP_INSECURE "+
+				"to true to send them in plaintext", logger.Attribute{Key: "endpoint", Value: endpoint})
+		} else {
+			opts.PerRPCCreds = observeCfg.ExporterCredentials()
+		}
+	}
+	if t := observeCfg.GrpcKeepaliveTime(); t > 0 {
+		opts.Keepalive = &keepalive.ClientParameters{Time: t, Timeout: observeCfg.GrpcKeepaliveTimeout()}
+	}
+	if n := observeCfg.GrpcRetryMaxAttempts(); n > 1 {
+		opts.Retry = &GrpcRetryPolicy{MaxAttempts: n}
+	}
+	return opts
 }
 
 // NewGrpcConnection dials the OpenTelemetry collector endpoint.
 func NewGrpcConnection(opts GrpcConnectionOptions) (conn *grpc.ClientConn, err error) {
-	ctx, cancel := context.WithTimeout(context.Background(), opts.WaitTimeSeconds*time.Second)
+	return newGrpcConnection(context.Background(), opts)
+}
+
+// newGrpcConnection dials the OpenTelemetry collector endpoint using the given parent context.
+func newGrpcConnection(parent context.Context, opts GrpcConnectionOptions) (conn *grpc.ClientConn, err error) {
+	dOpts, err := dialOptions(opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to collector: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(parent, opts.waitTimeout())
 	defer cancel()
 
 	switch opts.WaitForConnect {
 	case true:
-		return newGrpcConnWithBlock(ctx, opts.URL, opts.TransportCreds)
+		return newGrpcConnWithBlock(ctx, opts.URL, dOpts...)
+	default:
+		return newGrpcConn(ctx, opts.URL, dOpts...)
+	}
+}
+
+func (opts GrpcConnectionOptions) waitTimeout() time.Duration {
+	switch {
+	case opts.WaitTimeout > 0:
+		return opts.WaitTimeout
+	case opts.WaitTimeSeconds > 0:
+		return opts.WaitTimeSeconds * time.Second
 	default:
-		return newGrpcConn(ctx, opts.URL, opts.TransportCreds)
+		return defaultWaitTimeout
+	}
+}
+
+// dialOptions returns the dial options set by opts.
+func dialOptions(opts GrpcConnectionOptions) ([]grpc.DialOption, error) {
+	creds := opts.TransportCreds
+	if creds == nil && opts.TLS != nil {
+		tlsCreds, err := opts.TLS.credentials()
+		if err != nil {
+			return nil, err
+		}
+		creds = tlsCreds
+	}
+	dOpts := []grpc.DialOption{grpc.WithTransportCredentials(creds)}
+
+	if opts.PerRPCCreds != nil {
+		dOpts = append(dOpts, grpc.WithPerRPCCredentials(opts.PerRPCCreds))
+	}
+	if len(opts.Headers) > 0 {
+		dOpts = append(dOpts, grpc.WithChainUnaryInterceptor(headersInterceptor(opts.Headers)))
+	}
+	if len(opts.interceptors) > 0 {
+		dOpts = append(dOpts, grpc.WithChainUnaryInterceptor(opts.interceptors...))
+	}
+	switch opts.Compression {
+	case observeCfg.GzipCompression:
+		dOpts = append(dOpts, grpc.WithDefaultCallOptions(grpc.UseCompressor(gzip.Name)))
+	case observeCfg.NoCompression, "":
+	default:
+		return nil, fmt.Errorf("invalid compression: %s; accepted values are `%s` and `%s`",
+			opts.Compression, observeCfg.GzipCompression, observeCfg.NoCompression)
+	}
+	if opts.Keepalive != nil {
+		dOpts = append(dOpts, grpc.WithKeepaliveParams(*opts.Keepalive))
+	}
+	sc, err := serviceConfig(opts)
+	if err != nil {
+		return nil, err
+	}
+	if len(sc) > 0 {
+		dOpts = append(dOpts, grpc.WithDefaultServiceConfig(sc))
+	}
+	if len(opts.ProxyURL) > 0 {
+		proxy, err := url.Parse(opts.ProxyURL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid proxy %s: %w", opts.ProxyURL, err)
+		}
+		dOpts = append(dOpts, grpc.WithContextDialer(proxyDialer(proxy)))
+	}
+	return dOpts, nil
+}
+
+// credentials returns the TLS credentials built from the certificate files.
+func (o GrpcTLSOptions) credentials() (credentials.TransportCredentials, error) {
+	cfg := &tls.Config{ServerName: o.ServerName, MinVersion: tls.VersionTLS12}
+	if len(o.CAFile) > 0 {
+		pem, err := os.ReadFile(o.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read the CA certificate: %w", err)
+		}
+		cfg.RootCAs = x509.NewCertPool()
+		if !cfg.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", o.CAFile)
+		}
+	}
+	if len(o.CertFile) > 0 || len(o.KeyFile) > 0 {
+		cert, err := tls.LoadX509KeyPair(o.CertFile, o.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load the client certificate: %w", err)
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+	return credentials.NewTLS(cfg), nil
+}
+
+// headersInterceptor adds the headers to the metadata of every unary RPC, which the exports are.
+func headersInterceptor(headers map[string]string) grpc.UnaryClientInterceptor {
+	kv := make([]string, 0, 2*len(headers))
+	for k, v := range headers {
+		kv = append(kv, k, v)
+	}
+	return func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, callOpts ...grpc.CallOption) error {
+		return invoker(metadata.AppendToOutgoingContext(ctx, kv...), method, req, reply, cc, callOpts...)
+	}
+}
+
+// serviceConfig returns the JSON service config setting the load balancing policy and the retry policy of opts,
+// empty if neither is set.
+func serviceConfig(opts GrpcConnectionOptions) (string, error) {
+	type retryPolicy struct {
+		MaxAttempts          int          `json:"maxAttempts"`
+		InitialBackoff       string       `json:"initialBackoff"`
+		MaxBackoff           string       `json:"maxBackoff"`
+		BackoffMultiplier    float64      `json:"backoffMultiplier"`
+		RetryableStatusCodes []codes.Code `json:"retryableStatusCodes"`
+	}
+	type methodConfig struct {
+		// an empty name applies the method config to every method.
+		Name        []struct{}  `json:"name"`
+		RetryPolicy retryPolicy `json:"retryPolicy"`
+	}
+	var sc struct {
+		LoadBalancingConfig []map[string]struct{} `json:"loadBalancingConfig,omitempty"`
+		MethodConfig        []methodConfig        `json:"methodConfig,omitempty"`
+	}
+
+	if len(opts.LoadBalancingPolicy) > 0 {
+		sc.LoadBalancingConfig = []map[string]struct{}{{opts.LoadBalancingPolicy: {}}}
+	}
+	if r := opts.Retry; r != nil && r.MaxAttempts > 1 {
+		p := retryPolicy{
+			MaxAttempts:          r.MaxAttempts,
+			InitialBackoff:       seconds(r.InitialBackoff, defaultRetryInitialBackoff),
+			MaxBackoff:           seconds(r.MaxBackoff, defaultRetryMaxBackoff),
+			BackoffMultiplier:    r.BackoffMultiplier,
+			RetryableStatusCodes: r.RetryableCodes,
+		}
+		if p.BackoffMultiplier <= 0 {
+			p.BackoffMultiplier = defaultRetryBackoffMultiplier
+		}
+		if len(p.RetryableStatusCodes) == 0 {
+			p.RetryableStatusCodes = []codes.Code{codes.Unavailable}
+		}
+		sc.MethodConfig = []methodConfig{{Name: []struct{}{{}}, RetryPolicy: p}}
+	}
+	if sc.LoadBalancingConfig == nil && sc.MethodConfig == nil {
+		return "", nil
+	}
+
+	b, err := json.Marshal(sc)
+	if err != nil {
+		return "", fmt.Errorf("failed to create the service config: %w", err)
+	}
+	return string(b), nil
+}
+
+// seconds formats d, or def if d is not positive, as a service config duration, e.g. `0.5s`.
+func seconds(d, def time.Duration) string {
+	if d <= 0 {
+		d = def
 	}
+	return strconv.FormatFloat(d.Seconds(), 'f', -1, 64) + "s"
 }
 
 // newGrpcConn dials the OpenTelemetry collector endpoint.
-func newGrpcConn(ctx context.Context, otelEP string, creds credentials.TransportCredentials) (conn *grpc.ClientConn, err error) {
+func newGrpcConn(ctx context.Context, otelEP string, dOpts ...grpc.DialOption) (conn *grpc.ClientConn, err error) {
 	logrus.Debugf("connecting to observability endpoint `grpc://%s`", otelEP)
 
-	conn, err = grpc.DialContext(ctx, otelEP, grpc.WithTransportCredentials(creds))
+	conn, err = grpc.DialContext(ctx, otelEP, dOpts...)
 
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to collector: %w", err)
@@ -46,10 +341,8 @@ func newGrpcConn(ctx context.Context, otelEP string, creds credentials.Transport
 }
 
 // newGrpcConnWithBlock dials the OpenTelemetry collector endpoint it will not return until a connection is established or the context is canceled.
-func newGrpcConnWithBlock(ctx context.Context, otelEP string, creds credentials.TransportCredentials) (conn *grpc.ClientConn, err error) {
-	conn, err = grpc.DialContext(ctx, otelEP,
-		grpc.WithTransportCredentials(creds),
-		grpc.WithBlock())
+func newGrpcConnWithBlock(ctx context.Context, otelEP string, dOpts ...grpc.DialOption) (conn *grpc.ClientConn, err error) {
+	conn, err = grpc.DialContext(ctx, otelEP, append(dOpts, grpc.WithBlock())...)
 
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to collector: %w", err)
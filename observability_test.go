@@ -1,10 +1,26 @@
 package observability_test
 
 import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"github.com/twistingmercury/observability"
+	"github.com/twistingmercury/observability/observeCfg"
+	"github.com/twistingmercury/observability/testTools"
+	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
+	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials/insecure"
-	"testing"
+	"google.golang.org/grpc/keepalive"
 )
 
 func TestNewGrpcConnectionWithBlockError(t *testing.T) {
@@ -28,3 +44,215 @@ func TestNewGrpcConnectionNoBlock(t *testing.T) {
 	_, err := observability.NewGrpcConnection(opts)
 	assert.NoError(t, err)
 }
+
+func TestNewGrpcConnection_WaitTimeout(t *testing.T) {
+	opts := observability.GrpcConnectionOptions{
+		TransportCreds: insecure.NewCredentials(),
+		WaitForConnect: true,
+		WaitTimeout:    200 * time.Millisecond,
+		URL:            "localhost:10101",
+	}
+	start := time.Now()
+	_, err := observability.NewGrpcConnection(opts)
+	assert.Error(t, err)
+	assert.Less(t, time.Since(start), 2*time.Second)
+}
+
+// exportSpan sends a single span to the collector using conn.
+func exportSpan(t *testing.T, conn *grpc.ClientConn) error {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	req := &coltracepb.ExportTraceServiceRequest{ResourceSpans: []*tracepb.ResourceSpans{{
+		ScopeSpans: []*tracepb.ScopeSpans{{Spans: []*tracepb.Span{{Name: "grpc-span"}}}},
+	}}}
+	_, err := coltracepb.NewTraceServiceClient(conn).Export(ctx, req)
+	return err
+}
+
+func TestNewGrpcConnection_Options(t *testing.T) {
+	collector, err := testTools.StartCollector()
+	require.NoError(t, err)
+	defer collector.Stop()
+
+	conn, err := observability.NewGrpcConnection(observability.GrpcConnectionOptions{
+		URL:                 collector.Addr(),
+		TransportCreds:      insecure.NewCredentials(),
+		PerRPCCreds:         observeCfg.NewInsecureCredentials(map[string]string{"api-key": "secret"}),
+		Headers:             map[string]string{"x-tenant": "a"},
+		Compression:         observeCfg.GzipCompression,
+		Keepalive:           &keepalive.ClientParameters{Time: time.Minute, Timeout: 10 * time.Second},
+		Retry:               &observability.GrpcRetryPolicy{MaxAttempts: 3},
+		LoadBalancingPolicy: "round_robin",
+		WaitForConnect:      true,
+		WaitTimeout:         5 * time.Second,
+	})
+	require.NoError(t, err)
+	defer conn.Close()
+
+	require.NoError(t, exportSpan(t, conn))
+	assert.Equal(t, 1, collector.Spans())
+	assert.Equal(t, []string{"secret"}, collector.Metadata("api-key"))
+	assert.Equal(t, []string{"a"}, collector.Metadata("x-tenant"))
+}
+
+func TestNewGrpcConnection_InvalidOptions(t *testing.T) {
+	tests := []struct {
+		name string
+		opts observability.GrpcConnectionOptions
+	}{
+		{"compression", observability.GrpcConnectionOptions{TransportCreds: insecure.NewCredentials(), Compression: "zstd"}},
+		{"lb_policy", observability.GrpcConnectionOptions{TransportCreds: insecure.NewCredentials(), LoadBalancingPolicy: "random"}},
+		{"ca_file", observability.GrpcConnectionOptions{TLS: &observability.GrpcTLSOptions{CAFile: "/does/not/exist"}}},
+		{"client_cert", observability.GrpcConnectionOptions{TLS: &observability.GrpcTLSOptions{CertFile: "/does/not/exist"}}},
+		{"proxy", observability.GrpcConnectionOptions{TransportCreds: insecure.NewCredentials(), ProxyURL: "http://[::1"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.opts.URL = "localhost:10101"
+			_, err := observability.NewGrpcConnection(tt.opts)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestNewGrpcConnection_TLS(t *testing.T) {
+	collector, files, err := testTools.StartTLSCollector(t.TempDir())
+	require.NoError(t, err)
+	defer collector.Stop()
+
+	tlsOpts := observability.GrpcTLSOptions{
+		CAFile:     files.CAFile,
+		CertFile:   files.CertFile,
+		KeyFile:    files.KeyFile,
+		ServerName: testTools.TLSServerName,
+	}
+	conn, err := observability.NewGrpcConnection(observability.GrpcConnectionOptions{URL: collector.Addr(), TLS: &tlsOpts})
+	require.NoError(t, err)
+	defer conn.Close()
+	assert.NoError(t, exportSpan(t, conn))
+	assert.Equal(t, 1, collector.Spans())
+
+	// the collector requires the client certificate.
+	noClientCert := observability.GrpcTLSOptions{CAFile: files.CAFile, ServerName: testTools.TLSServerName}
+	conn, err = observability.NewGrpcConnection(observability.GrpcConnectionOptions{URL: collector.Addr(), TLS: &noClientCert})
+	require.NoError(t, err)
+	defer conn.Close()
+	assert.Error(t, exportSpan(t, conn))
+}
+
+func TestGrpcConnectionOptionsFromConfig(t *testing.T) {
+	setupStart("localhost:4317", "localhost:4317")
+	defer tearDownStart()
+	os.Setenv(observeCfg.ExporterCompressionEnvVar, observeCfg.GzipCompression)
+	os.Setenv(observeCfg.ExporterHeadersEnvVar, "api-key=secret")
+	os.Setenv(observeCfg.ExporterCertificateEnvVar, "/etc/ssl/collector/ca.pem")
+	os.Setenv(observeCfg.GrpcServerNameEnvVar, "collector.internal")
+	os.Setenv(observeCfg.GrpcKeepaliveTimeEnvVar, "30000")
+	os.Setenv(observeCfg.GrpcRetryMaxAttemptsEnvVar, "4")
+	os.Setenv(observeCfg.GrpcLBPolicyEnvVar, "round_robin")
+	os.Setenv(observeCfg.GrpcProxyEnvVar, "http://proxy:3128")
+	assert.NoError(t, observeCfg.Initialize("unit-tests", "2023-01-01T00:00:00.000", "0.0.0", "abcd0123"))
+
+	opts := observability.GrpcConnectionOptionsFromConfig("https://collector:4317/")
+	assert.Equal(t, "collector:4317", opts.URL)
+	assert.Nil(t, opts.TransportCreds)
+	assert.Equal(t, &observability.GrpcTLSOptions{CAFile: "/etc/ssl/collector/ca.pem", ServerName: "collector.internal"}, opts.TLS)
+	assert.NotNil(t, opts.PerRPCCreds)
+	assert.Equal(t, observeCfg.GzipCompression, opts.Compression)
+	assert.Equal(t, &keepalive.ClientParameters{Time: 30 * time.Second}, opts.Keepalive)
+	assert.Equal(t, &observability.GrpcRetryPolicy{MaxAttempts: 4}, opts.Retry)
+	assert.Equal(t, "round_robin", opts.LoadBalancingPolicy)
+	assert.Equal(t, "http://proxy:3128", opts.ProxyURL)
+
+	os.Setenv(observeCfg.ExporterInsecureEnvVar, "true")
+	assert.NoError(t, observeCfg.Initialize("unit-tests", "2023-01-01T00:00:00.000", "0.0.0", "abcd0123"))
+	opts = observability.GrpcConnectionOptionsFromConfig("https://collector:4317")
+	assert.Nil(t, opts.TLS)
+	assert.NotNil(t, opts.TransportCreds)
+	assert.NotNil(t, opts.PerRPCCreds)
+}
+
+func TestGrpcConnectionOptionsFromConfig_InsecureCredentials(t *testing.T) {
+	setupStart("localhost:4317", "localhost:4317")
+	defer tearDownStart()
+	os.Setenv(observeCfg.ExporterHeadersEnvVar, "api-key=secret")
+	assert.NoError(t, observeCfg.Initialize("unit-tests", "2023-01-01T00:00:00.000", "0.0.0", "abcd0123"))
+
+	// the headers are not sent in plaintext unless OTEL_EXPORTER_OTLP_INSECURE is true.
+	opts := observability.GrpcConnectionOptionsFromConfig("collector:4317")
+	assert.Nil(t, opts.TLS)
+	assert.NotNil(t, opts.TransportCreds)
+	assert.Nil(t, opts.PerRPCCreds)
+
+	os.Setenv(observeCfg.ExporterInsecureEnvVar, "true")
+	assert.NoError(t, observeCfg.Initialize("unit-tests", "2023-01-01T00:00:00.000", "0.0.0", "abcd0123"))
+	opts = observability.GrpcConnectionOptionsFromConfig("collector:4317")
+	assert.NotNil(t, opts.PerRPCCreds)
+}
+
+// connectProxy is an HTTP CONNECT proxy that records the addresses it tunnels to.
+type connectProxy struct {
+	mu      sync.Mutex
+	targets []string
+}
+
+func (p *connectProxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodConnect {
+		http.Error(w, "only CONNECT is supported", http.StatusMethodNotAllowed)
+		return
+	}
+	p.mu.Lock()
+	p.targets = append(p.targets, r.Host)
+	p.mu.Unlock()
+
+	target, err := net.Dial("tcp", r.Host)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	client, _, err := w.(http.Hijacker).Hijack()
+	if err != nil {
+		target.Close()
+		return
+	}
+	if _, err = io.WriteString(client, "HTTP/1.1 200 Connection established\r\n\r\n"); err != nil {
+		client.Close()
+		target.Close()
+		return
+	}
+	go func() {
+		defer client.Close()
+		defer target.Close()
+		_, _ = io.Copy(target, client)
+	}()
+	go func() {
+		defer client.Close()
+		defer target.Close()
+		_, _ = io.Copy(client, target)
+	}()
+}
+
+func TestNewGrpcConnection_Proxy(t *testing.T) {
+	collector, err := testTools.StartCollector()
+	require.NoError(t, err)
+	defer collector.Stop()
+
+	proxy := &connectProxy{}
+	svr := httptest.NewServer(proxy)
+	defer svr.Close()
+
+	conn, err := observability.NewGrpcConnection(observability.GrpcConnectionOptions{
+		URL:            collector.Addr(),
+		TransportCreds: insecure.NewCredentials(),
+		ProxyURL:       svr.URL,
+	})
+	require.NoError(t, err)
+	defer conn.Close()
+
+	require.NoError(t, exportSpan(t, conn))
+	assert.Equal(t, 1, collector.Spans())
+	proxy.mu.Lock()
+	defer proxy.mu.Unlock()
+	assert.Equal(t, []string{collector.Addr()}, proxy.targets)
+}
@@ -0,0 +1,62 @@
+package observeCfg
+
+import (
+	"runtime/debug"
+)
+
+// readBuildInfo reads the build info embedded in the binary; it is replaced by the tests.
+var readBuildInfo = debug.ReadBuildInfo
+
+// buildInfo is the build information the Go toolchain embeds in a binary built with module support.
+type buildInfo struct {
+	version   string
+	commit    string
+	buildDate string
+	dirty     bool
+}
+
+// readVCSInfo returns the version of the main module, and the revision, time and modified state of the VCS
+// checkout the binary was built from. The values that are not known are empty; the version of a binary built from
+// a checkout without module version, `(devel)`, is ignored.
+func readVCSInfo() buildInfo {
+	bi, ok := readBuildInfo()
+	if !ok {
+		return buildInfo{}
+	}
+	var info buildInfo
+	if v := bi.Main.Version; v != "(devel)" {
+		info.version = v
+	}
+	for _, s := range bi.Settings {
+		switch s.Key {
+		case "vcs.revision":
+			info.commit = s.Value
+		case "vcs.time":
+			info.buildDate = s.Value
+		case "vcs.modified":
+			info.dirty = s.Value == "true"
+		}
+	}
+	return info
+}
+
+// withBuildInfo returns the build information given by the args, completed by the build info of the binary.
+func withBuildInfo(ver, commit, buildDate string) buildInfo {
+	info := readVCSInfo()
+	if len(ver) > 0 {
+		info.version = ver
+	}
+	if len(commit) > 0 {
+		info.commit = commit
+	}
+	if len(buildDate) > 0 {
+		info.buildDate = buildDate
+	}
+	return info
+}
+
+// VCSDirty returns true if the binary was built from a VCS checkout with uncommitted changes. It is read from the
+// `vcs.modified` setting of the build info.
+func VCSDirty() bool {
+	return current().VCSDirty
+}
@@ -0,0 +1,370 @@
+package observeCfg
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+)
+
+// Config holds the observability settings read by Load. See the package-level accessors, e.g. TraceEndpoint, for
+// the environment variable and the flag of each setting.
+type Config struct {
+	// build info
+	ServiceName string
+	BuildDate   string
+	Version     string
+	CommitHash  string
+	VCSDirty    bool
+	HostName    string
+
+	Environment     EnvironmentType
+	LogLevel        logrus.Level
+	TraceEndpoint   string
+	MetricsEndpoint string
+
+	// exporter config
+	ExporterProtocol    string
+	ExporterHeaders     map[string]string
+	ExporterCredentials *Credentials
+	ExporterCompression string
+	ExporterTimeout     time.Duration
+	ExporterInsecure    bool
+	ExporterCertificate string
+	// ExporterClientCertificate and ExporterClientKey are the paths of the client certificate and key, for mutual TLS.
+	ExporterClientCertificate string
+	ExporterClientKey         string
+
+	// gRPC connection config
+	GrpcServerName       string
+	GrpcKeepaliveTime    time.Duration
+	GrpcKeepaliveTimeout time.Duration
+	GrpcRetryMaxAttempts int
+	GrpcLBPolicy         string
+	GrpcProxy            string
+
+	MetricsExporters []string
+	TracesExporter   string
+
+	// local file exporters config
+	FileDir        string
+	FileMaxSizeMB  int
+	FileMaxBackups int
+
+	// metrics config
+	MetricsLatencyBuckets       []float64
+	MetricsHistogramAggregation string
+	MetricsCardinalityLimit     int
+	MetricsExemplarFilter       string
+	MetricsExportInterval       time.Duration
+	MetricsExportTimeout        time.Duration
+	MetricsTemporality          string
+	MetricsTemporalityOverrides map[string]string
+	MetricsViews                []MetricView
+
+	// traces config
+	TracesSampler    string
+	TracesSamplerArg float64
+	SDKDisabled      bool
+
+	// LogRedactedKeys are the keys of the log fields whose values are redacted, in lower case. HttpExcludedRoutes are
+	// the routes the HTTP middlewares neither log, trace nor measure.
+	LogRedactedKeys    []string
+	HttpExcludedRoutes []string
+
+	// ResourceLegacyAttributes is true if the service attributes are also set under their legacy keys.
+	ResourceLegacyAttributes bool
+
+	// ConfigFile is the path of the config file the settings were read from, if any.
+	ConfigFile string
+
+	// ShowHelp and ShowVersion are true if the `--help` and `--version` flags are set.
+	ShowHelp    bool
+	ShowVersion bool
+}
+
+// MetricsExporterEnabled returns true if exporter is one of the MetricsExporters.
+func (c *Config) MetricsExporterEnabled(exporter string) bool {
+	for _, e := range c.MetricsExporters {
+		if e == exporter {
+			return true
+		}
+	}
+	return false
+}
+
+// Options are the sources Load reads the settings from, and the build information of the service.
+type Options struct {
+	// FlagSet holds the flags added by RegisterFlags, and must already be parsed, e.g. by cobra. If nil, no flag is
+	// read.
+	FlagSet *pflag.FlagSet
+	// Viper provides the settings by environment variable name, e.g. `LOG_LEVEL`. Call its AutomaticEnv method for
+	// the environment variables to be read. If nil, the environment variables are read.
+	Viper *viper.Viper
+
+	// ServiceName is overridden by `OTEL_SERVICE_NAME`. BuildDate, Version and Commit default to the `vcs.time`,
+	// the module version and the `vcs.revision` of the build info of the binary, see debug.ReadBuildInfo.
+	ServiceName string
+	BuildDate   string
+	Version     string
+	Commit      string
+}
+
+// flagDef is a flag added by RegisterFlags.
+type flagDef struct {
+	name   string
+	usage  string
+	isBool bool
+}
+
+var flagDefs = []flagDef{
+	{name: environFlag, usage: "Set the environment in which the service is running [ localhost | dev | test | stage | prod ]"},
+	{name: versionFlag, usage: "Display current version information for the app", isBool: true},
+	{name: helpFlag, usage: "Display help information", isBool: true},
+	{name: logLevelFlag, usage: "Sets the log level [ debug | info | warn | error | fatal ]"},
+	{name: traceEndpointFlag, usage: "The host and port of the otel collector where traces are to be sent [<server>:<port>]"},
+	{name: metricsEndpointFlag, usage: "The host and port of the otel collector where metrics are to be sent [<server>:<port>]"},
+	{name: exporterProtocolFlag, usage: "The protocol used to send traces and metrics to the otel collector [ grpc | http/protobuf | http/json ]"},
+	{name: metricsExporterFlag, usage: "A comma separated list of the metrics exporters [ otlp | console | file | prometheus ]"},
+	{name: tracesExporterFlag, usage: "The exporter spans are sent to [ otlp | console | file ]"},
+	{name: configFlag, usage: "The path of a YAML, TOML or JSON file holding the observability settings"},
+	{name: infoFormatFlag, usage: "The format of the --version and --help output [ text | json ]"},
+}
+
+// RegisterFlags adds the observability flags to fs, e.g. the flags of a cobra command, `cmd.Flags()`. The flags
+// already defined in fs, such as the `--help` flag of cobra, are kept.
+func RegisterFlags(fs *pflag.FlagSet) {
+	for _, f := range flagDefs {
+		if fs.Lookup(f.name) != nil {
+			continue
+		}
+		if f.isBool {
+			fs.Bool(f.name, false, f.usage)
+		} else {
+			fs.String(f.name, "", f.usage)
+		}
+	}
+}
+
+// Load reads the settings from the flags and the viper of opts, and from the config file they give, if any. Unlike
+// Initialize, it neither parses the command line nor changes the settings returned by the package-level accessors.
+func Load(opts Options) (*Config, error) {
+	c, _, err := load(opts)
+	return c, err
+}
+
+func load(opts Options) (*Config, *loader, error) {
+	v := opts.Viper
+	if v == nil {
+		v = viper.New()
+		v.AutomaticEnv()
+	}
+	l := &loader{flags: opts.FlagSet, v: v}
+
+	svcName := opts.ServiceName
+	if n := l.lookup(ServiceNameEnvVar); len(n) > 0 {
+		svcName = n
+	}
+	bi := withBuildInfo(opts.Version, opts.Commit, opts.BuildDate)
+	switch {
+	case len(svcName) == 0:
+		return nil, nil, errors.New("arg svcName cannot be empty")
+	case len(bi.buildDate) == 0:
+		return nil, nil, errors.New("arg buildDate cannot be empty, and the build info has no `vcs.time`")
+	case len(bi.version) == 0:
+		return nil, nil, errors.New("arg ver cannot be empty, and the build info has no module version")
+	case len(bi.commit) == 0:
+		return nil, nil, errors.New("arg commit cannot be empty, and the build info has no `vcs.revision`")
+	}
+
+	if err := l.readConfigFile(); err != nil {
+		return nil, nil, err
+	}
+
+	hn, _ := os.Hostname()
+	c := &Config{
+		ServiceName: svcName,
+		BuildDate:   bi.buildDate,
+		Version:     bi.version,
+		CommitHash:  bi.commit,
+		VCSDirty:    bi.dirty,
+		HostName:    hn,
+		ConfigFile:  l.configFile,
+	}
+	if err := c.parse(l); err != nil {
+		return nil, nil, err
+	}
+	return c, l, nil
+}
+
+// parse reads the settings, applies their defaults and validates them. Every invalid setting is reported, rather
+// than the first one.
+func (c *Config) parse(l *loader) error {
+	levelStr := l.lookup(LogLevelEnvVar)
+	c.TraceEndpoint = l.lookup(TraceEndpointEnvVar)
+	c.MetricsEndpoint = l.lookup(MetricsEndpointEnvVar)
+	environStr := l.lookup(EnvironEnvVar)
+	c.ExporterProtocol = l.lookup(ExporterProtocolEnvVar)
+	headersStr := l.lookup(ExporterHeadersEnvVar)
+	c.ExporterCompression = l.lookup(ExporterCompressionEnvVar)
+	timeoutStr := l.lookup(ExporterTimeoutEnvVar)
+	insecureStr := l.lookup(ExporterInsecureEnvVar)
+	c.ExporterCertificate = l.lookup(ExporterCertificateEnvVar)
+	c.ExporterClientCertificate = l.lookup(ExporterClientCertEnvVar)
+	c.ExporterClientKey = l.lookup(ExporterClientKeyEnvVar)
+	c.GrpcServerName = l.lookup(GrpcServerNameEnvVar)
+	keepaliveTimeStr := l.lookup(GrpcKeepaliveTimeEnvVar)
+	keepaliveTimeoutStr := l.lookup(GrpcKeepaliveTimeoutEnvVar)
+	retryMaxAttemptsStr := l.lookup(GrpcRetryMaxAttemptsEnvVar)
+	c.GrpcLBPolicy = l.lookup(GrpcLBPolicyEnvVar)
+	c.GrpcProxy = l.lookup(GrpcProxyEnvVar)
+	metricsExportersStr := l.lookup(MetricsExporterEnvVar)
+	c.TracesExporter = l.lookup(TracesExporterEnvVar)
+	c.FileDir = l.lookup(FileDirEnvVar)
+	fileMaxSizeStr := l.lookup(FileMaxSizeEnvVar)
+	fileMaxBackupsStr := l.lookup(FileMaxBackupsEnvVar)
+	latencyBucketsStr := l.lookup(MetricsLatencyBucketsEnvVar)
+	c.MetricsHistogramAggregation = l.lookup(MetricsHistogramAggregationEnvVar)
+	cardinalityLimitStr := l.lookup(MetricsCardinalityLimitEnvVar)
+	c.MetricsExemplarFilter = l.lookup(MetricsExemplarFilterEnvVar)
+	exportIntervalStr := l.lookup(MetricsExportIntervalEnvVar)
+	exportTimeoutStr := l.lookup(MetricsExportTimeoutEnvVar)
+	c.MetricsTemporality = l.lookup(MetricsTemporalityEnvVar)
+	temporalityOverridesStr := l.lookup(MetricsTemporalityOverridesEnvVar)
+	viewsStr := l.lookup(MetricsViewsEnvVar)
+	c.TracesSampler = l.lookup(TracesSamplerEnvVar)
+	samplerArgStr := l.lookup(TracesSamplerArgEnvVar)
+	sdkDisabledStr := l.lookup(SDKDisabledEnvVar)
+	legacyAttributesStr := l.lookup(ResourceLegacyAttributesEnvVar)
+	c.LogRedactedKeys = parseList(strings.ToLower(l.lookup(LogRedactedKeysEnvVar)))
+	c.HttpExcludedRoutes = parseList(l.lookup(HttpExcludedRoutesEnvVar))
+
+	if len(environStr) == 0 {
+		environStr = resourceAttribute(l.lookup(ResourceAttributesEnvVar), deploymentEnvironmentKeys...)
+	}
+	if len(c.ExporterProtocol) == 0 {
+		c.ExporterProtocol = GrpcProtocol
+	}
+	if len(c.TracesSampler) == 0 {
+		c.TracesSampler = AlwaysOnSampler
+	}
+	if len(c.ExporterCompression) == 0 {
+		c.ExporterCompression = NoCompression
+	}
+	if len(strings.TrimSpace(metricsExportersStr)) == 0 {
+		metricsExportersStr = OtlpExporter
+	}
+	if len(c.TracesExporter) == 0 {
+		c.TracesExporter = OtlpExporter
+	}
+	if len(c.FileDir) == 0 {
+		c.FileDir = defaultFileDir
+	}
+	if len(c.MetricsHistogramAggregation) == 0 {
+		c.MetricsHistogramAggregation = ExplicitBucketHistogram
+	}
+	if len(c.MetricsExemplarFilter) == 0 {
+		c.MetricsExemplarFilter = TraceBasedExemplarFilter
+	}
+	if len(c.MetricsTemporality) == 0 {
+		c.MetricsTemporality = CumulativeTemporality
+	}
+
+	var errs []error
+	add := func(err error) {
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	var err error
+
+	c.ShowHelp, err = l.flagSet(helpFlag)
+	add(err)
+	c.ShowVersion, err = l.flagSet(versionFlag)
+	add(err)
+	// the endpoints are not required if the SDK is disabled.
+	c.SDKDisabled, err = parseBool("sdk disabled", sdkDisabledStr, false)
+	add(err)
+
+	if len(environStr) == 0 {
+		add(errors.New("environment is required"))
+	} else {
+		c.Environment, err = ParseEnvironment(environStr)
+		add(err)
+	}
+	if len(levelStr) == 0 {
+		add(errors.New("log level is required"))
+	} else {
+		c.LogLevel, err = parseLogLevel(levelStr)
+		add(err)
+	}
+
+	add(validateTracesExporter(c.TracesExporter))
+	if len(c.TraceEndpoint) == 0 && c.TracesExporter == OtlpExporter && !c.SDKDisabled {
+		add(errors.New("trace endpoint is required"))
+	}
+	add(validateEndpoint("trace endpoint", c.TraceEndpoint, c.ExporterProtocol))
+	c.MetricsExporters, err = parseMetricsExporters(metricsExportersStr)
+	add(err)
+	if len(c.MetricsEndpoint) == 0 && c.MetricsExporterEnabled(OtlpExporter) && !c.SDKDisabled {
+		add(errors.New("metrics endpoint is required"))
+	}
+	add(validateEndpoint("metrics endpoint", c.MetricsEndpoint, c.ExporterProtocol))
+	c.FileMaxSizeMB, err = parsePositiveInt("file max size", fileMaxSizeStr, defaultFileMaxSizeMB)
+	add(err)
+	c.FileMaxBackups, err = parsePositiveInt("file max backups", fileMaxBackupsStr, defaultFileMaxBackups)
+	add(err)
+
+	add(validateExporterProtocol(c.ExporterProtocol, c.ExporterCompression))
+	c.ExporterTimeout, err = parseMilliseconds("exporter timeout", timeoutStr, defaultExporterTimeout)
+	add(err)
+	c.ExporterHeaders, err = parseHeaders(headersStr)
+	add(err)
+	c.ExporterInsecure, err = parseBool("exporter insecure", insecureStr, false)
+	add(err)
+	if c.ExporterInsecure {
+		c.ExporterCredentials = NewInsecureCredentials(c.ExporterHeaders)
+	} else {
+		c.ExporterCredentials = NewCredentials(c.ExporterHeaders)
+	}
+	_, err = c.ExporterCredentials.Headers()
+	add(err)
+	add(validateClientCertificate(c.ExporterClientCertificate, c.ExporterClientKey))
+
+	c.GrpcKeepaliveTime, err = parseMilliseconds("grpc keepalive time", keepaliveTimeStr, 0)
+	add(err)
+	c.GrpcKeepaliveTimeout, err = parseMilliseconds("grpc keepalive timeout", keepaliveTimeoutStr, 0)
+	add(err)
+	c.GrpcRetryMaxAttempts, err = parseRetryMaxAttempts(retryMaxAttemptsStr)
+	add(err)
+	add(validateLBPolicy(c.GrpcLBPolicy))
+	add(validateProxy(c.GrpcProxy))
+
+	c.ResourceLegacyAttributes, err = parseBool("resource legacy attributes", legacyAttributesStr, true)
+	add(err)
+
+	add(validateSampler(c.TracesSampler))
+	c.TracesSamplerArg, err = parseSamplerArg(samplerArgStr)
+	add(err)
+
+	add(validateMetricsAggregation(c.MetricsHistogramAggregation, c.MetricsExemplarFilter))
+	c.MetricsLatencyBuckets, err = parseLatencyBuckets(latencyBucketsStr)
+	add(err)
+	c.MetricsCardinalityLimit, err = parseCardinalityLimit(cardinalityLimitStr)
+	add(err)
+	c.MetricsExportInterval, err = parseMilliseconds("metrics export interval", exportIntervalStr, defaultMetricsExportInterval)
+	add(err)
+	c.MetricsExportTimeout, err = parseMilliseconds("metrics export timeout", exportTimeoutStr, defaultMetricsExportTimeout)
+	add(err)
+	add(validateTemporality(c.MetricsTemporality))
+	c.MetricsTemporalityOverrides, err = parseTemporalityOverrides(temporalityOverridesStr)
+	add(err)
+	c.MetricsViews, err = parseViews(viewsStr)
+	add(err)
+
+	return errors.Join(errs...)
+}
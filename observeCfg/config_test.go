@@ -0,0 +1,193 @@
+package observeCfg_test
+
+import (
+	"runtime/debug"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/twistingmercury/observability/observeCfg"
+)
+
+func loadOptions(fs *pflag.FlagSet, v *viper.Viper) observeCfg.Options {
+	return observeCfg.Options{
+		FlagSet:     fs,
+		Viper:       v,
+		ServiceName: svcName,
+		BuildDate:   buildDate,
+		Version:     version,
+		Commit:      commitHash,
+	}
+}
+
+func TestLoad(t *testing.T) {
+	fs := pflag.NewFlagSet("svc", pflag.ContinueOnError)
+	fs.Bool("help", false, "the help flag of the service")
+	observeCfg.RegisterFlags(fs)
+	require.NoError(t, fs.Parse([]string{"--log-level", "warn", "--metrics-exporter", "prometheus", "--help"}))
+
+	v := viper.New()
+	v.Set(observeCfg.EnvironEnvVar, "dev")
+	v.Set(observeCfg.LogLevelEnvVar, "debug")
+	v.Set(observeCfg.TraceEndpointEnvVar, "collector:4317")
+	v.Set(observeCfg.MetricsCardinalityLimitEnvVar, "10")
+
+	before := observeCfg.TraceEndpoint()
+	c, err := observeCfg.Load(loadOptions(fs, v))
+	require.NoError(t, err)
+	assert.Equal(t, svcName, c.ServiceName)
+	assert.Equal(t, commitHash, c.CommitHash)
+	assert.Equal(t, observeCfg.Dev, c.Environment)
+	assert.Equal(t, logrus.WarnLevel, c.LogLevel, "the flags take precedence")
+	assert.Equal(t, "collector:4317", c.TraceEndpoint)
+	assert.Equal(t, []string{observeCfg.PrometheusExporter}, c.MetricsExporters)
+	assert.True(t, c.MetricsExporterEnabled(observeCfg.PrometheusExporter))
+	assert.Equal(t, 10, c.MetricsCardinalityLimit)
+	assert.Equal(t, observeCfg.AlwaysOnSampler, c.TracesSampler)
+	assert.True(t, c.ShowHelp)
+	assert.False(t, c.ShowVersion)
+	assert.Equal(t, before, observeCfg.TraceEndpoint(), "the package-level settings are unchanged")
+
+	v.Set(observeCfg.LogLevelEnvVar, "loud")
+	v.Set(observeCfg.MetricsEndpointEnvVar, "collector:4317")
+	_, err = observeCfg.Load(loadOptions(nil, v))
+	assert.ErrorContains(t, err, "invalid log level")
+
+	_, err = observeCfg.Load(loadOptions(nil, viper.New()))
+	assert.ErrorContains(t, err, "environment is required", "the environment variables are only read if asked to")
+}
+
+func TestLoad_Environment(t *testing.T) {
+	t.Setenv(observeCfg.EnvironEnvVar, "prod")
+	t.Setenv(observeCfg.LogLevelEnvVar, "info")
+	t.Setenv(observeCfg.OtlpEndpointEnvVar, "collector:4317")
+
+	c, err := observeCfg.Load(loadOptions(nil, nil))
+	require.NoError(t, err)
+	assert.Equal(t, observeCfg.Production, c.Environment)
+	assert.Equal(t, logrus.InfoLevel, c.LogLevel)
+	assert.Equal(t, "collector:4317", c.TraceEndpoint)
+	assert.Equal(t, "collector:4317", c.MetricsEndpoint)
+}
+
+func TestLoad_ValidationErrors(t *testing.T) {
+	v := viper.New()
+	v.Set(observeCfg.EnvironEnvVar, "prodtest")
+	v.Set(observeCfg.LogLevelEnvVar, "loud")
+	v.Set(observeCfg.TraceEndpointEnvVar, "collector")
+	v.Set(observeCfg.MetricsEndpointEnvVar, "ftp://collector:4317")
+	v.Set(observeCfg.MetricsCardinalityLimitEnvVar, "many")
+	v.Set(observeCfg.ExporterInsecureEnvVar, "maybe")
+	v.Set(observeCfg.SDKDisabledEnvVar, "yes")
+
+	_, err := observeCfg.Load(loadOptions(nil, v))
+	require.Error(t, err)
+	assert.ErrorContains(t, err, "invalid environment: prodtest")
+	assert.ErrorContains(t, err, "invalid log level: loud")
+	assert.ErrorContains(t, err, "invalid trace endpoint: collector")
+	assert.ErrorContains(t, err, "invalid metrics endpoint: ftp://collector:4317")
+	assert.ErrorContains(t, err, "invalid cardinality limit: many")
+	assert.ErrorContains(t, err, "invalid exporter insecure: maybe")
+	assert.ErrorContains(t, err, "invalid sdk disabled: yes")
+}
+
+func TestValidateEndpoint(t *testing.T) {
+	v := viper.New()
+	v.Set(observeCfg.EnvironEnvVar, "dev")
+	v.Set(observeCfg.LogLevelEnvVar, "info")
+	tests := map[string]bool{
+		"collector:4317":                 true,
+		"127.0.0.1:4317":                 true,
+		"[::1]:4317":                     true,
+		"http://collector:4318":          true,
+		"https://collector/otlp":         true,
+		"collector":                      false,
+		":4317":                          false,
+		"collector:http":                 false,
+		"collector:70000":                false,
+		"http://:4318":                   false,
+		"grpc://collector:4317":          false,
+		"https://collector:4318/v1/logs": true,
+		"dns:///collector:4317":          true,
+		"dns://8.8.8.8/collector:4317":   true,
+		"unix:///var/run/collector.sock": true,
+		"passthrough:///collector:4317":  true,
+		"unix:///":                       false,
+	}
+	for ep, valid := range tests {
+		v.Set(observeCfg.OtlpEndpointEnvVar, ep)
+		_, err := observeCfg.Load(loadOptions(nil, v))
+		assert.Equal(t, valid, err == nil, "%s: %v", ep, err)
+	}
+
+	v.Set(observeCfg.ExporterProtocolEnvVar, observeCfg.HttpProtobufProtocol)
+	v.Set(observeCfg.OtlpEndpointEnvVar, "dns:///collector:4318")
+	_, err := observeCfg.Load(loadOptions(nil, v))
+	assert.ErrorContains(t, err, "it must be a `<host>:<port>` or an http(s) URL", "the gRPC targets require grpc")
+}
+
+func TestParseEnvironment(t *testing.T) {
+	e, err := observeCfg.ParseEnvironment("PROD")
+	assert.NoError(t, err)
+	assert.Equal(t, observeCfg.Production, e)
+
+	for _, s := range []string{"de", "prodtest", "", "qa"} {
+		_, err = observeCfg.ParseEnvironment(s)
+		assert.Error(t, err, s)
+	}
+
+	observeCfg.RegisterEnvironment("qa", "dev")
+	e, err = observeCfg.ParseEnvironment("qa")
+	assert.NoError(t, err)
+	assert.Equal(t, observeCfg.EnvironmentType("qa"), e)
+	_, err = observeCfg.ParseEnvironment("perf")
+	assert.ErrorContains(t, err, "`qa`")
+}
+
+func TestLoad_BuildInfo(t *testing.T) {
+	restore := observeCfg.SetBuildInfo(&debug.BuildInfo{
+		Main: debug.Module{Path: "github.com/acme/svc", Version: "v1.2.3"},
+		Settings: []debug.BuildSetting{
+			{Key: "vcs", Value: "git"},
+			{Key: "vcs.revision", Value: "3f2c1a9"},
+			{Key: "vcs.time", Value: "2024-05-01T10:00:00Z"},
+			{Key: "vcs.modified", Value: "true"},
+		},
+	})
+	defer restore()
+
+	v := viper.New()
+	v.Set(observeCfg.EnvironEnvVar, "dev")
+	v.Set(observeCfg.LogLevelEnvVar, "info")
+	v.Set(observeCfg.OtlpEndpointEnvVar, "collector:4317")
+
+	c, err := observeCfg.Load(observeCfg.Options{Viper: v, ServiceName: svcName})
+	require.NoError(t, err)
+	assert.Equal(t, "v1.2.3", c.Version)
+	assert.Equal(t, "3f2c1a9", c.CommitHash)
+	assert.Equal(t, "2024-05-01T10:00:00Z", c.BuildDate)
+	assert.True(t, c.VCSDirty)
+	info := c.VersionInfo()
+	assert.True(t, info.Dirty)
+	assert.Equal(t, "github.com/acme/svc", info.Module)
+
+	c, err = observeCfg.Load(loadOptions(nil, v))
+	require.NoError(t, err)
+	assert.Equal(t, version, c.Version, "the args take precedence")
+	assert.Equal(t, commitHash, c.CommitHash)
+	assert.Equal(t, buildDate, c.BuildDate)
+
+	observeCfg.SetBuildInfo(&debug.BuildInfo{Main: debug.Module{Path: "github.com/acme/svc", Version: "(devel)"}})
+	_, err = observeCfg.Load(observeCfg.Options{Viper: v, ServiceName: svcName})
+	assert.ErrorContains(t, err, "arg buildDate cannot be empty")
+	_, err = observeCfg.Load(observeCfg.Options{Viper: v, ServiceName: svcName, BuildDate: buildDate})
+	assert.ErrorContains(t, err, "arg ver cannot be empty", "the version of a checkout is unknown")
+
+	observeCfg.SetBuildInfo(nil)
+	_, err = observeCfg.Load(observeCfg.Options{Viper: v, ServiceName: svcName, BuildDate: buildDate, Version: version})
+	assert.ErrorContains(t, err, "arg commit cannot be empty")
+}
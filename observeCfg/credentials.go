@@ -0,0 +1,206 @@
+package observeCfg
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/twistingmercury/observability/logger"
+)
+
+// secretRef matches the references the values of the exporter headers may contain: `${env:VAR}` and `${file:PATH}`.
+var secretRef = regexp.MustCompile(`\$\{([^:}]*):([^}]*)\}`)
+
+// Credentials resolves the exporter headers, e.g. the API key of the collector. Their values may refer to
+// environment variables, `${env:VAR}`, and to files such as Kubernetes secret mounts, `${file:/path}`, whose content
+// is trimmed of surrounding white space. The headers are resolved once, and again only when a file they read
+// changes.
+//
+// Credentials implements the gRPC credentials.PerRPCCredentials interface, so that the headers are sent as metadata
+// with every export. Unless created by NewInsecureCredentials, they require a secure connection, so that the secrets
+// are not sent in plaintext.
+type Credentials struct {
+	headers  map[string]string
+	insecure bool
+
+	mu    sync.Mutex
+	files map[string]*secretFile
+	// resolved are the headers as last resolved, and err the error of their resolution.
+	resolved map[string]string
+	err      error
+}
+
+// secretFile is the cached content of a file a header refers to.
+type secretFile struct {
+	modTime time.Time
+	size    int64
+	value   string
+}
+
+var (
+	secretFilesMu sync.Mutex
+	// secretFiles are the contents last read of the files the headers refer to, shared by all the Credentials, so
+	// that a file briefly missing while a secret is rotated keeps the value read before, even by the Credentials of
+	// a previous Initialize. unreadable holds the files that could not be read since, whose failure was logged.
+	secretFiles = make(map[string]*secretFile)
+	unreadable  = make(map[string]bool)
+)
+
+// NewCredentials returns the Credentials resolving headers, which require a secure connection.
+func NewCredentials(headers map[string]string) *Credentials {
+	c := &Credentials{headers: maps.Clone(headers), files: make(map[string]*secretFile)}
+	if c.headers == nil {
+		c.headers = make(map[string]string)
+	}
+	c.resolved, c.err = c.resolveHeaders()
+	return c
+}
+
+// NewInsecureCredentials returns the Credentials resolving headers, which may be sent over an insecure connection,
+// e.g. to a collector running on localhost. The secrets are then sent in plaintext.
+func NewInsecureCredentials(headers map[string]string) *Credentials {
+	c := NewCredentials(headers)
+	c.insecure = true
+	return c
+}
+
+// Headers returns the resolved headers. They are resolved again if a file they read changed, or if their previous
+// resolution failed. If a file cannot be read, e.g. while Kubernetes replaces a rotated secret, its last content is
+// used and the failure is logged; an error is returned only if no content of the file was ever read.
+func (c *Credentials) Headers() (map[string]string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.err != nil || c.filesChanged() {
+		c.resolved, c.err = c.resolveHeaders()
+	}
+	return maps.Clone(c.resolved), c.err
+}
+
+// cached returns the headers as last resolved, without checking whether the files they read changed.
+func (c *Credentials) cached() map[string]string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return maps.Clone(c.resolved)
+}
+
+// resolveHeaders resolves the references of every header.
+func (c *Credentials) resolveHeaders() (map[string]string, error) {
+	h := make(map[string]string, len(c.headers))
+	var errs []error
+	for k, v := range c.headers {
+		resolved, err := c.resolve(v)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("exporter header %s: %w", k, err))
+		}
+		h[k] = resolved
+	}
+	return h, errors.Join(errs...)
+}
+
+// filesChanged returns true if the modification time or the size of a file read by the headers changed.
+func (c *Credentials) filesChanged() bool {
+	for path, cached := range c.files {
+		fi, err := os.Stat(path)
+		if err != nil || !fi.ModTime().Equal(cached.modTime) || fi.Size() != cached.size {
+			return true
+		}
+	}
+	return false
+}
+
+// FromFiles returns true if a header is read from a file, and may therefore change.
+func (c *Credentials) FromFiles() bool {
+	for _, v := range c.headers {
+		for _, m := range secretRef.FindAllStringSubmatch(v, -1) {
+			if m[1] == "file" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// GetRequestMetadata returns the resolved headers; it implements credentials.PerRPCCredentials.
+func (c *Credentials) GetRequestMetadata(context.Context, ...string) (map[string]string, error) {
+	return c.Headers()
+}
+
+// RequireTransportSecurity returns true unless the Credentials were created by NewInsecureCredentials, in which case
+// gRPC refuses to send them over an insecure connection; it implements credentials.PerRPCCredentials.
+func (c *Credentials) RequireTransportSecurity() bool {
+	return !c.insecure
+}
+
+// resolve replaces the references in v by their values.
+func (c *Credentials) resolve(v string) (string, error) {
+	var errs []error
+	resolved := secretRef.ReplaceAllStringFunc(v, func(ref string) string {
+		m := secretRef.FindStringSubmatch(ref)
+		source, name := m[1], strings.TrimSpace(m[2])
+		switch source {
+		case "env":
+			value, ok := os.LookupEnv(name)
+			if !ok {
+				errs = append(errs, fmt.Errorf("the environment variable %s is not set", name))
+			}
+			return value
+		case "file":
+			value, err := c.readFile(name)
+			if err != nil {
+				errs = append(errs, err)
+			}
+			return value
+		default:
+			errs = append(errs, fmt.Errorf("invalid reference %s; accepted references are `${env:VAR}` and `${file:PATH}`", ref))
+			return ref
+		}
+	})
+	return resolved, errors.Join(errs...)
+}
+
+// readFile returns the trimmed content of the file at path, read again if its modification time or size changed.
+// Kubernetes updates a secret mount by replacing the file the path links to, which the stat follows. If the file
+// cannot be read, its last content is returned, and an error only if it was never read.
+func (c *Credentials) readFile(path string) (string, error) {
+	cached := c.files[path]
+	fi, err := os.Stat(path)
+	if err == nil && cached != nil && fi.ModTime().Equal(cached.modTime) && fi.Size() == cached.size {
+		return cached.value, nil
+	}
+	var b []byte
+	if err == nil {
+		b, err = os.ReadFile(path)
+	}
+
+	secretFilesMu.Lock()
+	defer secretFilesMu.Unlock()
+	if err != nil {
+		err = fmt.Errorf("failed to read the secret file: %w", err)
+		last := secretFiles[path]
+		if last == nil {
+			return "", err
+		}
+		if !unreadable[path] {
+			unreadable[path] = true
+			logger.Warn("the secret file of an exporter header cannot be read; its last content is sent",
+				logger.Attribute{Key: "file", Value: path}, logger.Attribute{Key: "error", Value: err.Error()})
+		}
+		c.files[path] = last
+		return last.value, nil
+	}
+	f := &secretFile{modTime: fi.ModTime(), size: fi.Size(), value: strings.TrimSpace(string(b))}
+	c.files[path], secretFiles[path] = f, f
+	delete(unreadable, path)
+	return f.value, nil
+}
+
+// ExporterCredentials returns the Credentials resolving the exporter headers, see ExporterHeaders.
+func ExporterCredentials() *Credentials {
+	return current().ExporterCredentials
+}
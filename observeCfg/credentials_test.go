@@ -0,0 +1,114 @@
+package observeCfg_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/twistingmercury/observability/observeCfg"
+)
+
+func TestCredentials(t *testing.T) {
+	t.Setenv("TEST_TENANT", "tenant-a")
+	secret := filepath.Join(t.TempDir(), "api-key")
+	require.NoError(t, os.WriteFile(secret, []byte("  secret\n"), 0o600))
+
+	creds := observeCfg.NewCredentials(map[string]string{
+		"api-key":  "${file:" + secret + "}",
+		"x-tenant": "${env:TEST_TENANT}",
+		"x-auth":   "Bearer ${env:TEST_TENANT}.${file:" + secret + "}",
+		"x-static": "static",
+	})
+	assert.True(t, creds.FromFiles())
+	assert.True(t, creds.RequireTransportSecurity())
+	assert.False(t, observeCfg.NewInsecureCredentials(map[string]string{"x-static": "static"}).RequireTransportSecurity())
+
+	h, err := creds.GetRequestMetadata(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		"api-key":  "secret",
+		"x-tenant": "tenant-a",
+		"x-auth":   "Bearer tenant-a.secret",
+		"x-static": "static",
+	}, h)
+
+	// the headers are cached until the secret is rotated.
+	t.Setenv("TEST_TENANT", "tenant-b")
+	h, err = creds.Headers()
+	require.NoError(t, err)
+	assert.Equal(t, "tenant-a", h["x-tenant"])
+	require.NoError(t, os.WriteFile(secret, []byte("rotated-secret\n"), 0o600))
+	h, err = creds.Headers()
+	require.NoError(t, err)
+	assert.Equal(t, "rotated-secret", h["api-key"])
+	assert.Equal(t, "tenant-b", h["x-tenant"])
+
+	// the secret can no longer be read, e.g. while it is rotated; its last content is kept.
+	require.NoError(t, os.Remove(secret))
+	h, err = creds.GetRequestMetadata(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "rotated-secret", h["api-key"])
+
+	// Credentials created again, e.g. by Initialize, keep it as well.
+	h, err = observeCfg.NewCredentials(map[string]string{"api-key": "${file:" + secret + "}"}).Headers()
+	require.NoError(t, err)
+	assert.Equal(t, "rotated-secret", h["api-key"])
+
+	require.NoError(t, os.WriteFile(secret, []byte("new-secret\n"), 0o600))
+	h, err = creds.Headers()
+	require.NoError(t, err)
+	assert.Equal(t, "new-secret", h["api-key"])
+}
+
+func TestCredentials_Errors(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		err   string
+	}{
+		{"unset_env", "${env:TEST_UNSET_API_KEY}", "the environment variable TEST_UNSET_API_KEY is not set"},
+		{"missing_file", "${file:/does/not/exist}", "failed to read the secret file"},
+		{"invalid_ref", "${vault:api-key}", "invalid reference ${vault:api-key}"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			creds := observeCfg.NewCredentials(map[string]string{"api-key": tt.value})
+			_, err := creds.Headers()
+			assert.ErrorContains(t, err, tt.err)
+		})
+	}
+
+	assert.False(t, observeCfg.NewCredentials(map[string]string{"api-key": "${env:API_KEY}"}).FromFiles())
+}
+
+func TestLoad_Credentials(t *testing.T) {
+	t.Setenv("TEST_API_KEY", "secret")
+
+	v := viper.New()
+	v.Set(observeCfg.EnvironEnvVar, "dev")
+	v.Set(observeCfg.LogLevelEnvVar, "info")
+	v.Set(observeCfg.OtlpEndpointEnvVar, "collector:4317")
+	v.Set(observeCfg.ExporterHeadersEnvVar, "api-key=${env:TEST_API_KEY}")
+
+	c, err := observeCfg.Load(loadOptions(nil, v))
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"api-key": "${env:TEST_API_KEY}"}, c.ExporterHeaders)
+	h, err := c.ExporterCredentials.Headers()
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"api-key": "secret"}, h)
+	assert.True(t, c.ExporterCredentials.RequireTransportSecurity())
+
+	v.Set(observeCfg.ExporterInsecureEnvVar, "true")
+	c, err = observeCfg.Load(loadOptions(nil, v))
+	require.NoError(t, err)
+	assert.False(t, c.ExporterCredentials.RequireTransportSecurity(), "the insecure connection is explicit")
+
+	v.Set(observeCfg.ExporterHeadersEnvVar, "api-key=${env:TEST_UNSET_API_KEY}")
+	_, err = observeCfg.Load(loadOptions(nil, v))
+	assert.ErrorContains(t, err, "exporter header api-key: the environment variable TEST_UNSET_API_KEY is not set")
+}
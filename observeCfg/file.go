@@ -0,0 +1,387 @@
+package observeCfg
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+)
+
+// valueKind is the type of the value of a setting in the config file.
+type valueKind int
+
+const (
+	stringValue valueKind = iota
+	boolValue
+	intValue
+	// listValue is a list, or a comma separated string.
+	listValue
+	// mapValue is a map, or a comma separated string of `key=value` pairs.
+	mapValue
+	// millisecondsValue is a number of milliseconds, or a duration such as `10s`.
+	millisecondsValue
+	// viewsValue is a list of views, or a string formatted as described by parseViews.
+	viewsValue
+	// floatValue is a number.
+	floatValue
+)
+
+func (k valueKind) String() string {
+	switch k {
+	case boolValue:
+		return "a boolean"
+	case intValue:
+		return "an integer"
+	case listValue:
+		return "a list"
+	case mapValue:
+		return "a map"
+	case millisecondsValue:
+		return "a number of milliseconds or a duration such as `10s`"
+	case viewsValue:
+		return "a list of views"
+	case floatValue:
+		return "a number"
+	default:
+		return "a string"
+	}
+}
+
+// setting maps a key of the config file to the environment variable of the same setting.
+type setting struct {
+	key    string
+	envVar string
+	kind   valueKind
+}
+
+// settings is the schema of the config file: every key it may contain.
+var settings = []setting{
+	{key: "environment", envVar: EnvironEnvVar, kind: stringValue},
+	{key: "log.level", envVar: LogLevelEnvVar, kind: stringValue},
+	{key: "log.redacted_keys", envVar: LogRedactedKeysEnvVar, kind: listValue},
+	{key: "http.excluded_routes", envVar: HttpExcludedRoutesEnvVar, kind: listValue},
+	{key: "traces.endpoint", envVar: TraceEndpointEnvVar, kind: stringValue},
+	{key: "traces.exporter", envVar: TracesExporterEnvVar, kind: stringValue},
+	{key: "traces.sampler", envVar: TracesSamplerEnvVar, kind: stringValue},
+	{key: "traces.sampler_arg", envVar: TracesSamplerArgEnvVar, kind: floatValue},
+	{key: "metrics.endpoint", envVar: MetricsEndpointEnvVar, kind: stringValue},
+	{key: "metrics.exporters", envVar: MetricsExporterEnvVar, kind: listValue},
+	{key: "metrics.latency_buckets", envVar: MetricsLatencyBucketsEnvVar, kind: listValue},
+	{key: "metrics.histogram_aggregation", envVar: MetricsHistogramAggregationEnvVar, kind: stringValue},
+	{key: "metrics.cardinality_limit", envVar: MetricsCardinalityLimitEnvVar, kind: intValue},
+	{key: "metrics.exemplar_filter", envVar: MetricsExemplarFilterEnvVar, kind: stringValue},
+	{key: "metrics.export_interval", envVar: MetricsExportIntervalEnvVar, kind: millisecondsValue},
+	{key: "metrics.export_timeout", envVar: MetricsExportTimeoutEnvVar, kind: millisecondsValue},
+	{key: "metrics.temporality", envVar: MetricsTemporalityEnvVar, kind: stringValue},
+	{key: "metrics.temporality_overrides", envVar: MetricsTemporalityOverridesEnvVar, kind: mapValue},
+	{key: "metrics.views", envVar: MetricsViewsEnvVar, kind: viewsValue},
+	{key: "exporter.protocol", envVar: ExporterProtocolEnvVar, kind: stringValue},
+	{key: "exporter.headers", envVar: ExporterHeadersEnvVar, kind: mapValue},
+	{key: "exporter.compression", envVar: ExporterCompressionEnvVar, kind: stringValue},
+	{key: "exporter.timeout", envVar: ExporterTimeoutEnvVar, kind: millisecondsValue},
+	{key: "exporter.insecure", envVar: ExporterInsecureEnvVar, kind: boolValue},
+	{key: "exporter.certificate", envVar: ExporterCertificateEnvVar, kind: stringValue},
+	{key: "exporter.client_certificate", envVar: ExporterClientCertEnvVar, kind: stringValue},
+	{key: "exporter.client_key", envVar: ExporterClientKeyEnvVar, kind: stringValue},
+	{key: "grpc.server_name", envVar: GrpcServerNameEnvVar, kind: stringValue},
+	{key: "grpc.keepalive_time", envVar: GrpcKeepaliveTimeEnvVar, kind: millisecondsValue},
+	{key: "grpc.keepalive_timeout", envVar: GrpcKeepaliveTimeoutEnvVar, kind: millisecondsValue},
+	{key: "grpc.retry_max_attempts", envVar: GrpcRetryMaxAttemptsEnvVar, kind: intValue},
+	{key: "grpc.lb_policy", envVar: GrpcLBPolicyEnvVar, kind: stringValue},
+	{key: "grpc.proxy", envVar: GrpcProxyEnvVar, kind: stringValue},
+	{key: "file.dir", envVar: FileDirEnvVar, kind: stringValue},
+	{key: "file.max_size_mb", envVar: FileMaxSizeEnvVar, kind: intValue},
+	{key: "file.max_backups", envVar: FileMaxBackupsEnvVar, kind: intValue},
+	{key: "sdk.disabled", envVar: SDKDisabledEnvVar, kind: boolValue},
+	{key: "resource.legacy_attributes", envVar: ResourceLegacyAttributesEnvVar, kind: boolValue},
+}
+
+// loader reads the settings from flags, a viper providing the environment variables, and a config file.
+type loader struct {
+	flags *pflag.FlagSet
+	v     *viper.Viper
+
+	configFile string
+	// fileValues are the values of the config file, formatted as their environment variables, by environment variable.
+	fileValues map[string]string
+}
+
+// envVarAliases are the environment variables, in order of precedence, of the settings that can be set by several:
+// the OpenTelemetry environment variables, and the former names that are kept as aliases.
+var envVarAliases = map[string][]string{
+	TraceEndpointEnvVar:   {OtlpTracesEndpointEnvVar, TraceEndpointEnvVar, OtlpEndpointEnvVar},
+	MetricsEndpointEnvVar: {OtlpMetricsEndpointEnvVar, MetricsEndpointEnvVar, OtlpEndpointEnvVar},
+}
+
+// lookup returns the value of a setting: the value of its flag or environment variables if any is set, or else the
+// value of the config file.
+func (l *loader) lookup(envVar string) string {
+	if f := l.changedFlag(flagNames[envVar]); f != nil {
+		return f.Value.String()
+	}
+	for _, n := range envVarNames(envVar) {
+		if l.v.IsSet(n) {
+			return l.v.GetString(n)
+		}
+	}
+	return l.fileValues[envVar]
+}
+
+// isSet returns true if a setting is set by its flag or environment variables, which take precedence over the
+// config file.
+func (l *loader) isSet(envVar string) bool {
+	if l.changedFlag(flagNames[envVar]) != nil {
+		return true
+	}
+	for _, n := range envVarNames(envVar) {
+		if l.v.IsSet(n) {
+			return true
+		}
+	}
+	return false
+}
+
+// changedFlag returns the flag name if it is set on the command line, or nil.
+func (l *loader) changedFlag(name string) *pflag.Flag {
+	if l.flags == nil || len(name) == 0 {
+		return nil
+	}
+	if f := l.flags.Lookup(name); f != nil && f.Changed {
+		return f
+	}
+	return nil
+}
+
+// flagSet returns true if the boolean flag name is set. The flag may have been defined by the service, in which case
+// its value is not necessarily a boolean.
+func (l *loader) flagSet(name string) (bool, error) {
+	f := l.changedFlag(name)
+	if f == nil {
+		return false, nil
+	}
+	return parseBool("--"+name+" flag", f.Value.String(), false)
+}
+
+func envVarNames(envVar string) []string {
+	if names, ok := envVarAliases[envVar]; ok {
+		return names
+	}
+	return []string{envVar}
+}
+
+// readConfigFile reads the config file given by the `--config` flag or the `OBSERVABILITY_CONFIG` environment
+// variable, if any. Its format, YAML, TOML or JSON, is given by its extension.
+func (l *loader) readConfigFile() error {
+	l.fileValues = nil
+	l.configFile = l.lookup(ConfigFileEnvVar)
+	if len(l.configFile) == 0 {
+		return nil
+	}
+
+	fv := viper.New()
+	fv.SetConfigFile(l.configFile)
+	if err := fv.ReadInConfig(); err != nil {
+		return fmt.Errorf("failed to read the config file %s: %w", l.configFile, err)
+	}
+	values, err := parseConfigFile(fv)
+	if err != nil {
+		return fmt.Errorf("invalid config file %s: %w", l.configFile, err)
+	}
+	l.fileValues = values
+	return nil
+}
+
+// parseConfigFile validates the content of a config file against the settings, and returns its values. Every error
+// is reported, rather than the first one.
+func parseConfigFile(fv *viper.Viper) (map[string]string, error) {
+	known := make(map[string]setting, len(settings))
+	for _, s := range settings {
+		known[s.key] = s
+	}
+
+	var errs []error
+	values := make(map[string]string)
+	seen := make(map[string]bool)
+	for _, key := range fv.AllKeys() {
+		s, ok := known[key]
+		if !ok {
+			// the keys of the map settings are flattened, e.g. `exporter.headers.api-key`.
+			if s, ok = mapSetting(key, known); !ok {
+				errs = append(errs, fmt.Errorf("%s: unknown setting", key))
+				continue
+			}
+		}
+		if seen[s.key] {
+			continue
+		}
+		seen[s.key] = true
+		v, err := formatValue(s.kind, fv.Get(s.key))
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", s.key, err))
+			continue
+		}
+		values[s.envVar] = v
+	}
+	return values, errors.Join(errs...)
+}
+
+func mapSetting(key string, known map[string]setting) (setting, bool) {
+	for k, s := range known {
+		if s.kind == mapValue && strings.HasPrefix(key, k+".") {
+			return s, true
+		}
+	}
+	return setting{}, false
+}
+
+// formatValue formats the value of a setting as the value of its environment variable.
+func formatValue(kind valueKind, v any) (string, error) {
+	invalid := fmt.Errorf("must be %s", kind)
+	switch kind {
+	case stringValue:
+		if s, ok := v.(string); ok {
+			return s, nil
+		}
+	case boolValue:
+		if b, ok := v.(bool); ok {
+			return strconv.FormatBool(b), nil
+		}
+	case intValue:
+		if i, ok := toInt(v); ok {
+			return strconv.Itoa(i), nil
+		}
+	case floatValue:
+		switch n := v.(type) {
+		case int, int64, float64:
+			return fmt.Sprint(n), nil
+		}
+	case listValue:
+		switch l := v.(type) {
+		case string:
+			return l, nil
+		case []any:
+			items := make([]string, 0, len(l))
+			for _, item := range l {
+				switch item.(type) {
+				case string, int, int64, float64:
+					items = append(items, fmt.Sprint(item))
+				default:
+					return "", invalid
+				}
+			}
+			return strings.Join(items, ","), nil
+		}
+	case mapValue:
+		switch m := v.(type) {
+		case string:
+			return m, nil
+		case map[string]any:
+			pairs := make([]string, 0, len(m))
+			for k, mv := range m {
+				s, ok := mv.(string)
+				if !ok {
+					return "", fmt.Errorf("%s: must be a string", k)
+				}
+				pairs = append(pairs, k+"="+url.QueryEscape(s))
+			}
+			sort.Strings(pairs)
+			return strings.Join(pairs, ","), nil
+		}
+	case millisecondsValue:
+		if i, ok := toInt(v); ok {
+			return strconv.Itoa(i), nil
+		}
+		if s, ok := v.(string); ok {
+			if d, err := time.ParseDuration(s); err == nil {
+				return strconv.FormatInt(d.Milliseconds(), 10), nil
+			}
+		}
+	case viewsValue:
+		switch l := v.(type) {
+		case string:
+			return l, nil
+		case []any:
+			return formatViews(l)
+		}
+	}
+	return "", invalid
+}
+
+// formatViews formats a list of views, each a map of `instrument`, `rename`, `drop_attributes` and `disable`, as
+// described by parseViews.
+func formatViews(l []any) (string, error) {
+	specs := make([]string, 0, len(l))
+	for i, item := range l {
+		m, ok := item.(map[string]any)
+		if !ok {
+			return "", fmt.Errorf("view %d: must be a map", i)
+		}
+		var inst string
+		var opts []string
+		for k, v := range m {
+			switch k {
+			case "instrument", "rename":
+				s, ok := v.(string)
+				if !ok {
+					return "", fmt.Errorf("view %d: %s: must be a string", i, k)
+				}
+				if k == "instrument" {
+					inst = s
+				} else {
+					opts = append(opts, "rename="+s)
+				}
+			case "drop_attributes":
+				drop, err := formatValue(listValue, v)
+				if err != nil {
+					return "", fmt.Errorf("view %d: %s: %w", i, k, err)
+				}
+				opts = append(opts, "drop="+strings.ReplaceAll(drop, ",", "|"))
+			case "disable":
+				b, ok := v.(bool)
+				if !ok {
+					return "", fmt.Errorf("view %d: %s: must be a boolean", i, k)
+				}
+				if b {
+					opts = append(opts, "disable")
+				}
+			default:
+				return "", fmt.Errorf("view %d: %s: unknown setting; accepted settings are `instrument`, `rename`, "+
+					"`drop_attributes`, and `disable`", i, k)
+			}
+		}
+		if len(inst) == 0 {
+			return "", fmt.Errorf("view %d: instrument is required", i)
+		}
+		sort.Strings(opts)
+		specs = append(specs, inst+":"+strings.Join(opts, ","))
+	}
+	return strings.Join(specs, ";"), nil
+}
+
+// toInt returns v as an int if it is an integer. The numbers of JSON files are decoded as float64.
+func toInt(v any) (int, bool) {
+	switch n := v.(type) {
+	case int:
+		return n, true
+	case int64:
+		return int(n), true
+	case float64:
+		if n == math.Trunc(n) {
+			return int(n), true
+		}
+	}
+	return 0, false
+}
+
+// ConfigFile returns the path of the config file the settings were read from, if any. It is set by the environment
+// variable `OBSERVABILITY_CONFIG` and can be overridden by the `--config` flag.
+func ConfigFile() string {
+	return current().ConfigFile
+}
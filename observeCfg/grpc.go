@@ -0,0 +1,98 @@
+package observeCfg
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+
+	"google.golang.org/grpc/balancer"
+	// the policies are registered by their packages, imported by grpc; round_robin is registered here as well so that
+	// the settings are validated without the connection.
+	_ "google.golang.org/grpc/balancer/roundrobin"
+)
+
+// validateClientCertificate checks that the client certificate and key of mutual TLS are either both set or both
+// unset.
+func validateClientCertificate(cert, key string) error {
+	if (len(cert) == 0) != (len(key) == 0) {
+		return fmt.Errorf("the exporter client certificate and key must be set together; set both `%s` and `%s`",
+			ExporterClientCertEnvVar, ExporterClientKeyEnvVar)
+	}
+	return nil
+}
+
+// parseRetryMaxAttempts parses the maximum number of attempts of a gRPC export, the first one included. 0, the
+// default, disables the retries of the connection; the exporters still retry on their own.
+func parseRetryMaxAttempts(s string) (int, error) {
+	if len(s) == 0 {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid grpc retry max attempts: %s; it must be a positive number", s)
+	}
+	return n, nil
+}
+
+// validateLBPolicy checks that the gRPC load balancing policy is registered, e.g. `pick_first` or `round_robin`.
+func validateLBPolicy(policy string) error {
+	if len(policy) == 0 || balancer.Get(policy) != nil {
+		return nil
+	}
+	return fmt.Errorf("invalid grpc load balancing policy: %s; accepted values are `pick_first` and `round_robin`", policy)
+}
+
+// validateProxy checks that the proxy of the gRPC connections is an http or https URL with a host.
+func validateProxy(proxy string) error {
+	if len(proxy) == 0 {
+		return nil
+	}
+	u, err := url.Parse(proxy)
+	if err != nil {
+		return fmt.Errorf("invalid grpc proxy %s: %w", proxy, err)
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || len(u.Host) == 0 {
+		return errors.New("invalid grpc proxy: it must be a URL such as `http://proxy:3128`")
+	}
+	return nil
+}
+
+// GrpcServerName returns the name the certificate of the collector is verified against, if it differs from the host
+// of the endpoint. It is set by the environment variable `GRPC_SERVER_NAME`.
+func GrpcServerName() string {
+	return current().GrpcServerName
+}
+
+// GrpcKeepaliveTime returns the time after which a gRPC connection without activity is pinged. It is set, in
+// milliseconds, by the environment variable `GRPC_KEEPALIVE_TIME`; 0, the default, disables the pings.
+func GrpcKeepaliveTime() time.Duration {
+	return current().GrpcKeepaliveTime
+}
+
+// GrpcKeepaliveTimeout returns how long a ping waits for its acknowledgement before the connection is closed. It is
+// set, in milliseconds, by the environment variable `GRPC_KEEPALIVE_TIMEOUT`, and defaults to that of gRPC, 20
+// seconds.
+func GrpcKeepaliveTimeout() time.Duration {
+	return current().GrpcKeepaliveTimeout
+}
+
+// GrpcRetryMaxAttempts returns the maximum number of attempts of a gRPC export that fails because the collector is
+// unavailable, the first one included. It is set by the environment variable `GRPC_RETRY_MAX_ATTEMPTS`; 0, the
+// default, disables the retries.
+func GrpcRetryMaxAttempts() int {
+	return current().GrpcRetryMaxAttempts
+}
+
+// GrpcLBPolicy returns the load balancing policy of the gRPC connections, e.g. `round_robin`. It is set by the
+// environment variable `GRPC_LB_POLICY`, and defaults to that of gRPC, `pick_first`.
+func GrpcLBPolicy() string {
+	return current().GrpcLBPolicy
+}
+
+// GrpcProxy returns the URL of the HTTP CONNECT proxy the gRPC connections go through. It is set by the environment
+// variable `GRPC_PROXY`; if empty, the proxy is read from `HTTPS_PROXY` and `NO_PROXY`.
+func GrpcProxy() string {
+	return current().GrpcProxy
+}
@@ -0,0 +1,66 @@
+package observeCfg_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/twistingmercury/observability/observeCfg"
+)
+
+func grpcViper() *viper.Viper {
+	v := viper.New()
+	v.Set(observeCfg.EnvironEnvVar, "dev")
+	v.Set(observeCfg.LogLevelEnvVar, "info")
+	v.Set(observeCfg.OtlpEndpointEnvVar, "collector:4317")
+	return v
+}
+
+func TestLoad_Grpc(t *testing.T) {
+	c, err := observeCfg.Load(loadOptions(nil, grpcViper()))
+	require.NoError(t, err)
+	assert.Zero(t, c.GrpcKeepaliveTime)
+	assert.Zero(t, c.GrpcRetryMaxAttempts)
+	assert.Empty(t, c.GrpcLBPolicy)
+
+	v := grpcViper()
+	v.Set(observeCfg.ExporterClientCertEnvVar, "/etc/ssl/client.pem")
+	v.Set(observeCfg.ExporterClientKeyEnvVar, "/etc/ssl/client-key.pem")
+	v.Set(observeCfg.GrpcServerNameEnvVar, "collector.internal")
+	v.Set(observeCfg.GrpcKeepaliveTimeEnvVar, "30000")
+	v.Set(observeCfg.GrpcKeepaliveTimeoutEnvVar, "5000")
+	v.Set(observeCfg.GrpcRetryMaxAttemptsEnvVar, "4")
+	v.Set(observeCfg.GrpcLBPolicyEnvVar, "round_robin")
+	v.Set(observeCfg.GrpcProxyEnvVar, "http://proxy:3128")
+
+	c, err = observeCfg.Load(loadOptions(nil, v))
+	require.NoError(t, err)
+	assert.Equal(t, "/etc/ssl/client.pem", c.ExporterClientCertificate)
+	assert.Equal(t, "/etc/ssl/client-key.pem", c.ExporterClientKey)
+	assert.Equal(t, "collector.internal", c.GrpcServerName)
+	assert.Equal(t, 30*time.Second, c.GrpcKeepaliveTime)
+	assert.Equal(t, 5*time.Second, c.GrpcKeepaliveTimeout)
+	assert.Equal(t, 4, c.GrpcRetryMaxAttempts)
+	assert.Equal(t, "round_robin", c.GrpcLBPolicy)
+	assert.Equal(t, "http://proxy:3128", c.GrpcProxy)
+}
+
+func TestLoad_GrpcValidationErrors(t *testing.T) {
+	v := grpcViper()
+	v.Set(observeCfg.ExporterClientCertEnvVar, "/etc/ssl/client.pem")
+	v.Set(observeCfg.GrpcKeepaliveTimeEnvVar, "often")
+	v.Set(observeCfg.GrpcRetryMaxAttemptsEnvVar, "-1")
+	v.Set(observeCfg.GrpcLBPolicyEnvVar, "random")
+	v.Set(observeCfg.GrpcProxyEnvVar, "proxy:3128")
+
+	_, err := observeCfg.Load(loadOptions(nil, v))
+	require.Error(t, err)
+	assert.ErrorContains(t, err, "the exporter client certificate and key must be set together")
+	assert.ErrorContains(t, err, "invalid grpc keepalive time: often")
+	assert.ErrorContains(t, err, "invalid grpc retry max attempts: -1")
+	assert.ErrorContains(t, err, "invalid grpc load balancing policy: random")
+	assert.ErrorContains(t, err, "invalid grpc proxy")
+}
@@ -0,0 +1,204 @@
+package observeCfg
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"strings"
+
+	"github.com/spf13/pflag"
+)
+
+// the formats of the `--version` and `--help` output, set by the `--info-format` flag.
+const (
+	TextFormat = "text"
+	JSONFormat = "json"
+)
+
+var (
+	infoOutput io.Writer = os.Stdout
+	exit                 = os.Exit
+)
+
+// VersionInfo describes the build of a service, as printed by the `--version` flag.
+type VersionInfo struct {
+	Service      string       `json:"service"`
+	Version      string       `json:"version"`
+	Commit       string       `json:"commit"`
+	Dirty        bool         `json:"dirty,omitempty"`
+	BuildDate    string       `json:"build_date"`
+	GoVersion    string       `json:"go_version"`
+	Module       string       `json:"module,omitempty"`
+	Dependencies []Dependency `json:"dependencies,omitempty"`
+}
+
+// Dependency is a module the service is built with.
+type Dependency struct {
+	Path    string `json:"path"`
+	Version string `json:"version"`
+	// Replace is the module replacing it, if any, formatted as `path version`.
+	Replace string `json:"replace,omitempty"`
+}
+
+// FlagUsage describes a flag, as printed by the `--help` flag.
+type FlagUsage struct {
+	Flag    string   `json:"flag"`
+	Type    string   `json:"type"`
+	Default string   `json:"default,omitempty"`
+	Usage   string   `json:"usage"`
+	EnvVars []string `json:"env_vars,omitempty"`
+}
+
+// NewVersionInfo returns the version information of a service, completed with the Go version and the module
+// dependencies the binary was built with. The empty args are read from the build info, as by Initialize.
+func NewVersionInfo(svcName, ver, commit, buildDate string) VersionInfo {
+	vcs := withBuildInfo(ver, commit, buildDate)
+	info := VersionInfo{
+		Service:   svcName,
+		Version:   vcs.version,
+		Commit:    vcs.commit,
+		Dirty:     vcs.dirty,
+		BuildDate: vcs.buildDate,
+		GoVersion: runtime.Version(),
+	}
+	bi, ok := readBuildInfo()
+	if !ok {
+		return info
+	}
+	info.Module = bi.Main.Path
+	for _, d := range bi.Deps {
+		dep := Dependency{Path: d.Path, Version: d.Version}
+		if d.Replace != nil {
+			dep.Replace = strings.TrimSpace(d.Replace.Path + " " + d.Replace.Version)
+		}
+		info.Dependencies = append(info.Dependencies, dep)
+	}
+	return info
+}
+
+// VersionInfo returns the version information of the service the configuration was read for.
+func (c *Config) VersionInfo() VersionInfo {
+	return NewVersionInfo(c.ServiceName, c.Version, c.CommitHash, c.BuildDate)
+}
+
+// WriteVersion writes the version information to w, formatted as text or JSON.
+func WriteVersion(w io.Writer, info VersionInfo, format string) error {
+	if format == JSONFormat {
+		return writeJSON(w, info)
+	}
+	b := &strings.Builder{}
+	fmt.Fprintf(b, "%s %s\n", info.Service, info.Version)
+	fmt.Fprintf(b, "  commit:     %s", info.Commit)
+	if info.Dirty {
+		b.WriteString(" (dirty)")
+	}
+	b.WriteString("\n")
+	fmt.Fprintf(b, "  build date: %s\n", info.BuildDate)
+	fmt.Fprintf(b, "  go version: %s\n", info.GoVersion)
+	if len(info.Module) > 0 {
+		fmt.Fprintf(b, "  module:     %s\n", info.Module)
+	}
+	if len(info.Dependencies) > 0 {
+		b.WriteString("  dependencies:\n")
+		for _, d := range info.Dependencies {
+			fmt.Fprintf(b, "    %s %s", d.Path, d.Version)
+			if len(d.Replace) > 0 {
+				fmt.Fprintf(b, " => %s", d.Replace)
+			}
+			b.WriteString("\n")
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// FlagUsages returns the usage of the flags of fs, and the environment variables that set the same settings.
+func FlagUsages(fs *pflag.FlagSet) []FlagUsage {
+	envVars := make(map[string]string, len(flagNames))
+	for envVar, flag := range flagNames {
+		envVars[flag] = envVar
+	}
+	var usages []FlagUsage
+	fs.VisitAll(func(f *pflag.Flag) {
+		u := FlagUsage{Flag: f.Name, Type: f.Value.Type(), Default: f.DefValue, Usage: f.Usage}
+		if envVar, ok := envVars[f.Name]; ok {
+			u.EnvVars = envVarNames(envVar)
+		}
+		usages = append(usages, u)
+	})
+	return usages
+}
+
+// WriteUsage writes the usage of the flags of fs to w, formatted as text or JSON. The text lists the environment
+// variables of each flag, e.g. `[$LOG_LEVEL]`.
+func WriteUsage(w io.Writer, fs *pflag.FlagSet, format string) error {
+	if format == JSONFormat {
+		return writeJSON(w, FlagUsages(fs))
+	}
+	// the flags are copied to a flag set of their own so that pflag aligns their usage.
+	aligned := pflag.NewFlagSet("usage", pflag.ContinueOnError)
+	aligned.SortFlags = fs.SortFlags
+	for _, u := range FlagUsages(fs) {
+		f := *fs.Lookup(u.Flag)
+		if len(u.EnvVars) > 0 {
+			f.Usage = fmt.Sprintf("%s [$%s]", f.Usage, strings.Join(u.EnvVars, ", $"))
+		}
+		aligned.AddFlag(&f)
+	}
+	_, err := fmt.Fprintf(w, "Flags:\n%s", aligned.FlagUsages())
+	return err
+}
+
+// HandleInfoFlags writes the version information to w if the `--version` flag of fs is set, and the usage of its
+// flags if the `--help` flag is set, formatted as set by the `--info-format` flag. It returns true if either was
+// written, in which case the service is expected to exit. The settings need not be valid.
+func HandleInfoFlags(w io.Writer, fs *pflag.FlagSet, info VersionInfo) (handled bool, err error) {
+	l := &loader{flags: fs}
+	showVersion, vErr := l.flagSet(versionFlag)
+	showHelp, hErr := l.flagSet(helpFlag)
+	if err = errors.Join(vErr, hErr); err != nil {
+		return false, err
+	}
+	if !showVersion && !showHelp {
+		return false, nil
+	}
+	format := TextFormat
+	if f := l.changedFlag(infoFormatFlag); f != nil {
+		format = f.Value.String()
+	}
+	if format != TextFormat && format != JSONFormat {
+		return true, fmt.Errorf("invalid info format: %s; accepted values are `%s` and `%s`", format, TextFormat, JSONFormat)
+	}
+
+	if showVersion {
+		if err = WriteVersion(w, info, format); err != nil {
+			return true, err
+		}
+	}
+	if showHelp {
+		err = WriteUsage(w, fs, format)
+	}
+	return true, err
+}
+
+// handleInfoFlags handles the `--version` and `--help` flags of the command line, and exits if either is set.
+func handleInfoFlags(info VersionInfo) {
+	handled, err := HandleInfoFlags(infoOutput, pflag.CommandLine, info)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		exit(2)
+		return
+	}
+	if handled {
+		exit(0)
+	}
+}
+
+func writeJSON(w io.Writer, v any) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
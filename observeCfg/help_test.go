@@ -0,0 +1,54 @@
+package observeCfg_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/spf13/pflag"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/twistingmercury/observability/observeCfg"
+)
+
+func TestHandleInfoFlags(t *testing.T) {
+	info := observeCfg.NewVersionInfo(svcName, version, commitHash, buildDate)
+	newFlags := func(args ...string) *pflag.FlagSet {
+		fs := pflag.NewFlagSet("svc", pflag.ContinueOnError)
+		fs.Int("port", 8080, "the port of the service")
+		observeCfg.RegisterFlags(fs)
+		require.NoError(t, fs.Parse(args))
+		return fs
+	}
+
+	out := &bytes.Buffer{}
+	handled, err := observeCfg.HandleInfoFlags(out, newFlags("--port", "9090"), info)
+	assert.NoError(t, err)
+	assert.False(t, handled)
+	assert.Empty(t, out.String())
+
+	handled, err = observeCfg.HandleInfoFlags(out, newFlags("--help", "--info-format", "json"), info)
+	assert.NoError(t, err)
+	assert.True(t, handled)
+	var usages []observeCfg.FlagUsage
+	require.NoError(t, json.Unmarshal(out.Bytes(), &usages))
+	assert.Contains(t, usages, observeCfg.FlagUsage{Flag: "port", Type: "int", Default: "8080", Usage: "the port of the service"})
+	assert.Contains(t, usages, observeCfg.FlagUsage{Flag: "env", Type: "string",
+		Usage:   "Set the environment in which the service is running [ localhost | dev | test | stage | prod ]",
+		EnvVars: []string{observeCfg.EnvironEnvVar}})
+
+	out.Reset()
+	handled, err = observeCfg.HandleInfoFlags(out, newFlags("--version", "--info-format", "yaml"), info)
+	assert.ErrorContains(t, err, "invalid info format: yaml")
+	assert.True(t, handled)
+	assert.Empty(t, out.String())
+
+	fs := pflag.NewFlagSet("svc", pflag.ContinueOnError)
+	fs.String("version", "", "the version of the API")
+	observeCfg.RegisterFlags(fs)
+	require.NoError(t, fs.Parse([]string{"--version", "v2"}))
+	handled, err = observeCfg.HandleInfoFlags(out, fs, info)
+	assert.ErrorContains(t, err, "invalid --version flag: v2")
+	assert.False(t, handled)
+}
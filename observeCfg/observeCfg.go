@@ -2,19 +2,29 @@
 // packages. It is intended to be used by the main package of a service. Internally, it uses the `github.com/spf13/pflag`
 // and `github.com/spf13/viper` packages to for configuration. Because of this, when creating configuration logic for
 // a service, it is recommended to use the same packages to avoid conflicts.
+//
+// Initialize reads the settings returned by the package-level accessors from the global `pflag.CommandLine` and
+// viper. Load reads them from a given flag set and viper instead, e.g. those of a cobra command, and returns them
+// as a Config.
 package observeCfg
 
 import (
-	"errors"
 	"fmt"
+	"net"
+	"net/url"
 	"os"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/pflag"
 	"github.com/spf13/viper"
 )
 
+// EnvironmentType is the environment a service runs in. Besides the environments defined here, services can accept
+// their own, see RegisterEnvironment.
 type EnvironmentType string
 
 const (
@@ -25,6 +35,51 @@ const (
 	local      EnvironmentType = "localhost"
 )
 
+func (e EnvironmentType) String() string {
+	return string(e)
+}
+
+var (
+	envMu        sync.RWMutex
+	environments = []EnvironmentType{local, Dev, Test, Stage, Production}
+)
+
+// RegisterEnvironment adds envs to the environments accepted by Initialize and Load, e.g. `qa` or `perf`. It should
+// be invoked before they are.
+func RegisterEnvironment(envs ...EnvironmentType) {
+	envMu.Lock()
+	defer envMu.Unlock()
+	for _, e := range envs {
+		if _, ok := findEnvironment(string(e)); !ok && len(e) > 0 {
+			environments = append(environments, e)
+		}
+	}
+}
+
+// ParseEnvironment returns the environment named s, ignoring the case: `localhost`, `dev`, `test`, `stage`, `prod`,
+// or one added by RegisterEnvironment.
+func ParseEnvironment(s string) (EnvironmentType, error) {
+	envMu.RLock()
+	defer envMu.RUnlock()
+	if e, ok := findEnvironment(strings.TrimSpace(s)); ok {
+		return e, nil
+	}
+	accepted := make([]string, 0, len(environments))
+	for _, e := range environments {
+		accepted = append(accepted, fmt.Sprintf("`%s`", e))
+	}
+	return "", fmt.Errorf("invalid environment: %s; accepted values are %s", s, strings.Join(accepted, ", "))
+}
+
+func findEnvironment(s string) (EnvironmentType, bool) {
+	for _, e := range environments {
+		if strings.EqualFold(string(e), s) {
+			return e, true
+		}
+	}
+	return "", false
+}
+
 const (
 	DebugLevel = "debug"
 	InfoLevel  = "info"
@@ -34,190 +89,703 @@ const (
 )
 
 const (
-	MetricsEndpointEnvVar = "METRICS_ENDPOINT"
-	TraceEndpointEnvVar   = "TRACE_ENDPOINT"
-	LogLevelEnvVar        = "LOG_LEVEL"
-	EnvironEnvVar         = "ENVIRONMENT"
-
-	environFlag         = "env"
-	versionFlag         = "version"
-	helpFlag            = "help"
-	logLevelFlag        = "log-level"
-	traceEndpointFlag   = "trace-endpoint"
-	metricsEndpointFlag = "metrics-endpoint"
+	GrpcProtocol         = "grpc"
+	HttpProtobufProtocol = "http/protobuf"
+	HttpJsonProtocol     = "http/json"
 )
 
-// ==================== flags ====================
-var (
-	fEnv = pflag.String(environFlag, "", "Set the environment in which the service is running [ localhost | dev | test | stage | prod ]")
-	fVer = pflag.Bool(versionFlag, false, "Display current version information for the app")
-	help = pflag.Bool(helpFlag, false, "Display help information")
-	fLlv = pflag.String(logLevelFlag, "", "Sets the log level [ debug | info | warn | error | fatal ]")
-	fTep = pflag.String(traceEndpointFlag, "", "The host and port of the otel collector where traces are to be sent [<server>:<port>]")
-	fMep = pflag.String(metricsEndpointFlag, "", "The host and port of the otel collector where metrics are to be sent [<server>:<port>]")
+const (
+	OtlpExporter       = "otlp"
+	PrometheusExporter = "prometheus"
+	ConsoleExporter    = "console"
+	FileExporter       = "file"
 )
 
-var (
-	// build info
-	commitHash string
-	bDate      string
-	version    string
-	name       string
-	hostName   string
-
-	// observability config
-	levelStr  string
-	logLevel  logrus.Level
-	traceEP   string
-	metricsEP string
-	environ   string
-
-	environs = fmt.Sprintf("%s%s%s%s%s", Dev, Stage, Production, Test, local)
+const (
+	ExplicitBucketHistogram    = "explicit_bucket_histogram"
+	ExponentialBucketHistogram = "base2_exponential_bucket_histogram"
 )
 
-// Initialize sets the build information, and invokes `pflag.Parse()`.
+const (
+	CumulativeTemporality = "cumulative"
+	DeltaTemporality      = "delta"
+	LowMemoryTemporality  = "lowmemory"
+)
+
+// the instrument kinds whose temporality can be overridden.
+const (
+	CounterKind                 = "counter"
+	UpDownCounterKind           = "up_down_counter"
+	HistogramKind               = "histogram"
+	GaugeKind                   = "gauge"
+	ObservableCounterKind       = "observable_counter"
+	ObservableUpDownCounterKind = "observable_up_down_counter"
+	ObservableGaugeKind         = "observable_gauge"
+)
+
+const (
+	AlwaysOnExemplarFilter   = "always_on"
+	AlwaysOffExemplarFilter  = "always_off"
+	TraceBasedExemplarFilter = "trace_based"
+)
+
+const (
+	GzipCompression = "gzip"
+	NoCompression   = "none"
+)
+
+// DefaultCardinalityLimit is the number of unique attribute sets each metrics instrument records, unless
+// `METRICS_CARDINALITY_LIMIT` is set.
+const DefaultCardinalityLimit = 2000
+
+const (
+	MetricsEndpointEnvVar     = "METRICS_ENDPOINT"
+	TraceEndpointEnvVar       = "TRACE_ENDPOINT"
+	LogLevelEnvVar            = "LOG_LEVEL"
+	EnvironEnvVar             = "ENVIRONMENT"
+	ExporterProtocolEnvVar    = "OTEL_EXPORTER_OTLP_PROTOCOL"
+	ExporterHeadersEnvVar     = "OTEL_EXPORTER_OTLP_HEADERS"
+	ExporterCompressionEnvVar = "OTEL_EXPORTER_OTLP_COMPRESSION"
+	ExporterTimeoutEnvVar     = "OTEL_EXPORTER_OTLP_TIMEOUT"
+	ExporterInsecureEnvVar    = "OTEL_EXPORTER_OTLP_INSECURE"
+	ExporterCertificateEnvVar = "OTEL_EXPORTER_OTLP_CERTIFICATE"
+	ExporterClientCertEnvVar  = "OTEL_EXPORTER_OTLP_CLIENT_CERTIFICATE"
+	ExporterClientKeyEnvVar   = "OTEL_EXPORTER_OTLP_CLIENT_KEY"
+	MetricsExporterEnvVar     = "OTEL_METRICS_EXPORTER"
+	TracesExporterEnvVar      = "OTEL_TRACES_EXPORTER"
+	FileDirEnvVar             = "TELEMETRY_FILE_DIR"
+	FileMaxSizeEnvVar         = "TELEMETRY_FILE_MAX_SIZE_MB"
+	FileMaxBackupsEnvVar      = "TELEMETRY_FILE_MAX_BACKUPS"
+	ConfigFileEnvVar          = "OBSERVABILITY_CONFIG"
+
+	ServiceNameEnvVar         = "OTEL_SERVICE_NAME"
+	OtlpEndpointEnvVar        = "OTEL_EXPORTER_OTLP_ENDPOINT"
+	OtlpTracesEndpointEnvVar  = "OTEL_EXPORTER_OTLP_TRACES_ENDPOINT"
+	OtlpMetricsEndpointEnvVar = "OTEL_EXPORTER_OTLP_METRICS_ENDPOINT"
+	TracesSamplerEnvVar       = "OTEL_TRACES_SAMPLER"
+	TracesSamplerArgEnvVar    = "OTEL_TRACES_SAMPLER_ARG"
+	ResourceAttributesEnvVar  = "OTEL_RESOURCE_ATTRIBUTES"
+	SDKDisabledEnvVar         = "OTEL_SDK_DISABLED"
+
+	ResourceLegacyAttributesEnvVar = "RESOURCE_LEGACY_ATTRIBUTES"
+
+	LogRedactedKeysEnvVar    = "LOG_REDACTED_KEYS"
+	HttpExcludedRoutesEnvVar = "HTTP_EXCLUDED_ROUTES"
+
+	MetricsLatencyBucketsEnvVar       = "METRICS_LATENCY_BUCKETS"
+	MetricsHistogramAggregationEnvVar = "OTEL_EXPORTER_OTLP_METRICS_DEFAULT_HISTOGRAM_AGGREGATION"
+	MetricsCardinalityLimitEnvVar     = "METRICS_CARDINALITY_LIMIT"
+	MetricsExemplarFilterEnvVar       = "OTEL_METRICS_EXEMPLAR_FILTER"
+	MetricsExportIntervalEnvVar       = "OTEL_METRIC_EXPORT_INTERVAL"
+	MetricsExportTimeoutEnvVar        = "OTEL_METRIC_EXPORT_TIMEOUT"
+	MetricsTemporalityEnvVar          = "OTEL_EXPORTER_OTLP_METRICS_TEMPORALITY_PREFERENCE"
+	MetricsTemporalityOverridesEnvVar = "METRICS_TEMPORALITY_OVERRIDES"
+	MetricsViewsEnvVar                = "METRICS_VIEWS"
+
+	GrpcServerNameEnvVar       = "GRPC_SERVER_NAME"
+	GrpcKeepaliveTimeEnvVar    = "GRPC_KEEPALIVE_TIME"
+	GrpcKeepaliveTimeoutEnvVar = "GRPC_KEEPALIVE_TIMEOUT"
+	GrpcRetryMaxAttemptsEnvVar = "GRPC_RETRY_MAX_ATTEMPTS"
+	GrpcLBPolicyEnvVar         = "GRPC_LB_POLICY"
+	GrpcProxyEnvVar            = "GRPC_PROXY"
+
+	environFlag          = "env"
+	versionFlag          = "version"
+	helpFlag             = "help"
+	logLevelFlag         = "log-level"
+	traceEndpointFlag    = "trace-endpoint"
+	metricsEndpointFlag  = "metrics-endpoint"
+	exporterProtocolFlag = "otlp-protocol"
+	metricsExporterFlag  = "metrics-exporter"
+	tracesExporterFlag   = "traces-exporter"
+	configFlag           = "config"
+	infoFormatFlag       = "info-format"
+
+	defaultFileDir        = "telemetry"
+	defaultFileMaxSizeMB  = 10
+	defaultFileMaxBackups = 3
+
+	defaultExporterTimeout = 10 * time.Second
+
+	defaultMetricsExportInterval = 60 * time.Second
+	defaultMetricsExportTimeout  = 30 * time.Second
+)
+
+// cfg is the configuration read by Initialize, returned by the package-level accessors. It is replaced, never
+// modified, when the config file is reloaded.
+var cfg = &Config{}
+
+// current returns the configuration read by Initialize.
+func current() *Config {
+	mu.RLock()
+	defer mu.RUnlock()
+	return cfg
+}
+
+// Initialize sets the build information, invokes `pflag.Parse()` unless the service already parsed the command line,
+// and reads the settings returned by the package-level accessors. A setting is read from, in order of precedence, its
+// flag, its environment variable, the config file given by `--config` or `OBSERVABILITY_CONFIG`, and its default.
+// The flags are registered on `pflag.CommandLine` when the package is loaded, so that a service may define its own
+// flags and parse them before invoking Initialize.
+//
+// The empty args buildDate, ver and commit are read from the build info of the binary: the `vcs.time`, the version
+// of the main module, and the `vcs.revision`. svcName is overridden by `OTEL_SERVICE_NAME`.
+//
+// If the `--version` or `--help` flag is set, the version information or the usage of the flags is printed to
+// stdout, see HandleInfoFlags, and the process exits.
+//
+// Services that parse their own flags, e.g. using cobra, should use RegisterFlags and Load instead.
 func Initialize(svcName, buildDate, ver, commit string) (err error) {
-	switch {
-	case len(svcName) == 0:
-		return errors.New("arg svcName cannot be empty")
-	case len(buildDate) == 0:
-		return errors.New("arg buildDate cannot be empty")
-	case len(ver) == 0:
-		return errors.New("arg ver cannot be empty")
-	case len(commit) == 0:
-		return errors.New("arg commit cannot be empty")
-	}
-
-	commitHash = commit
-	bDate = buildDate
-	version = ver
-	name = svcName
-
-	bindFlags()
+	if !commandLineParsed() {
+		RegisterFlags(pflag.CommandLine)
+		pflag.Parse()
+	}
+	if n := os.Getenv(ServiceNameEnvVar); len(n) > 0 {
+		svcName = n
+	}
+	handleInfoFlags(NewVersionInfo(svcName, ver, commit, buildDate))
+	for envVar, flag := range flagNames {
+		_ = viper.BindPFlag(envVar, pflag.Lookup(flag))
+	}
 	viper.AutomaticEnv()
-	parseConfig()
-	return validateConfig()
+
+	c, l, err := load(Options{
+		FlagSet:     pflag.CommandLine,
+		Viper:       viper.GetViper(),
+		ServiceName: svcName,
+		BuildDate:   buildDate,
+		Version:     ver,
+		Commit:      commit,
+	})
+	if err != nil {
+		return err
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	cfg = c
+	src = l
+	return nil
 }
 
-func bindFlags() {
-	pflag.Parse()
-	_ = viper.BindPFlag(EnvironEnvVar, pflag.Lookup(environFlag))
-	_ = viper.BindPFlag(LogLevelEnvVar, pflag.Lookup(logLevelFlag))
-	_ = viper.BindPFlag(TraceEndpointEnvVar, pflag.Lookup(traceEndpointFlag))
-	_ = viper.BindPFlag(MetricsEndpointEnvVar, pflag.Lookup(metricsEndpointFlag))
+func init() {
+	RegisterFlags(pflag.CommandLine)
 }
 
-func parseConfig() {
-	ShowHelp()
-	ShowHelp()
+// commandLineParsed reports whether the command line has already been parsed; it is replaced by the tests.
+var commandLineParsed = pflag.Parsed
+
+// flagNames are the flags of the settings that can be set by a flag, by environment variable.
+var flagNames = map[string]string{
+	EnvironEnvVar:          environFlag,
+	LogLevelEnvVar:         logLevelFlag,
+	TraceEndpointEnvVar:    traceEndpointFlag,
+	MetricsEndpointEnvVar:  metricsEndpointFlag,
+	ExporterProtocolEnvVar: exporterProtocolFlag,
+	MetricsExporterEnvVar:  metricsExporterFlag,
+	TracesExporterEnvVar:   tracesExporterFlag,
+	ConfigFileEnvVar:       configFlag,
+}
 
-	hn, _ := os.Hostname()
-	hostName = hn
+// grpcSchemes are the schemes of the gRPC name resolvers accepted in the endpoints, besides http(s), if the exporter
+// protocol is grpc, e.g. `dns:///collector:4317` or `unix:///var/run/collector.sock`.
+var grpcSchemes = map[string]bool{"dns": true, "unix": true, "passthrough": true}
 
-	levelStr = viper.GetString(LogLevelEnvVar)
-	traceEP = viper.GetString(TraceEndpointEnvVar)
-	metricsEP = viper.GetString(MetricsEndpointEnvVar)
-	environ = viper.GetString(EnvironEnvVar)
+// validateEndpoint validates the endpoint of a signal. The endpoints of the grpc protocol may also be gRPC targets
+// using one of the grpcSchemes.
+func validateEndpoint(setting, endpoint, protocol string) error {
+	if len(endpoint) == 0 {
+		return nil
+	}
+	invalid := fmt.Errorf("invalid %s: %s; it must be a `<host>:<port>` or an http(s) URL", setting, endpoint)
+	if protocol == GrpcProtocol {
+		invalid = fmt.Errorf("invalid %s: %s; it must be a `<host>:<port>`, an http(s) URL, or a gRPC target using "+
+			"the `dns:///`, `unix:///` or `passthrough:///` scheme", setting, endpoint)
+	}
 
-	// cli overrides env vars
-	if len(*fLlv) != 0 {
-		levelStr = *fLlv
+	var host, port string
+	if strings.Contains(endpoint, "://") {
+		u, err := url.Parse(endpoint)
+		switch {
+		case err != nil:
+			return invalid
+		case protocol == GrpcProtocol && grpcSchemes[u.Scheme]:
+			// the target is resolved by gRPC: a host and port for dns and passthrough, or a path for unix.
+			if len(strings.TrimPrefix(u.Path, "/")) == 0 {
+				return invalid
+			}
+			return nil
+		case u.Scheme != "http" && u.Scheme != "https":
+			return invalid
+		}
+		host, port = u.Hostname(), u.Port()
+	} else {
+		h, p, err := net.SplitHostPort(endpoint)
+		if err != nil || len(p) == 0 {
+			return invalid
+		}
+		host, port = h, p
 	}
-	if len(*fTep) != 0 {
-		traceEP = *fTep
+	if len(host) == 0 {
+		return invalid
 	}
-	if len(*fMep) != 0 {
-		metricsEP = *fMep
+	if len(port) > 0 {
+		if n, err := strconv.Atoi(port); err != nil || n < 1 || n > 65535 {
+			return invalid
+		}
 	}
-	if len(*fEnv) != 0 {
-		environ = *fEnv
+	return nil
+}
+
+func validateTracesExporter(exporter string) error {
+	switch exporter {
+	case OtlpExporter, ConsoleExporter, FileExporter:
+		return nil
 	}
+	return fmt.Errorf("invalid traces exporter: %s; accepted values are `%s`, `%s`, and `%s`",
+		exporter, OtlpExporter, ConsoleExporter, FileExporter)
 }
 
-func validateConfig() error {
-	if len(environ) == 0 {
-		return errors.New("environment is required")
+func parseLogLevel(s string) (logrus.Level, error) {
+	ll, err := logrus.ParseLevel(s)
+	if err != nil {
+		return ll, fmt.Errorf("invalid log level: %s; accepted levels are `%s`, `%s`, `%s`, `%s`, and  `%s`",
+			s, DebugLevel, InfoLevel, WarnLevel, ErrorLevel, FatalLevel)
 	}
-	if len(levelStr) == 0 {
-		return errors.New("log level is required")
+	return ll, nil
+}
+
+func validateExporterProtocol(protocol, compression string) error {
+	switch protocol {
+	case GrpcProtocol, HttpProtobufProtocol, HttpJsonProtocol:
+	default:
+		return fmt.Errorf("invalid exporter protocol: %s; accepted values are `%s`, `%s`, and `%s`",
+			protocol, GrpcProtocol, HttpProtobufProtocol, HttpJsonProtocol)
 	}
-	if len(traceEP) == 0 {
-		return errors.New("trace endpoint is required")
+
+	switch compression {
+	case GzipCompression, NoCompression:
+	default:
+		return fmt.Errorf("invalid exporter compression: %s; accepted values are `%s` and `%s`",
+			compression, GzipCompression, NoCompression)
 	}
-	if len(metricsEP) == 0 {
-		return errors.New("metrics endpoint is required")
+	return nil
+}
+
+// ResourceLegacyAttributes returns true if the service attributes are also set under the keys they had before they
+// followed the semantic conventions, e.g. `service` as well as `service.name`. It is set by the environment variable
+// `RESOURCE_LEGACY_ATTRIBUTES`, and defaults to true.
+func ResourceLegacyAttributes() bool {
+	return current().ResourceLegacyAttributes
+}
+
+// LogRedactedKeys returns the keys of the log fields whose values are redacted, in lower case, e.g. `authorization`.
+// It is set by the environment variable `LOG_REDACTED_KEYS`, a comma separated list of keys, and is reloaded when the
+// config file changes, see WatchConfigFile.
+func LogRedactedKeys() []string {
+	return append([]string(nil), current().LogRedactedKeys...)
+}
+
+// LogKeyRedacted returns true if the value of the log field key is redacted. The keys are compared ignoring the case.
+func LogKeyRedacted(key string) bool {
+	for _, k := range current().LogRedactedKeys {
+		if strings.EqualFold(k, key) {
+			return true
+		}
+	}
+	return false
+}
+
+// HttpExcludedRoutes returns the routes the HTTP middlewares neither log, trace nor measure, e.g. `/health`. It is
+// set by the environment variable `HTTP_EXCLUDED_ROUTES`, a comma separated list of route templates, and is reloaded
+// when the config file changes, see WatchConfigFile.
+func HttpExcludedRoutes() []string {
+	return append([]string(nil), current().HttpExcludedRoutes...)
+}
+
+// HttpRouteExcluded returns true if route is one of the HttpExcludedRoutes.
+func HttpRouteExcluded(route string) bool {
+	for _, r := range current().HttpExcludedRoutes {
+		if r == route {
+			return true
+		}
 	}
+	return false
+}
 
-	if !strings.Contains(environs, environ) {
-		return fmt.Errorf("invalid environment: %s; accepted values are `%s`, `%s`, `%s`, `%s`, and  `%s`",
-			environ, Dev, Stage, Production, Test, local)
+// parseList parses a comma separated list, dropping the empty items.
+func parseList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); len(item) > 0 {
+			items = append(items, item)
+		}
 	}
+	return items
+}
 
-	ll, err := logrus.ParseLevel(levelStr)
+// parseMilliseconds parses a positive number of milliseconds. If s is empty, def is returned.
+func parseMilliseconds(setting, s string, def time.Duration) (time.Duration, error) {
+	if len(s) == 0 {
+		return def, nil
+	}
+	ms, err := strconv.Atoi(s)
+	if err != nil || ms <= 0 {
+		return 0, fmt.Errorf("invalid %s: %s; it must be a positive number of milliseconds", setting, s)
+	}
+	return time.Duration(ms) * time.Millisecond, nil
+}
+
+// parsePositiveInt parses a positive number. If s is empty, def is returned.
+func parsePositiveInt(setting, s string, def int) (int, error) {
+	if len(s) == 0 {
+		return def, nil
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil || n <= 0 {
+		return def, fmt.Errorf("invalid %s: %s; it must be a positive number", setting, s)
+	}
+	return n, nil
+}
+
+// parseBool parses a boolean such as `true` or `false`. If s is empty, def is returned.
+func parseBool(setting, s string, def bool) (bool, error) {
+	if len(s) == 0 {
+		return def, nil
+	}
+	b, err := strconv.ParseBool(s)
 	if err != nil {
-		return fmt.Errorf("invalid log level: %s; accepted levels are `%s`, `%s`, `%s`, `%s`, and  `%s`",
-			levelStr, DebugLevel, InfoLevel, WarnLevel, ErrorLevel, FatalLevel)
+		return def, fmt.Errorf("invalid %s: %s; it must be `true` or `false`", setting, s)
+	}
+	return b, nil
+}
+
+// parseMetricsExporters parses the metrics exporters. Metrics are pushed to at most one of the otlp, console and
+// file exporters; they can be pulled by Prometheus in addition.
+func parseMetricsExporters(s string) ([]string, error) {
+	var exporters []string
+	pushed := 0
+	for _, e := range strings.Split(s, ",") {
+		e = strings.TrimSpace(e)
+		switch e {
+		case "":
+			continue
+		case OtlpExporter, ConsoleExporter, FileExporter:
+			pushed++
+		case PrometheusExporter:
+		default:
+			return nil, fmt.Errorf("invalid metrics exporter: %s; accepted values are `%s`, `%s`, `%s`, and `%s`",
+				e, OtlpExporter, ConsoleExporter, FileExporter, PrometheusExporter)
+		}
+		exporters = append(exporters, e)
+	}
+	if pushed > 1 {
+		return nil, fmt.Errorf("invalid metrics exporters: %s; only one of `%s`, `%s`, and `%s` can be used",
+			s, OtlpExporter, ConsoleExporter, FileExporter)
 	}
-	logLevel = ll
+	return exporters, nil
+}
 
+// validateMetricsAggregation validates the histogram aggregation and the exemplar filter.
+func validateMetricsAggregation(histogramAggregation, exemplarFilter string) error {
+	switch histogramAggregation {
+	case ExplicitBucketHistogram, ExponentialBucketHistogram:
+	default:
+		return fmt.Errorf("invalid histogram aggregation: %s; accepted values are `%s` and `%s`",
+			histogramAggregation, ExplicitBucketHistogram, ExponentialBucketHistogram)
+	}
+
+	switch exemplarFilter {
+	case AlwaysOnExemplarFilter, AlwaysOffExemplarFilter, TraceBasedExemplarFilter:
+	default:
+		return fmt.Errorf("invalid exemplar filter: %s; accepted values are `%s`, `%s`, and `%s`",
+			exemplarFilter, AlwaysOnExemplarFilter, AlwaysOffExemplarFilter, TraceBasedExemplarFilter)
+	}
 	return nil
 }
 
-// CommitHash returns the VCS reference of the build. It is set by the build process.
+// parseLatencyBuckets parses the latency bucket boundaries, which must be increasing numbers of seconds.
+func parseLatencyBuckets(s string) ([]float64, error) {
+	var buckets []float64
+	for _, b := range strings.Split(s, ",") {
+		b = strings.TrimSpace(b)
+		if len(b) == 0 {
+			continue
+		}
+		v, err := strconv.ParseFloat(b, 64)
+		if err != nil || v < 0 {
+			return nil, fmt.Errorf("invalid latency bucket: %s; buckets must be non-negative numbers of seconds", b)
+		}
+		if n := len(buckets); n > 0 && v <= buckets[n-1] {
+			return nil, fmt.Errorf("invalid latency buckets: %s; buckets must be in increasing order", s)
+		}
+		buckets = append(buckets, v)
+	}
+	return buckets, nil
+}
+
+// parseCardinalityLimit parses the cardinality limit. If s is empty, the default limit is returned.
+func parseCardinalityLimit(s string) (int, error) {
+	if len(s) == 0 {
+		return DefaultCardinalityLimit, nil
+	}
+	l, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid cardinality limit: %s; it must be a number of attribute sets", s)
+	}
+	return l, nil
+}
+
+func validateTemporality(temporality string) error {
+	switch temporality {
+	case CumulativeTemporality, DeltaTemporality, LowMemoryTemporality:
+		return nil
+	}
+	return fmt.Errorf("invalid temporality preference: %s; accepted values are `%s`, `%s`, and `%s`",
+		temporality, CumulativeTemporality, DeltaTemporality, LowMemoryTemporality)
+}
+
+// parseHeaders parses headers formatted as a comma separated list of `key=value` pairs. Values may be URL encoded.
+func parseHeaders(s string) (map[string]string, error) {
+	h := make(map[string]string)
+	for _, pair := range strings.Split(s, ",") {
+		if len(strings.TrimSpace(pair)) == 0 {
+			continue
+		}
+		k, v, ok := strings.Cut(pair, "=")
+		k = strings.TrimSpace(k)
+		if !ok || len(k) == 0 {
+			return nil, fmt.Errorf("invalid exporter header: %s; headers must be formatted as `key=value`", pair)
+		}
+		uv, err := url.QueryUnescape(strings.TrimSpace(v))
+		if err != nil {
+			return nil, fmt.Errorf("invalid exporter header: %s: %w", k, err)
+		}
+		h[k] = uv
+	}
+	return h, nil
+}
+
+// CommitHash returns the VCS reference of the build. It is set by the build process, or else read from the
+// `vcs.revision` setting of the build info.
 func CommitHash() string {
-	return commitHash
+	return current().CommitHash
 }
 
-// BuildDate returns the date of the build. It is set by the build process.
+// BuildDate returns the date of the build. It is set by the build process, or else read from the `vcs.time` setting
+// of the build info.
 func BuildDate() string {
-	return bDate
+	return current().BuildDate
 }
 
-// Version returns the version of the build. It is set by the build process.
+// Version returns the version of the build. It is set by the build process, or else read from the version of the
+// main module in the build info.
 func Version() string {
-	return version
+	return current().Version
 }
 
-// Environment returns the environment the svcName is running in. It is set by the environment variable `ENV`
-// and can be overridden by the `--env` flag.
+// Environment returns the environment the svcName is running in. It is set by the environment variable `ENVIRONMENT`,
+// or else by the `deployment.environment.name` attribute of `OTEL_RESOURCE_ATTRIBUTES`, and can be overridden by the
+// `--env` flag.
 func Environment() string {
-	return environ
+	return string(current().Environment)
 }
 
-// ServiceName returns the name of the svcName.
+// ServiceName returns the name of the service. The name given to Initialize is overridden by the environment variable
+// `OTEL_SERVICE_NAME`.
 func ServiceName() string {
-	return name
+	return current().ServiceName
 }
 
 // LogLevel returns the log level. It is set by the environment variable `LOG_LEVEL` and can be overridden by the
-// `--log-level` flag.
+// `--log-level` flag. It is reloaded when the config file changes, see WatchConfigFile.
 func LogLevel() logrus.Level {
-	return logLevel
+	return current().LogLevel
 }
 
 // TraceEndpoint returns the OpenTelemetry endpoint for traces to be sent to. It is set by the environment variable
-// `TRACE_ENDPOINT` and can be overridden by the `--trace-endpoint` flag.
+// `OTEL_EXPORTER_OTLP_TRACES_ENDPOINT`, its alias `TRACE_ENDPOINT`, or `OTEL_EXPORTER_OTLP_ENDPOINT`, in order of
+// precedence, and can be overridden by the `--trace-endpoint` flag.
 func TraceEndpoint() string {
-	return traceEP
+	return current().TraceEndpoint
 }
 
 // MetricsEndpoint returns the OpenTelemetry endpoint for metrics to be sent to. It is set by the environment variable
-// `METRICS_ENDPOINT` and can be overridden by the `--metrics-endpoint` flag.
+// `OTEL_EXPORTER_OTLP_METRICS_ENDPOINT`, its alias `METRICS_ENDPOINT`, or `OTEL_EXPORTER_OTLP_ENDPOINT`, in order of
+// precedence, and can be overridden by the `--metrics-endpoint` flag.
 func MetricsEndpoint() string {
-	return metricsEP
+	return current().MetricsEndpoint
+}
+
+// ExporterProtocol returns the protocol used to send traces and metrics to the OpenTelemetry collector. It is set by
+// the environment variable `OTEL_EXPORTER_OTLP_PROTOCOL` and can be overridden by the `--otlp-protocol` flag. It
+// defaults to `grpc`.
+func ExporterProtocol() string {
+	return current().ExporterProtocol
+}
+
+// ExporterHeaders returns the headers sent with every export request, as HTTP headers or gRPC metadata. It is set by
+// the environment variable `OTEL_EXPORTER_OTLP_HEADERS`, formatted as `key1=value1,key2=value2`. The values are
+// resolved by ExporterCredentials: `${env:VAR}` is replaced by the value of the environment variable and
+// `${file:PATH}` by the content of the file, e.g. a Kubernetes secret mount. The values are those last resolved; the
+// exporters resolve them again when a file changes.
+func ExporterHeaders() map[string]string {
+	c := current()
+	if c.ExporterCredentials == nil {
+		return map[string]string{}
+	}
+	return c.ExporterCredentials.cached()
+}
+
+// ExporterCompression returns the compression used by the exporters, over HTTP or gRPC. It is set by the environment
+// variable `OTEL_EXPORTER_OTLP_COMPRESSION`, and is either `gzip` or `none`, the default.
+func ExporterCompression() string {
+	return current().ExporterCompression
+}
+
+// ExporterTimeout returns the maximum time an OTLP/HTTP export request may take. It is set, in milliseconds, by the
+// environment variable `OTEL_EXPORTER_OTLP_TIMEOUT`, and defaults to 10 seconds.
+func ExporterTimeout() time.Duration {
+	return current().ExporterTimeout
+}
+
+// ExporterInsecure returns true if the exporters use plain HTTP, or an insecure gRPC connection. It is set by the
+// environment variable `OTEL_EXPORTER_OTLP_INSECURE`.
+func ExporterInsecure() bool {
+	return current().ExporterInsecure
+}
+
+// ExporterCertificate returns the path of the CA certificate used to verify the collector's certificate. It is set
+// by the environment variable `OTEL_EXPORTER_OTLP_CERTIFICATE`.
+func ExporterCertificate() string {
+	return current().ExporterCertificate
+}
+
+// ExporterClientCertificate returns the path of the certificate the exporters present to the collector, for mutual
+// TLS. It is set by the environment variable `OTEL_EXPORTER_OTLP_CLIENT_CERTIFICATE`, together with
+// ExporterClientKey.
+func ExporterClientCertificate() string {
+	return current().ExporterClientCertificate
+}
+
+// ExporterClientKey returns the path of the private key of ExporterClientCertificate. It is set by the environment
+// variable `OTEL_EXPORTER_OTLP_CLIENT_KEY`.
+func ExporterClientKey() string {
+	return current().ExporterClientKey
+}
+
+// MetricsExporters returns the exporters the metrics are sent to. It is set by the environment variable
+// `OTEL_METRICS_EXPORTER`, a comma separated list of one of `otlp`, `console` and `file`, and optionally
+// `prometheus`, and can be overridden by the `--metrics-exporter` flag. It defaults to `otlp`.
+func MetricsExporters() []string {
+	return append([]string(nil), current().MetricsExporters...)
+}
+
+// MetricsExporterEnabled returns true if exporter is one of the MetricsExporters.
+func MetricsExporterEnabled(exporter string) bool {
+	return current().MetricsExporterEnabled(exporter)
+}
+
+// TracesExporter returns the exporter spans are sent to: `otlp`, the default, `console` or `file`. It is set by the
+// environment variable `OTEL_TRACES_EXPORTER` and can be overridden by the `--traces-exporter` flag.
+func TracesExporter() string {
+	return current().TracesExporter
+}
+
+// FileDir returns the directory the `file` exporters write `traces.jsonl` and `metrics.jsonl` to. It is set by the
+// environment variable `TELEMETRY_FILE_DIR`, and defaults to `telemetry`.
+func FileDir() string {
+	return current().FileDir
+}
+
+// FileMaxSizeMB returns the size, in megabytes, the files of the `file` exporters may reach before they are rotated.
+// It is set by the environment variable `TELEMETRY_FILE_MAX_SIZE_MB`, and defaults to 10.
+func FileMaxSizeMB() int {
+	return current().FileMaxSizeMB
+}
+
+// FileMaxBackups returns the number of rotated files the `file` exporters keep. It is set by the environment
+// variable `TELEMETRY_FILE_MAX_BACKUPS`, and defaults to 3.
+func FileMaxBackups() int {
+	return current().FileMaxBackups
+}
+
+// MetricsLatencyBuckets returns the bucket boundaries, in seconds, of the histograms measuring durations. It is set
+// by the environment variable `METRICS_LATENCY_BUCKETS`, a comma separated list of increasing numbers, e.g.
+// `0.01,0.1,1,10`. If empty, the boundaries of each histogram are used.
+func MetricsLatencyBuckets() []float64 {
+	return append([]float64(nil), current().MetricsLatencyBuckets...)
+}
+
+// MetricsHistogramAggregation returns the aggregation of the histograms: `explicit_bucket_histogram`, the default,
+// or `base2_exponential_bucket_histogram`. It is set by the environment variable
+// `OTEL_EXPORTER_OTLP_METRICS_DEFAULT_HISTOGRAM_AGGREGATION`.
+func MetricsHistogramAggregation() string {
+	return current().MetricsHistogramAggregation
+}
+
+// MetricsCardinalityLimit returns the number of unique attribute sets each instrument records before the
+// measurements are folded into an overflow series. It is set by the environment variable
+// `METRICS_CARDINALITY_LIMIT`, and defaults to 2000. A limit of zero or less disables the limit.
+func MetricsCardinalityLimit() int {
+	return current().MetricsCardinalityLimit
+}
+
+// MetricsExemplarFilter returns the filter selecting the measurements that may be sampled as exemplars, linking
+// them to the span they were recorded in: `trace_based`, the default, only samples measurements recorded in a
+// sampled span, `always_on` samples any measurement and `always_off` disables the exemplars. It is set by the
+// environment variable `OTEL_METRICS_EXEMPLAR_FILTER`.
+func MetricsExemplarFilter() string {
+	return current().MetricsExemplarFilter
+}
+
+// MetricsExportInterval returns the interval between two exports of the metrics. It is set, in milliseconds, by the
+// environment variable `OTEL_METRIC_EXPORT_INTERVAL`, and defaults to 60 seconds.
+func MetricsExportInterval() time.Duration {
+	return current().MetricsExportInterval
+}
+
+// MetricsExportTimeout returns the maximum time an export of the metrics may take. It is set, in milliseconds, by
+// the environment variable `OTEL_METRIC_EXPORT_TIMEOUT`, and defaults to 30 seconds.
+func MetricsExportTimeout() time.Duration {
+	return current().MetricsExportTimeout
+}
+
+// MetricsTemporality returns the temporality preference of the exported metrics: `cumulative`, the default, `delta`
+// or `lowmemory`. It is set by the environment variable `OTEL_EXPORTER_OTLP_METRICS_TEMPORALITY_PREFERENCE`.
+func MetricsTemporality() string {
+	return current().MetricsTemporality
+}
+
+// MetricsTemporalityOverrides returns a copy of the temporality of the instrument kinds that override the
+// MetricsTemporality preference. It is set by the environment variable `METRICS_TEMPORALITY_OVERRIDES`, formatted as
+// `kind=temporality`, e.g. `counter=delta,up_down_counter=cumulative`.
+func MetricsTemporalityOverrides() map[string]string {
+	overrides := current().MetricsTemporalityOverrides
+	o := make(map[string]string, len(overrides))
+	for k, v := range overrides {
+		o[k] = v
+	}
+	return o
+}
+
+// MetricsViews returns the views applied to the instruments. It is set by the environment variable `METRICS_VIEWS`,
+// see parseViews for its format.
+func MetricsViews() []MetricView {
+	return append([]MetricView(nil), current().MetricsViews...)
 }
 
 // HostName returns the hostname of the machine the svcName is running on.
 func HostName() string {
-	return hostName
+	return current().HostName
 }
 
-// ShowHelp displays the help information and exits if the `--help` flag is set.
+// ShowHelp returns true if the `--help` flag is set.
 func ShowHelp() bool {
-	return *help
+	return current().ShowHelp
 }
 
-// ShowVersion displays the version information and exits if the `--version` flag is set.
+// ShowVersion returns true if the `--version` flag is set.
 func ShowVersion() bool {
-	return *fVer
+	return current().ShowVersion
 }
@@ -0,0 +1,43 @@
+package observeCfg
+
+import (
+	"io"
+	"os"
+	"runtime/debug"
+
+	"github.com/spf13/pflag"
+)
+
+// ResetFlags replaces the flags parsed by previous tests, so that Initialize parses the command line again.
+func ResetFlags() {
+	pflag.CommandLine = pflag.NewFlagSet(os.Args[0], pflag.ExitOnError)
+	RegisterFlags(pflag.CommandLine)
+}
+
+// SetInfoOutput redirects the output of the `--version` and `--help` flags, and replaces the exit of the process by
+// recording its code. The returned func restores them.
+func SetInfoOutput(w io.Writer, code *int) (restore func()) {
+	infoOutput = w
+	*code = -1
+	exit = func(c int) { *code = c }
+	return func() {
+		infoOutput = os.Stdout
+		exit = os.Exit
+	}
+}
+
+// ParseArgsOnInitialize makes Initialize parse os.Args again, although a previous test already parsed the command
+// line. The returned func restores it.
+func ParseArgsOnInitialize() (restore func()) {
+	commandLineParsed = func() bool { return false }
+	return func() { commandLineParsed = pflag.Parsed }
+}
+
+// ReloadConfigFile applies the changes of the config file, as when the watched file is written.
+var ReloadConfigFile = reloadConfigFile
+
+// SetBuildInfo replaces the build info of the test binary. The returned func restores it.
+func SetBuildInfo(bi *debug.BuildInfo) (restore func()) {
+	readBuildInfo = func() (*debug.BuildInfo, bool) { return bi, bi != nil }
+	return func() { readBuildInfo = debug.ReadBuildInfo }
+}
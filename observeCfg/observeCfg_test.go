@@ -1,11 +1,17 @@
 package observeCfg_test
 
 import (
+	"bytes"
+	"encoding/json"
 	"github.com/sirupsen/logrus"
+	"github.com/spf13/pflag"
 	"github.com/spf13/viper"
 	"github.com/stretchr/testify/assert"
 	"os"
+	"path/filepath"
+	"runtime"
 	"testing"
+	"time"
 
 	"github.com/twistingmercury/observability/observeCfg"
 )
@@ -35,10 +41,41 @@ func tearDown() {
 	os.Unsetenv(observeCfg.TraceEndpointEnvVar)
 	os.Unsetenv(observeCfg.MetricsEndpointEnvVar)
 	os.Unsetenv(observeCfg.EnvironEnvVar)
+	os.Unsetenv(observeCfg.ExporterProtocolEnvVar)
+	os.Unsetenv(observeCfg.ExporterHeadersEnvVar)
+	os.Unsetenv(observeCfg.ExporterCompressionEnvVar)
+	os.Unsetenv(observeCfg.ExporterTimeoutEnvVar)
+	os.Unsetenv(observeCfg.ExporterInsecureEnvVar)
+	os.Unsetenv(observeCfg.ExporterCertificateEnvVar)
+	os.Unsetenv(observeCfg.MetricsExporterEnvVar)
+	os.Unsetenv(observeCfg.TracesExporterEnvVar)
+	os.Unsetenv(observeCfg.FileDirEnvVar)
+	os.Unsetenv(observeCfg.FileMaxSizeEnvVar)
+	os.Unsetenv(observeCfg.FileMaxBackupsEnvVar)
+	os.Unsetenv(observeCfg.MetricsLatencyBucketsEnvVar)
+	os.Unsetenv(observeCfg.MetricsHistogramAggregationEnvVar)
+	os.Unsetenv(observeCfg.MetricsCardinalityLimitEnvVar)
+	os.Unsetenv(observeCfg.MetricsExemplarFilterEnvVar)
+	os.Unsetenv(observeCfg.MetricsExportIntervalEnvVar)
+	os.Unsetenv(observeCfg.MetricsExportTimeoutEnvVar)
+	os.Unsetenv(observeCfg.MetricsTemporalityEnvVar)
+	os.Unsetenv(observeCfg.MetricsTemporalityOverridesEnvVar)
+	os.Unsetenv(observeCfg.MetricsViewsEnvVar)
+	os.Unsetenv(observeCfg.ConfigFileEnvVar)
+	os.Unsetenv(observeCfg.ServiceNameEnvVar)
+	os.Unsetenv(observeCfg.OtlpEndpointEnvVar)
+	os.Unsetenv(observeCfg.OtlpTracesEndpointEnvVar)
+	os.Unsetenv(observeCfg.OtlpMetricsEndpointEnvVar)
+	os.Unsetenv(observeCfg.TracesSamplerEnvVar)
+	os.Unsetenv(observeCfg.TracesSamplerArgEnvVar)
+	os.Unsetenv(observeCfg.ResourceAttributesEnvVar)
+	os.Unsetenv(observeCfg.SDKDisabledEnvVar)
 	viper.Reset()
+	observeCfg.ResetFlags()
 }
 
 func TestObserveCfg(t *testing.T) {
+	defer observeCfg.ParseArgsOnInitialize()()
 	t.Run("0-initialize", func(t *testing.T) {
 		setup()
 		defer tearDown()
@@ -57,6 +94,17 @@ func TestObserveCfg(t *testing.T) {
 		assert.Equal(t, hostName, observeCfg.HostName())
 		assert.False(t, observeCfg.ShowHelp())
 		assert.False(t, observeCfg.ShowVersion())
+		assert.Equal(t, observeCfg.GrpcProtocol, observeCfg.ExporterProtocol())
+		assert.Equal(t, observeCfg.NoCompression, observeCfg.ExporterCompression())
+		assert.Equal(t, 10*time.Second, observeCfg.ExporterTimeout())
+		assert.Empty(t, observeCfg.ExporterHeaders())
+		assert.False(t, observeCfg.ExporterInsecure())
+		assert.Empty(t, observeCfg.ExporterCertificate())
+		assert.Equal(t, []string{observeCfg.OtlpExporter}, observeCfg.MetricsExporters())
+		assert.Equal(t, observeCfg.OtlpExporter, observeCfg.TracesExporter())
+		assert.Equal(t, "telemetry", observeCfg.FileDir())
+		assert.Equal(t, 10, observeCfg.FileMaxSizeMB())
+		assert.Equal(t, 3, observeCfg.FileMaxBackups())
 	})
 	t.Run("1-invalid_log_level", func(t *testing.T) {
 		setup()
@@ -134,10 +182,446 @@ func TestObserveCfg(t *testing.T) {
 		setup()
 
 		defer tearDown()
+		out := &bytes.Buffer{}
+		var code int
+		restore := observeCfg.SetInfoOutput(out, &code)
+		defer restore()
+
 		os.Args = []string{"cmd", "--help", "--version"}
 		assert.NoError(t, observeCfg.Initialize(svcName, buildDate, version, commitHash))
-
+		assert.Equal(t, 0, code, "the process exits cleanly")
 		assert.True(t, observeCfg.ShowHelp())
 		assert.True(t, observeCfg.ShowVersion())
+		assert.Contains(t, out.String(), svcName+" "+version+"\n")
+		assert.Contains(t, out.String(), "commit:     "+commitHash)
+		assert.Contains(t, out.String(), "build date: "+buildDate)
+		assert.Contains(t, out.String(), "go version: "+runtime.Version())
+		assert.Contains(t, out.String(), "--log-level string")
+		assert.Contains(t, out.String(), "[$LOG_LEVEL]")
+		assert.Contains(t, out.String(), "[$OTEL_EXPORTER_OTLP_TRACES_ENDPOINT, $TRACE_ENDPOINT, $OTEL_EXPORTER_OTLP_ENDPOINT]")
+
+		observeCfg.ResetFlags()
+		out.Reset()
+		os.Args = []string{"cmd", "--version", "--info-format", "json"}
+		assert.NoError(t, observeCfg.Initialize(svcName, buildDate, version, commitHash))
+		var info observeCfg.VersionInfo
+		assert.NoError(t, json.Unmarshal(out.Bytes(), &info))
+		assert.Equal(t, svcName, info.Service)
+		assert.Equal(t, commitHash, info.Commit)
+		assert.Equal(t, runtime.Version(), info.GoVersion)
+		assert.NotEmpty(t, info.Dependencies)
+
+		observeCfg.ResetFlags()
+		code = -1
+		os.Args = []string{"cmd"}
+		assert.NoError(t, observeCfg.Initialize(svcName, buildDate, version, commitHash))
+		assert.Equal(t, -1, code, "the process only exits if a flag is set")
+		assert.False(t, observeCfg.ShowHelp())
+	})
+	t.Run("13-exporter_settings", func(t *testing.T) {
+		setup()
+		defer tearDown()
+		os.Args = []string{"cmd"}
+		os.Setenv(observeCfg.ExporterProtocolEnvVar, observeCfg.HttpJsonProtocol)
+		os.Setenv(observeCfg.ExporterHeadersEnvVar, "api-key=secret, x-tenant=a%20b")
+		os.Setenv(observeCfg.ExporterCompressionEnvVar, observeCfg.GzipCompression)
+		os.Setenv(observeCfg.ExporterTimeoutEnvVar, "2500")
+		os.Setenv(observeCfg.ExporterInsecureEnvVar, "true")
+		os.Setenv(observeCfg.ExporterCertificateEnvVar, "/etc/ca.pem")
+
+		assert.NoError(t, observeCfg.Initialize(svcName, buildDate, version, commitHash))
+		assert.Equal(t, observeCfg.HttpJsonProtocol, observeCfg.ExporterProtocol())
+		assert.Equal(t, map[string]string{"api-key": "secret", "x-tenant": "a b"}, observeCfg.ExporterHeaders())
+		assert.Equal(t, observeCfg.GzipCompression, observeCfg.ExporterCompression())
+		assert.Equal(t, 2500*time.Millisecond, observeCfg.ExporterTimeout())
+		assert.True(t, observeCfg.ExporterInsecure())
+		assert.Equal(t, "/etc/ca.pem", observeCfg.ExporterCertificate())
+	})
+	t.Run("14-invalid_exporter_settings", func(t *testing.T) {
+		invalid := map[string]string{
+			observeCfg.ExporterProtocolEnvVar:    "http",
+			observeCfg.ExporterHeadersEnvVar:     "api-key",
+			observeCfg.ExporterCompressionEnvVar: "zstd",
+			observeCfg.ExporterTimeoutEnvVar:     "10s",
+		}
+		for k, v := range invalid {
+			setup()
+			os.Args = []string{"cmd"}
+			os.Setenv(k, v)
+			assert.Error(t, observeCfg.Initialize(svcName, buildDate, version, commitHash), k)
+			tearDown()
+		}
+	})
+	t.Run("15-cli_override_exporter_protocol", func(t *testing.T) {
+		setup()
+		defer tearDown()
+		os.Setenv(observeCfg.ExporterProtocolEnvVar, observeCfg.HttpJsonProtocol)
+		os.Args = []string{"cmd", "--otlp-protocol", observeCfg.HttpProtobufProtocol}
+		assert.NoError(t, observeCfg.Initialize(svcName, buildDate, version, commitHash))
+		assert.Equal(t, observeCfg.HttpProtobufProtocol, observeCfg.ExporterProtocol())
+	})
+	t.Run("16-metrics_exporters", func(t *testing.T) {
+		setup()
+		defer tearDown()
+		os.Args = []string{"cmd"}
+		os.Unsetenv(observeCfg.MetricsEndpointEnvVar)
+		os.Setenv(observeCfg.MetricsExporterEnvVar, "prometheus")
+		assert.NoError(t, observeCfg.Initialize(svcName, buildDate, version, commitHash),
+			"the metrics endpoint is not required when metrics are only pulled")
+		assert.True(t, observeCfg.MetricsExporterEnabled(observeCfg.PrometheusExporter))
+		assert.False(t, observeCfg.MetricsExporterEnabled(observeCfg.OtlpExporter))
+
+		os.Setenv(observeCfg.MetricsExporterEnvVar, "otlp, prometheus")
+		os.Setenv(observeCfg.MetricsEndpointEnvVar, metricsEndpoint)
+		assert.NoError(t, observeCfg.Initialize(svcName, buildDate, version, commitHash))
+		assert.Equal(t, []string{observeCfg.OtlpExporter, observeCfg.PrometheusExporter}, observeCfg.MetricsExporters())
+
+		os.Setenv(observeCfg.MetricsExporterEnvVar, "statsd")
+		assert.Error(t, observeCfg.Initialize(svcName, buildDate, version, commitHash))
+	})
+	t.Run("17-local_exporters", func(t *testing.T) {
+		setup()
+		defer tearDown()
+		os.Args = []string{"cmd"}
+		os.Setenv(observeCfg.TracesExporterEnvVar, observeCfg.FileExporter)
+		os.Setenv(observeCfg.MetricsExporterEnvVar, "file,prometheus")
+		os.Setenv(observeCfg.FileDirEnvVar, "/tmp/telemetry")
+		os.Setenv(observeCfg.FileMaxSizeEnvVar, "5")
+		os.Setenv(observeCfg.FileMaxBackupsEnvVar, "1")
+		assert.NoError(t, observeCfg.Initialize(svcName, buildDate, version, commitHash))
+		assert.Equal(t, observeCfg.FileExporter, observeCfg.TracesExporter())
+		assert.True(t, observeCfg.MetricsExporterEnabled(observeCfg.FileExporter))
+		assert.Equal(t, "/tmp/telemetry", observeCfg.FileDir())
+		assert.Equal(t, 5, observeCfg.FileMaxSizeMB())
+		assert.Equal(t, 1, observeCfg.FileMaxBackups())
+
+		os.Setenv(observeCfg.MetricsExporterEnvVar, "otlp,console")
+		assert.Error(t, observeCfg.Initialize(svcName, buildDate, version, commitHash),
+			"metrics are pushed to a single exporter")
+		os.Setenv(observeCfg.MetricsExporterEnvVar, "console")
+		os.Setenv(observeCfg.TracesExporterEnvVar, "jaeger")
+		assert.Error(t, observeCfg.Initialize(svcName, buildDate, version, commitHash))
+
+		os.Setenv(observeCfg.TracesExporterEnvVar, observeCfg.FileExporter)
+		os.Setenv(observeCfg.FileMaxSizeEnvVar, "5MB")
+		os.Setenv(observeCfg.FileMaxBackupsEnvVar, "0")
+		err := observeCfg.Initialize(svcName, buildDate, version, commitHash)
+		assert.ErrorContains(t, err, "invalid file max size: 5MB")
+		assert.ErrorContains(t, err, "invalid file max backups: 0")
+	})
+	t.Run("18-histograms", func(t *testing.T) {
+		setup()
+		defer tearDown()
+		os.Args = []string{"cmd"}
+		assert.NoError(t, observeCfg.Initialize(svcName, buildDate, version, commitHash))
+		assert.Empty(t, observeCfg.MetricsLatencyBuckets())
+		assert.Equal(t, observeCfg.ExplicitBucketHistogram, observeCfg.MetricsHistogramAggregation())
+
+		os.Setenv(observeCfg.MetricsLatencyBucketsEnvVar, "0.01, 0.1,1,10")
+		os.Setenv(observeCfg.MetricsHistogramAggregationEnvVar, observeCfg.ExponentialBucketHistogram)
+		assert.NoError(t, observeCfg.Initialize(svcName, buildDate, version, commitHash))
+		assert.Equal(t, []float64{0.01, 0.1, 1, 10}, observeCfg.MetricsLatencyBuckets())
+		assert.Equal(t, observeCfg.ExponentialBucketHistogram, observeCfg.MetricsHistogramAggregation())
+
+		os.Setenv(observeCfg.MetricsLatencyBucketsEnvVar, "1,0.1")
+		assert.Error(t, observeCfg.Initialize(svcName, buildDate, version, commitHash), "buckets must be increasing")
+		os.Setenv(observeCfg.MetricsLatencyBucketsEnvVar, "1,fast")
+		assert.Error(t, observeCfg.Initialize(svcName, buildDate, version, commitHash))
+		os.Setenv(observeCfg.MetricsLatencyBucketsEnvVar, "")
+		os.Setenv(observeCfg.MetricsHistogramAggregationEnvVar, "summary")
+		assert.Error(t, observeCfg.Initialize(svcName, buildDate, version, commitHash))
+	})
+	t.Run("19-cardinality_limit", func(t *testing.T) {
+		setup()
+		defer tearDown()
+		os.Args = []string{"cmd"}
+		assert.NoError(t, observeCfg.Initialize(svcName, buildDate, version, commitHash))
+		assert.Equal(t, 2000, observeCfg.MetricsCardinalityLimit())
+
+		os.Setenv(observeCfg.MetricsCardinalityLimitEnvVar, "-1")
+		assert.NoError(t, observeCfg.Initialize(svcName, buildDate, version, commitHash))
+		assert.Equal(t, -1, observeCfg.MetricsCardinalityLimit())
+
+		os.Setenv(observeCfg.MetricsCardinalityLimitEnvVar, "many")
+		assert.Error(t, observeCfg.Initialize(svcName, buildDate, version, commitHash))
+	})
+	t.Run("20-exemplar_filter", func(t *testing.T) {
+		setup()
+		defer tearDown()
+		os.Args = []string{"cmd"}
+		assert.NoError(t, observeCfg.Initialize(svcName, buildDate, version, commitHash))
+		assert.Equal(t, observeCfg.TraceBasedExemplarFilter, observeCfg.MetricsExemplarFilter())
+
+		os.Setenv(observeCfg.MetricsExemplarFilterEnvVar, observeCfg.AlwaysOffExemplarFilter)
+		assert.NoError(t, observeCfg.Initialize(svcName, buildDate, version, commitHash))
+		assert.Equal(t, observeCfg.AlwaysOffExemplarFilter, observeCfg.MetricsExemplarFilter())
+
+		os.Setenv(observeCfg.MetricsExemplarFilterEnvVar, "sometimes")
+		assert.Error(t, observeCfg.Initialize(svcName, buildDate, version, commitHash))
 	})
+
+	t.Run("21-metrics_export", func(t *testing.T) {
+		setup()
+		defer tearDown()
+		os.Args = []string{"cmd"}
+		assert.NoError(t, observeCfg.Initialize(svcName, buildDate, version, commitHash))
+		assert.Equal(t, time.Minute, observeCfg.MetricsExportInterval())
+		assert.Equal(t, 30*time.Second, observeCfg.MetricsExportTimeout())
+		assert.Equal(t, observeCfg.CumulativeTemporality, observeCfg.MetricsTemporality())
+		assert.Empty(t, observeCfg.MetricsTemporalityOverrides())
+		assert.Empty(t, observeCfg.MetricsViews())
+
+		os.Setenv(observeCfg.MetricsExportIntervalEnvVar, "15000")
+		os.Setenv(observeCfg.MetricsExportTimeoutEnvVar, "5000")
+		os.Setenv(observeCfg.MetricsTemporalityEnvVar, observeCfg.DeltaTemporality)
+		os.Setenv(observeCfg.MetricsTemporalityOverridesEnvVar, "up_down_counter=delta, histogram=cumulative")
+		os.Setenv(observeCfg.MetricsViewsEnvVar,
+			"http.request_duration_seconds:rename=http.server.duration,drop=http.status_code|http.method; http.*.size:disable")
+		assert.NoError(t, observeCfg.Initialize(svcName, buildDate, version, commitHash))
+		assert.Equal(t, 15*time.Second, observeCfg.MetricsExportInterval())
+		assert.Equal(t, 5*time.Second, observeCfg.MetricsExportTimeout())
+		assert.Equal(t, observeCfg.DeltaTemporality, observeCfg.MetricsTemporality())
+		assert.Equal(t, map[string]string{
+			observeCfg.UpDownCounterKind: observeCfg.DeltaTemporality,
+			observeCfg.HistogramKind:     observeCfg.CumulativeTemporality,
+		}, observeCfg.MetricsTemporalityOverrides())
+		assert.Equal(t, []observeCfg.MetricView{
+			{Instrument: "http.request_duration_seconds", Rename: "http.server.duration", DropAttributes: []string{"http.status_code", "http.method"}},
+			{Instrument: "http.*.size", Disabled: true},
+		}, observeCfg.MetricsViews())
+
+		invalid := map[string]string{
+			observeCfg.MetricsExportIntervalEnvVar:       "0",
+			observeCfg.MetricsExportTimeoutEnvVar:        "soon",
+			observeCfg.MetricsTemporalityEnvVar:          "sometimes",
+			observeCfg.MetricsTemporalityOverridesEnvVar: "timer=delta",
+			observeCfg.MetricsViewsEnvVar:                "http.*:rename=http.all",
+		}
+		for k, v := range invalid {
+			tearDown()
+			setup()
+			os.Setenv(k, v)
+			assert.Error(t, observeCfg.Initialize(svcName, buildDate, version, commitHash), k)
+		}
+	})
+
+	t.Run("22-config_file", func(t *testing.T) {
+		setup()
+		defer tearDown()
+		os.Args = []string{"cmd"}
+		os.Setenv(observeCfg.ConfigFileEnvVar, writeConfig(t, "observability.yaml", `
+metrics:
+  exporters: [otlp, prometheus]
+  latency_buckets: [0.1, 1]
+  cardinality_limit: 100
+  export_interval: 15s
+  temporality_overrides:
+    counter: delta
+  views:
+    - instrument: http.request.size
+      disable: true
+    - instrument: http.request_duration_seconds
+      rename: http.server.duration
+      drop_attributes: [http.status_code]
+exporter:
+  headers:
+    api-key: a secret
+  insecure: true
+file:
+  dir: from-file
+  max_backups: 5
+`))
+		assert.NoError(t, observeCfg.Initialize(svcName, buildDate, version, commitHash))
+		assert.NotEmpty(t, observeCfg.ConfigFile())
+		assert.Equal(t, []string{observeCfg.OtlpExporter, observeCfg.PrometheusExporter}, observeCfg.MetricsExporters())
+		assert.Equal(t, []float64{0.1, 1}, observeCfg.MetricsLatencyBuckets())
+		assert.Equal(t, 100, observeCfg.MetricsCardinalityLimit())
+		assert.Equal(t, 15*time.Second, observeCfg.MetricsExportInterval())
+		assert.Equal(t, map[string]string{observeCfg.CounterKind: observeCfg.DeltaTemporality}, observeCfg.MetricsTemporalityOverrides())
+		assert.Equal(t, []observeCfg.MetricView{
+			{Instrument: "http.request.size", Disabled: true},
+			{Instrument: "http.request_duration_seconds", Rename: "http.server.duration", DropAttributes: []string{"http.status_code"}},
+		}, observeCfg.MetricsViews())
+		assert.Equal(t, map[string]string{"api-key": "a secret"}, observeCfg.ExporterHeaders())
+		assert.True(t, observeCfg.ExporterInsecure())
+		assert.Equal(t, "from-file", observeCfg.FileDir())
+		assert.Equal(t, 5, observeCfg.FileMaxBackups())
+		assert.Equal(t, 10*time.Second, observeCfg.ExporterTimeout(), "the settings absent everywhere use their default")
+
+		// flags > env > file
+		os.Setenv(observeCfg.FileDirEnvVar, "from-env")
+		os.Setenv(observeCfg.MetricsExporterEnvVar, observeCfg.OtlpExporter)
+		os.Args = []string{"cmd", "--metrics-exporter", observeCfg.ConsoleExporter}
+		assert.NoError(t, observeCfg.Initialize(svcName, buildDate, version, commitHash))
+		assert.Equal(t, "from-env", observeCfg.FileDir())
+		assert.Equal(t, 5, observeCfg.FileMaxBackups())
+		assert.Equal(t, []string{observeCfg.ConsoleExporter}, observeCfg.MetricsExporters())
+
+		os.Args = []string{"cmd", "--config", writeConfig(t, "observability.json",
+			`{"metrics": {"cardinality_limit": 50, "exporters": "otlp"}, "exporter": {"timeout": 2500}}`)}
+		assert.NoError(t, observeCfg.Initialize(svcName, buildDate, version, commitHash))
+		assert.Equal(t, 50, observeCfg.MetricsCardinalityLimit(), "the flag overrides the environment variable")
+		assert.Equal(t, []string{observeCfg.ConsoleExporter}, observeCfg.MetricsExporters())
+		assert.Equal(t, 2500*time.Millisecond, observeCfg.ExporterTimeout())
+
+		os.Args = []string{"cmd", "--config", writeConfig(t, "observability.toml", `
+[metrics]
+cardinality_limit = 25
+temporality = "delta"
+`)}
+		assert.NoError(t, observeCfg.Initialize(svcName, buildDate, version, commitHash))
+		assert.Equal(t, 25, observeCfg.MetricsCardinalityLimit())
+		assert.Equal(t, observeCfg.DeltaTemporality, observeCfg.MetricsTemporality())
+
+		os.Args = []string{"cmd", "--config", writeConfig(t, "invalid.yaml", `
+log:
+  levl: debug
+metrics:
+  cardinality_limit: many
+  views:
+    - instrument: http.request.size
+      hide: true
+`)}
+		err := observeCfg.Initialize(svcName, buildDate, version, commitHash)
+		assert.ErrorContains(t, err, "log.levl: unknown setting")
+		assert.ErrorContains(t, err, "metrics.cardinality_limit: must be an integer")
+		assert.ErrorContains(t, err, "metrics.views: view 0: hide: unknown setting")
+
+		os.Args = []string{"cmd", "--config", writeConfig(t, "invalid.yaml", "metrics:\n  temporality: sometimes\n")}
+		assert.ErrorContains(t, observeCfg.Initialize(svcName, buildDate, version, commitHash), "invalid temporality preference")
+
+		os.Args = []string{"cmd", "--config", filepath.Join(t.TempDir(), "missing.yaml")}
+		assert.Error(t, observeCfg.Initialize(svcName, buildDate, version, commitHash))
+	})
+	t.Run("23-watch_config_file", func(t *testing.T) {
+		setup()
+		defer tearDown()
+		os.Args = []string{"cmd"}
+		os.Unsetenv(observeCfg.LogLevelEnvVar)
+		path := writeConfig(t, "observability.yaml", "log:\n  level: debug\nmetrics:\n  cardinality_limit: 10\n")
+		os.Setenv(observeCfg.ConfigFileEnvVar, path)
+		assert.NoError(t, observeCfg.Initialize(svcName, buildDate, version, commitHash))
+		assert.Equal(t, logrus.DebugLevel, observeCfg.LogLevel())
+
+		changes := make(chan observeCfg.Change, 10)
+		unsubscribe := observeCfg.Subscribe(func(c observeCfg.Change) { changes <- c })
+		defer unsubscribe()
+		assert.NoError(t, observeCfg.WatchConfigFile())
+
+		assert.NoError(t, os.WriteFile(path, []byte(`
+log:
+  level: error
+  redacted_keys: [Authorization]
+http:
+  excluded_routes: [/health]
+metrics:
+  cardinality_limit: 20
+`), 0o600))
+		select {
+		case c := <-changes:
+			assert.True(t, c.Has(observeCfg.LogLevelEnvVar))
+			assert.ElementsMatch(t, []string{observeCfg.LogLevelEnvVar, observeCfg.LogRedactedKeysEnvVar,
+				observeCfg.HttpExcludedRoutesEnvVar}, c.Settings, "only the reloadable settings are applied")
+		case <-time.After(5 * time.Second):
+			t.Fatal("the change of the config file was not published")
+		}
+		assert.Equal(t, logrus.ErrorLevel, observeCfg.LogLevel())
+		assert.Equal(t, []string{"authorization"}, observeCfg.LogRedactedKeys())
+		assert.True(t, observeCfg.LogKeyRedacted("AUTHORIZATION"))
+		assert.Equal(t, []string{"/health"}, observeCfg.HttpExcludedRoutes())
+		assert.True(t, observeCfg.HttpRouteExcluded("/health"))
+		assert.Equal(t, 10, observeCfg.MetricsCardinalityLimit(), "the other settings require a restart")
+
+		assert.NoError(t, os.WriteFile(path, []byte("log:\n  level: loud\n"), 0o600))
+		assert.Error(t, observeCfg.ReloadConfigFile(path), "an invalid file is rejected")
+		assert.Equal(t, logrus.ErrorLevel, observeCfg.LogLevel(), "the last valid settings are kept")
+
+		assert.NoError(t, os.WriteFile(path, []byte("log:\n  level: warn\n"), 0o600))
+		select {
+		case c := <-changes:
+			assert.True(t, c.Has(observeCfg.LogLevelEnvVar))
+		case <-time.After(5 * time.Second):
+			t.Fatal("the change of the config file was not published")
+		}
+		assert.Empty(t, changes, "the invalid file was not published")
+		assert.Equal(t, logrus.WarnLevel, observeCfg.LogLevel())
+
+		os.Setenv(observeCfg.ConfigFileEnvVar, "")
+		os.Setenv(observeCfg.LogLevelEnvVar, "debug")
+		assert.NoError(t, observeCfg.Initialize(svcName, buildDate, version, commitHash))
+		assert.Error(t, observeCfg.WatchConfigFile(), "no config file was given")
+	})
+	t.Run("24-otel_env_vars", func(t *testing.T) {
+		setup()
+		defer tearDown()
+		os.Args = []string{"cmd"}
+		assert.NoError(t, observeCfg.Initialize(svcName, buildDate, version, commitHash))
+		assert.Equal(t, observeCfg.AlwaysOnSampler, observeCfg.TracesSampler())
+		assert.Equal(t, 1.0, observeCfg.TracesSamplerArg())
+		assert.False(t, observeCfg.SDKDisabled())
+
+		os.Setenv(observeCfg.ServiceNameEnvVar, "otel-svc")
+		os.Setenv(observeCfg.OtlpEndpointEnvVar, "http://collector:4317")
+		os.Setenv(observeCfg.OtlpMetricsEndpointEnvVar, "http://metrics:4317")
+		os.Unsetenv(observeCfg.TraceEndpointEnvVar)
+		os.Setenv(observeCfg.TracesSamplerEnvVar, observeCfg.TraceIDRatioSampler)
+		os.Setenv(observeCfg.TracesSamplerArgEnvVar, "0.25")
+		assert.NoError(t, observeCfg.Initialize("", buildDate, version, commitHash))
+		assert.Equal(t, "otel-svc", observeCfg.ServiceName())
+		assert.Equal(t, "http://collector:4317", observeCfg.TraceEndpoint(), "the general endpoint is the fallback")
+		assert.Equal(t, "http://metrics:4317", observeCfg.MetricsEndpoint(), "the signal endpoint takes precedence")
+		assert.Equal(t, observeCfg.TraceIDRatioSampler, observeCfg.TracesSampler())
+		assert.Equal(t, 0.25, observeCfg.TracesSamplerArg())
+
+		os.Setenv(observeCfg.TraceEndpointEnvVar, "localhost:1234")
+		os.Args = []string{"cmd", "--metrics-endpoint", "localhost:5678"}
+		assert.NoError(t, observeCfg.Initialize(svcName, buildDate, version, commitHash))
+		assert.Equal(t, "localhost:1234", observeCfg.TraceEndpoint(), "the former name is an alias")
+		assert.Equal(t, "localhost:5678", observeCfg.MetricsEndpoint(), "the flags take precedence")
+
+		os.Args = []string{"cmd"}
+		observeCfg.ResetFlags()
+		os.Unsetenv(observeCfg.EnvironEnvVar)
+		os.Setenv(observeCfg.ResourceAttributesEnvVar, "team=core,deployment.environment.name=prod")
+		assert.NoError(t, observeCfg.Initialize(svcName, buildDate, version, commitHash))
+		assert.Equal(t, "prod", observeCfg.Environment())
+
+		os.Setenv(observeCfg.TracesSamplerArgEnvVar, "2")
+		assert.ErrorContains(t, observeCfg.Initialize(svcName, buildDate, version, commitHash), "invalid traces sampler arg")
+		os.Setenv(observeCfg.TracesSamplerEnvVar, "sometimes")
+		assert.ErrorContains(t, observeCfg.Initialize(svcName, buildDate, version, commitHash), "invalid traces sampler")
+
+		os.Unsetenv(observeCfg.TracesSamplerEnvVar)
+		os.Unsetenv(observeCfg.TracesSamplerArgEnvVar)
+		os.Unsetenv(observeCfg.OtlpEndpointEnvVar)
+		os.Unsetenv(observeCfg.OtlpMetricsEndpointEnvVar)
+		os.Unsetenv(observeCfg.TraceEndpointEnvVar)
+		os.Unsetenv(observeCfg.MetricsEndpointEnvVar)
+		assert.Error(t, observeCfg.Initialize(svcName, buildDate, version, commitHash))
+		os.Setenv(observeCfg.SDKDisabledEnvVar, "true")
+		assert.NoError(t, observeCfg.Initialize(svcName, buildDate, version, commitHash), "the endpoints are not required")
+		assert.True(t, observeCfg.SDKDisabled())
+	})
+}
+
+func TestInitialize_ParsedCommandLine(t *testing.T) {
+	setup()
+	defer tearDown()
+	observeCfg.ResetFlags()
+	defer observeCfg.ResetFlags()
+
+	port := pflag.Int("port", 8080, "the port of the service")
+	os.Args = []string{"cmd", "--port", "9090", "--log-level", "warn"}
+	pflag.Parse()
+	assert.Equal(t, 9090, *port)
+
+	os.Args = []string{"cmd", "--log-level", "error"}
+	assert.NoError(t, observeCfg.Initialize(svcName, buildDate, version, commitHash))
+	assert.Equal(t, logrus.WarnLevel, observeCfg.LogLevel(), "the command line parsed by the service is kept")
+}
+
+func writeConfig(t *testing.T, name, content string) string {
+	path := filepath.Join(t.TempDir(), name)
+	assert.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	return path
 }
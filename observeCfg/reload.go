@@ -0,0 +1,190 @@
+package observeCfg
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/spf13/viper"
+	"github.com/twistingmercury/observability/logger"
+)
+
+// Change is published to the subscribers when settings of the config file are changed at runtime.
+type Change struct {
+	// Settings are the environment variables of the settings that changed, e.g. `LOG_LEVEL`.
+	Settings []string
+}
+
+// Has returns true if the setting of the environment variable envVar changed.
+func (c Change) Has(envVar string) bool {
+	for _, s := range c.Settings {
+		if s == envVar {
+			return true
+		}
+	}
+	return false
+}
+
+// reloadable are the settings applied when the config file changes, by environment variable, and the func that
+// validates a new value and sets it in a copy of the configuration. The other settings require a restart.
+var reloadable = map[string]func(c *Config, value string) error{
+	LogLevelEnvVar: func(c *Config, value string) (err error) {
+		c.LogLevel, err = parseLogLevel(value)
+		return err
+	},
+	TracesSamplerEnvVar:    reloadSampler,
+	TracesSamplerArgEnvVar: reloadSamplerArg,
+	LogRedactedKeysEnvVar: func(c *Config, value string) error {
+		c.LogRedactedKeys = parseList(strings.ToLower(value))
+		return nil
+	},
+	HttpExcludedRoutesEnvVar: func(c *Config, value string) error {
+		c.HttpExcludedRoutes = parseList(value)
+		return nil
+	},
+}
+
+type subscriber struct {
+	f func(Change)
+}
+
+var (
+	// mu guards the configuration read by Initialize, and the loader it was read by.
+	mu          sync.RWMutex
+	src         *loader
+	subMu       sync.Mutex
+	subscribers []*subscriber
+	watchMu     sync.Mutex
+	// watched is the config file being watched.
+	watched string
+)
+
+// Subscribe registers f to be invoked with each Change applied from the config file, once WatchConfigFile has been
+// called. The returned func unsubscribes f.
+func Subscribe(f func(Change)) (unsubscribe func()) {
+	s := &subscriber{f: f}
+	subMu.Lock()
+	subscribers = append(subscribers, s)
+	subMu.Unlock()
+
+	return func() {
+		subMu.Lock()
+		defer subMu.Unlock()
+		for i, sub := range subscribers {
+			if sub == s {
+				subscribers = append(subscribers[:i:i], subscribers[i+1:]...)
+				return
+			}
+		}
+	}
+}
+
+func publish(c Change) {
+	subMu.Lock()
+	subs := append([]*subscriber(nil), subscribers...)
+	subMu.Unlock()
+	for _, s := range subs {
+		s.f(c)
+	}
+}
+
+// WatchConfigFile watches the config file, and applies the changes of the reloadable settings, the log level, the
+// traces sampler and its ratio, the redacted log keys and the excluded HTTP routes, when it is written. A setting also set by its flag or environment variable keeps that
+// value. An invalid file is rejected and logged, and the last valid settings are kept. The changes of the other
+// settings are logged and ignored until the service restarts.
+//
+// It returns an error if no config file was given. The file is watched until the process exits; if Initialize is
+// invoked again with another config file, the new file is watched instead.
+func WatchConfigFile() error {
+	file := ConfigFile()
+	if len(file) == 0 {
+		return errors.New("failed to watch the config file: no config file was given")
+	}
+	watchMu.Lock()
+	defer watchMu.Unlock()
+	if watched == file {
+		return nil
+	}
+
+	fv := viper.New()
+	fv.SetConfigFile(file)
+	if err := fv.ReadInConfig(); err != nil {
+		return fmt.Errorf("failed to watch the config file %s: %w", file, err)
+	}
+	fv.OnConfigChange(func(fsnotify.Event) {
+		if err := reloadConfigFile(file); err != nil {
+			logger.Error(err, "the config file change was rejected; the last valid settings are kept",
+				logger.Attribute{Key: "file", Value: file})
+		}
+	})
+	fv.WatchConfig()
+	watched = file
+	return nil
+}
+
+// reloadConfigFile reads the config file again, and applies and publishes the changes of the reloadable settings
+// if they are all valid. The changes of a file that is no longer the config file are ignored.
+func reloadConfigFile(file string) error {
+	// viper keeps its previous content if the file cannot be parsed, so the file is read again to detect it.
+	fv := viper.New()
+	fv.SetConfigFile(file)
+	if err := fv.ReadInConfig(); err != nil {
+		return fmt.Errorf("failed to read the config file %s: %w", file, err)
+	}
+	if len(fv.AllKeys()) == 0 {
+		// the file is being written: it is truncated before its new content is written.
+		return nil
+	}
+	values, err := parseConfigFile(fv)
+	if err != nil {
+		return fmt.Errorf("invalid config file %s: %w", file, err)
+	}
+
+	mu.Lock()
+	if src == nil || file != src.configFile {
+		mu.Unlock()
+		return nil
+	}
+	var changed, ignored []string
+	var errs []error
+	c := *cfg
+	for _, s := range settings {
+		if values[s.envVar] == src.fileValues[s.envVar] || src.isSet(s.envVar) {
+			continue
+		}
+		reload, ok := reloadable[s.envVar]
+		if !ok {
+			ignored = append(ignored, s.key)
+			continue
+		}
+		if err := reload(&c, values[s.envVar]); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", s.key, err))
+			continue
+		}
+		changed = append(changed, s.envVar)
+	}
+	if err := errors.Join(errs...); err != nil {
+		mu.Unlock()
+		return fmt.Errorf("invalid config file %s: %w", file, err)
+	}
+	for _, envVar := range changed {
+		src.fileValues[envVar] = values[envVar]
+	}
+	cfg = &c
+	mu.Unlock()
+
+	if len(ignored) > 0 {
+		sort.Strings(ignored)
+		logger.Warn("settings of the config file changed that are only applied when the service restarts",
+			logger.Attribute{Key: "settings", Value: ignored})
+	}
+	if len(changed) == 0 {
+		return nil
+	}
+	logger.Info("config file reloaded", logger.Attribute{Key: "settings", Value: changed})
+	publish(Change{Settings: changed})
+	return nil
+}
@@ -0,0 +1,103 @@
+package observeCfg
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// the samplers defined by the OpenTelemetry specification.
+const (
+	AlwaysOnSampler                = "always_on"
+	AlwaysOffSampler               = "always_off"
+	TraceIDRatioSampler            = "traceidratio"
+	ParentBasedAlwaysOnSampler     = "parentbased_always_on"
+	ParentBasedAlwaysOffSampler    = "parentbased_always_off"
+	ParentBasedTraceIDRatioSampler = "parentbased_traceidratio"
+)
+
+// deploymentEnvironmentKeys are the resource attributes of the environment, the current one first.
+var deploymentEnvironmentKeys = []string{"deployment.environment.name", "deployment.environment"}
+
+// reloadSampler validates a new sampler, and sets it in c.
+func reloadSampler(c *Config, value string) error {
+	if len(value) == 0 {
+		value = AlwaysOnSampler
+	}
+	if err := validateSampler(value); err != nil {
+		return err
+	}
+	c.TracesSampler = value
+	return nil
+}
+
+// reloadSamplerArg validates a new sampling ratio, and sets it in c.
+func reloadSamplerArg(c *Config, value string) (err error) {
+	c.TracesSamplerArg, err = parseSamplerArg(value)
+	return err
+}
+
+func validateSampler(name string) error {
+	switch name {
+	case AlwaysOnSampler, AlwaysOffSampler, TraceIDRatioSampler,
+		ParentBasedAlwaysOnSampler, ParentBasedAlwaysOffSampler, ParentBasedTraceIDRatioSampler:
+		return nil
+	}
+	return fmt.Errorf("invalid traces sampler: %s; accepted values are `%s`, `%s`, `%s`, `%s`, `%s`, and `%s`",
+		name, AlwaysOnSampler, AlwaysOffSampler, TraceIDRatioSampler,
+		ParentBasedAlwaysOnSampler, ParentBasedAlwaysOffSampler, ParentBasedTraceIDRatioSampler)
+}
+
+// parseSamplerArg parses the sampling ratio of the ratio samplers. If s is empty, every trace is sampled.
+func parseSamplerArg(s string) (float64, error) {
+	if len(s) == 0 {
+		return 1, nil
+	}
+	arg, err := strconv.ParseFloat(s, 64)
+	if err != nil || arg < 0 || arg > 1 {
+		return 0, fmt.Errorf("invalid traces sampler arg: %s; it must be a ratio between 0 and 1", s)
+	}
+	return arg, nil
+}
+
+// resourceAttribute returns the value of the first of the keys found in resourceAttrs, formatted as
+// `OTEL_RESOURCE_ATTRIBUTES`.
+func resourceAttribute(resourceAttrs string, keys ...string) string {
+	attrs := make(map[string]string)
+	for _, pair := range strings.Split(resourceAttrs, ",") {
+		k, v, ok := strings.Cut(pair, "=")
+		if !ok {
+			continue
+		}
+		if uv, err := url.QueryUnescape(strings.TrimSpace(v)); err == nil {
+			attrs[strings.TrimSpace(k)] = uv
+		}
+	}
+	for _, k := range keys {
+		if v, ok := attrs[k]; ok {
+			return v
+		}
+	}
+	return ""
+}
+
+// TracesSampler returns the sampler of the spans: `always_on`, the default, `always_off`, `traceidratio`,
+// `parentbased_always_on`, `parentbased_always_off` or `parentbased_traceidratio`. It is set by the environment
+// variable `OTEL_TRACES_SAMPLER`, and is reloaded when the config file changes.
+func TracesSampler() string {
+	return current().TracesSampler
+}
+
+// TracesSamplerArg returns the ratio of the traces sampled by the `traceidratio` and `parentbased_traceidratio`
+// samplers. It is set by the environment variable `OTEL_TRACES_SAMPLER_ARG`, defaults to 1, and is reloaded when the
+// config file changes.
+func TracesSamplerArg() float64 {
+	return current().TracesSamplerArg
+}
+
+// SDKDisabled returns true if the telemetry is disabled: the tracer and the metrics are then no-ops, and no endpoint
+// is required. It is set by the environment variable `OTEL_SDK_DISABLED`.
+func SDKDisabled() bool {
+	return current().SDKDisabled
+}
@@ -0,0 +1,97 @@
+package observeCfg
+
+import (
+	"fmt"
+	"strings"
+)
+
+// MetricView changes how an instrument is exported.
+type MetricView struct {
+	// Instrument is the name of the instruments the view applies to, without the namespace and service name, e.g.
+	// `http.request.size`. It may contain the wildcards `*` and `?`, e.g. `job.*`.
+	Instrument string
+	// Rename is the name the instrument is exported as, without the namespace and service name. It cannot be used
+	// with wildcards.
+	Rename string
+	// DropAttributes are the attribute keys removed from the measurements of the instrument.
+	DropAttributes []string
+	// Disabled drops the instrument entirely.
+	Disabled bool
+}
+
+func (v MetricView) validate() error {
+	if len(v.Instrument) == 0 {
+		return fmt.Errorf("invalid metric view: the instrument name is empty")
+	}
+	if len(v.Rename) > 0 && strings.ContainsAny(v.Instrument, "*?") {
+		return fmt.Errorf("invalid metric view: %s; an instrument name with wildcards cannot be renamed", v.Instrument)
+	}
+	return nil
+}
+
+// parseViews parses views formatted as a semicolon separated list of `instrument:options`, where the options are a
+// comma separated list of `rename=name`, `drop=key1|key2` and `disable`. For example,
+// `http.request_duration_seconds:rename=http.server.duration,drop=http.status_code;http.request.size:disable`.
+func parseViews(s string) ([]MetricView, error) {
+	var vs []MetricView
+	for _, spec := range strings.Split(s, ";") {
+		spec = strings.TrimSpace(spec)
+		if len(spec) == 0 {
+			continue
+		}
+		inst, opts, _ := strings.Cut(spec, ":")
+		v := MetricView{Instrument: strings.TrimSpace(inst)}
+		for _, opt := range strings.Split(opts, ",") {
+			k, val, _ := strings.Cut(strings.TrimSpace(opt), "=")
+			switch k {
+			case "":
+			case "rename":
+				v.Rename = strings.TrimSpace(val)
+			case "drop":
+				for _, a := range strings.Split(val, "|") {
+					if a = strings.TrimSpace(a); len(a) > 0 {
+						v.DropAttributes = append(v.DropAttributes, a)
+					}
+				}
+			case "disable":
+				v.Disabled = true
+			default:
+				return nil, fmt.Errorf("invalid metric view: %s; accepted options are `rename=<name>`, `drop=<key>|<key>`, and `disable`", spec)
+			}
+		}
+		if err := v.validate(); err != nil {
+			return nil, err
+		}
+		vs = append(vs, v)
+	}
+	return vs, nil
+}
+
+// parseTemporalityOverrides parses the temporality of instrument kinds formatted as a comma separated list of
+// `kind=temporality`.
+func parseTemporalityOverrides(s string) (map[string]string, error) {
+	o := make(map[string]string)
+	for _, pair := range strings.Split(s, ",") {
+		if len(strings.TrimSpace(pair)) == 0 {
+			continue
+		}
+		k, v, _ := strings.Cut(pair, "=")
+		k, v = strings.TrimSpace(k), strings.TrimSpace(v)
+		switch k {
+		case CounterKind, UpDownCounterKind, HistogramKind, GaugeKind,
+			ObservableCounterKind, ObservableUpDownCounterKind, ObservableGaugeKind:
+		default:
+			return nil, fmt.Errorf("invalid temporality override: %s; accepted kinds are `%s`, `%s`, `%s`, `%s`, `%s`, `%s`, and `%s`",
+				pair, CounterKind, UpDownCounterKind, HistogramKind, GaugeKind,
+				ObservableCounterKind, ObservableUpDownCounterKind, ObservableGaugeKind)
+		}
+		switch v {
+		case CumulativeTemporality, DeltaTemporality:
+		default:
+			return nil, fmt.Errorf("invalid temporality override: %s; accepted temporalities are `%s` and `%s`",
+				pair, CumulativeTemporality, DeltaTemporality)
+		}
+		o[k] = v
+	}
+	return o, nil
+}
@@ -0,0 +1,118 @@
+package otlpHttp
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/twistingmercury/observability/observeCfg"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	jsonContentType     = "application/json"
+	protobufContentType = "application/x-protobuf"
+)
+
+// client sends OTLP requests, encoded as JSON or protobuf, to a single URL.
+type client struct {
+	url         string
+	protocol    string
+	opts        Options
+	compression string
+	timeout     time.Duration
+	http        *http.Client
+}
+
+func newClient(t target, opts Options) *client {
+	transport := &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: (&net.Dialer{
+			Timeout:   30 * time.Second,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		ForceAttemptHTTP2:     true,
+		MaxIdleConns:          100,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: 1 * time.Second,
+	}
+	if !t.insecure && opts.TLSConfig != nil {
+		transport.TLSClientConfig = opts.TLSConfig.Clone()
+	}
+
+	return &client{
+		url:         t.url(),
+		protocol:    opts.Protocol,
+		opts:        opts,
+		compression: opts.Compression,
+		timeout:     opts.timeout(),
+		http:        &http.Client{Transport: transport},
+	}
+}
+
+// send posts msg, encoded as JSON or protobuf, to the collector.
+func (c *client) send(ctx context.Context, msg proto.Message) error {
+	contentType := jsonContentType
+	marshal := marshalJSON
+	if c.protocol != observeCfg.HttpJsonProtocol {
+		contentType = protobufContentType
+		marshal = proto.Marshal
+	}
+	body, err := marshal(msg)
+	if err != nil {
+		return fmt.Errorf("failed to marshal the export request: %w", err)
+	}
+
+	if c.compression == observeCfg.GzipCompression {
+		var buf bytes.Buffer
+		gz := gzip.NewWriter(&buf)
+		if _, err := gz.Write(body); err != nil {
+			return fmt.Errorf("failed to compress the export request: %w", err)
+		}
+		if err := gz.Close(); err != nil {
+			return fmt.Errorf("failed to compress the export request: %w", err)
+		}
+		body = buf.Bytes()
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create the export request: %w", err)
+	}
+	headers, err := c.opts.requestHeaders(ctx, c.url)
+	if err != nil {
+		return err
+	}
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	req.Header.Set("Content-Type", contentType)
+	if c.compression == observeCfg.GzipCompression {
+		req.Header.Set("Content-Encoding", "gzip")
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send the export request: %w", err)
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("failed to send the export request to %s: %s", c.url, resp.Status)
+	}
+	return nil
+}
+
+func (c *client) close() {
+	c.http.CloseIdleConnections()
+}
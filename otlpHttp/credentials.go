@@ -0,0 +1,165 @@
+package otlpHttp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"sync"
+
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace"
+	sdkMetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
+)
+
+// requestHeaders returns the headers of an export request sent to url: the Headers of opts, and the resolved
+// Credentials.
+func (opts Options) requestHeaders(ctx context.Context, url string) (map[string]string, error) {
+	h := maps.Clone(opts.Headers)
+	if h == nil {
+		h = make(map[string]string)
+	}
+	if opts.Credentials != nil {
+		creds, err := opts.Credentials.GetRequestMetadata(ctx, url)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve the export request headers: %w", err)
+		}
+		maps.Copy(h, creds)
+	}
+	return h, nil
+}
+
+// credentialsTraceClient is the otlptrace.Client that uploads spans using the client of the otlptracehttp package,
+// whose headers are static. The client is created again when the resolved Credentials change, e.g. when a secret
+// file is rotated, so that the retries of the official client are kept.
+type credentialsTraceClient struct {
+	opts      Options
+	url       string
+	newClient func(headers map[string]string) otlptrace.Client
+
+	// mu serializes the uploads, so that a client is not stopped while it uploads spans.
+	mu      sync.Mutex
+	headers map[string]string
+	client  otlptrace.Client
+	stopped bool
+}
+
+func (c *credentialsTraceClient) Start(context.Context) error {
+	return nil
+}
+
+func (c *credentialsTraceClient) Stop(ctx context.Context) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.stopped = true
+	if c.client == nil {
+		return nil
+	}
+	return c.client.Stop(ctx)
+}
+
+func (c *credentialsTraceClient) UploadTraces(ctx context.Context, protoSpans []*tracepb.ResourceSpans) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.stopped {
+		return errors.New("the trace client is stopped")
+	}
+	h, err := c.opts.requestHeaders(ctx, c.url)
+	if err != nil {
+		return err
+	}
+	if c.client == nil || !maps.Equal(h, c.headers) {
+		client := c.newClient(h)
+		if err := client.Start(ctx); err != nil {
+			return err
+		}
+		if c.client != nil {
+			_ = c.client.Stop(ctx)
+		}
+		c.client, c.headers = client, h
+	}
+	return c.client.UploadTraces(ctx, protoSpans)
+}
+
+// credentialsMetricExporter is the sdkMetric.Exporter that sends metrics using the exporter of the otlpmetrichttp
+// package, whose headers are static. The exporter is created again when the resolved Credentials change.
+type credentialsMetricExporter struct {
+	opts        Options
+	url         string
+	newExporter func(ctx context.Context, headers map[string]string) (sdkMetric.Exporter, error)
+
+	// mu serializes the exports, so that an exporter is not shut down while it exports metrics.
+	mu       sync.Mutex
+	headers  map[string]string
+	exporter sdkMetric.Exporter
+	shutdown bool
+}
+
+// newCredentialsMetricExporter returns the credentialsMetricExporter sending the currently resolved Credentials.
+func newCredentialsMetricExporter(ctx context.Context, opts Options, url string,
+	newExporter func(context.Context, map[string]string) (sdkMetric.Exporter, error)) (*credentialsMetricExporter, error) {
+	e := &credentialsMetricExporter{opts: opts, url: url, newExporter: newExporter}
+	if err := e.refresh(ctx); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// refresh creates the exporter again if the resolved headers changed; e.mu is held by the caller, unless e is being
+// created.
+func (e *credentialsMetricExporter) refresh(ctx context.Context) error {
+	h, err := e.opts.requestHeaders(ctx, e.url)
+	if err != nil {
+		return err
+	}
+	if e.exporter != nil && maps.Equal(h, e.headers) {
+		return nil
+	}
+	exp, err := e.newExporter(ctx, h)
+	if err != nil {
+		return err
+	}
+	if e.exporter != nil {
+		_ = e.exporter.Shutdown(ctx)
+	}
+	e.exporter, e.headers = exp, h
+	return nil
+}
+
+func (e *credentialsMetricExporter) Temporality(k sdkMetric.InstrumentKind) metricdata.Temporality {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.exporter.Temporality(k)
+}
+
+func (e *credentialsMetricExporter) Aggregation(k sdkMetric.InstrumentKind) sdkMetric.Aggregation {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.exporter.Aggregation(k)
+}
+
+func (e *credentialsMetricExporter) Export(ctx context.Context, rm *metricdata.ResourceMetrics) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.shutdown {
+		return sdkMetric.ErrExporterShutdown
+	}
+	if err := e.refresh(ctx); err != nil {
+		return err
+	}
+	return e.exporter.Export(ctx, rm)
+}
+
+func (e *credentialsMetricExporter) ForceFlush(ctx context.Context) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.exporter.ForceFlush(ctx)
+}
+
+func (e *credentialsMetricExporter) Shutdown(ctx context.Context) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.shutdown = true
+	return e.exporter.Shutdown(ctx)
+}
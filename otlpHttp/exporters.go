@@ -0,0 +1,144 @@
+package otlpHttp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/twistingmercury/observability/observeCfg"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetrichttp"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	sdkMetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
+)
+
+// NewTraceExporter returns an exporter that sends spans to the collector using the protocol in opts.
+func NewTraceExporter(ctx context.Context, opts Options) (sdktrace.SpanExporter, error) {
+	t, err := parseEndpoint(opts, TracesPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create the trace exporter: %w", err)
+	}
+	opts = opts.secured(t)
+
+	var client otlptrace.Client
+	switch opts.Protocol {
+	case observeCfg.HttpJsonProtocol:
+		client = &traceClient{client: newClient(t, opts)}
+	case observeCfg.HttpProtobufProtocol, "":
+		newTraceClient := func(headers map[string]string) otlptrace.Client {
+			return otlptracehttp.NewClient(traceOptions(t, opts, headers)...)
+		}
+		if opts.Credentials != nil {
+			client = &credentialsTraceClient{opts: opts, url: t.url(), newClient: newTraceClient}
+		} else {
+			client = newTraceClient(opts.Headers)
+		}
+	default:
+		return nil, fmt.Errorf("failed to create the trace exporter: unsupported protocol %s", opts.Protocol)
+	}
+	if opts.ExportResult != nil {
+		client = resultTraceClient{Client: client, result: opts.ExportResult}
+	}
+	return otlptrace.New(ctx, client)
+}
+
+// NewMetricExporter returns an exporter that sends metrics to the collector using the protocol in opts.
+func NewMetricExporter(ctx context.Context, opts Options) (sdkMetric.Exporter, error) {
+	t, err := parseEndpoint(opts, MetricsPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create the metrics exporter: %w", err)
+	}
+	opts = opts.secured(t)
+
+	var exp sdkMetric.Exporter
+	switch opts.Protocol {
+	case observeCfg.HttpJsonProtocol:
+		exp = &metricExporter{client: newClient(t, opts)}
+	case observeCfg.HttpProtobufProtocol, "":
+		newExporter := func(ctx context.Context, headers map[string]string) (sdkMetric.Exporter, error) {
+			return otlpmetrichttp.New(ctx, metricOptions(t, opts, headers)...)
+		}
+		if opts.Credentials != nil {
+			exp, err = newCredentialsMetricExporter(ctx, opts, t.url(), newExporter)
+		} else {
+			exp, err = newExporter(ctx, opts.Headers)
+		}
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("failed to create the metrics exporter: unsupported protocol %s", opts.Protocol)
+	}
+	if opts.ExportResult != nil {
+		exp = resultMetricExporter{Exporter: exp, result: opts.ExportResult}
+	}
+	return exp, nil
+}
+
+// resultTraceClient is the otlptrace.Client passing the outcome of every upload to Options.ExportResult.
+type resultTraceClient struct {
+	otlptrace.Client
+	result func(error)
+}
+
+func (c resultTraceClient) UploadTraces(ctx context.Context, protoSpans []*tracepb.ResourceSpans) error {
+	err := c.Client.UploadTraces(ctx, protoSpans)
+	c.result(err)
+	return err
+}
+
+// resultMetricExporter is the sdkMetric.Exporter passing the outcome of every export to Options.ExportResult. The
+// exports refused once the exporter is shut down are not passed.
+type resultMetricExporter struct {
+	sdkMetric.Exporter
+	result func(error)
+}
+
+func (e resultMetricExporter) Export(ctx context.Context, rm *metricdata.ResourceMetrics) error {
+	err := e.Exporter.Export(ctx, rm)
+	if !errors.Is(err, sdkMetric.ErrExporterShutdown) {
+		e.result(err)
+	}
+	return err
+}
+
+// traceOptions returns the options of the otlptracehttp client sending headers.
+func traceOptions(t target, opts Options, headers map[string]string) []otlptracehttp.Option {
+	tOpts := []otlptracehttp.Option{
+		otlptracehttp.WithEndpoint(t.host),
+		otlptracehttp.WithURLPath(t.path),
+		otlptracehttp.WithHeaders(headers),
+		otlptracehttp.WithTimeout(opts.timeout()),
+	}
+	if t.insecure {
+		tOpts = append(tOpts, otlptracehttp.WithInsecure())
+	} else if opts.TLSConfig != nil {
+		tOpts = append(tOpts, otlptracehttp.WithTLSClientConfig(opts.TLSConfig))
+	}
+	if opts.Compression == observeCfg.GzipCompression {
+		tOpts = append(tOpts, otlptracehttp.WithCompression(otlptracehttp.GzipCompression))
+	}
+	return tOpts
+}
+
+// metricOptions returns the options of the otlpmetrichttp exporter sending headers.
+func metricOptions(t target, opts Options, headers map[string]string) []otlpmetrichttp.Option {
+	mOpts := []otlpmetrichttp.Option{
+		otlpmetrichttp.WithEndpoint(t.host),
+		otlpmetrichttp.WithURLPath(t.path),
+		otlpmetrichttp.WithHeaders(headers),
+		otlpmetrichttp.WithTimeout(opts.timeout()),
+	}
+	if t.insecure {
+		mOpts = append(mOpts, otlpmetrichttp.WithInsecure())
+	} else if opts.TLSConfig != nil {
+		mOpts = append(mOpts, otlpmetrichttp.WithTLSClientConfig(opts.TLSConfig))
+	}
+	if opts.Compression == observeCfg.GzipCompression {
+		mOpts = append(mOpts, otlpmetrichttp.WithCompression(otlpmetrichttp.GzipCompression))
+	}
+	return mOpts
+}
@@ -0,0 +1,64 @@
+package otlpHttp
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// idFields are the JSON names of the fields holding a trace or span ID, e.g. of a span, a link or an exemplar.
+var idFields = map[string]bool{"traceId": true, "spanId": true, "parentSpanId": true}
+
+// marshalJSON encodes msg as OTLP/JSON. It differs from the protobuf JSON mapping in that the trace and span IDs
+// are hex strings rather than base64 strings, and the enums are integers rather than names.
+func marshalJSON(msg proto.Message) ([]byte, error) {
+	b, err := protojson.MarshalOptions{UseEnumNumbers: true}.Marshal(msg)
+	if err != nil {
+		return nil, err
+	}
+
+	// the numbers are kept as they are, the 64 bit integers not fitting in a float64.
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	if err := hexIDs(v); err != nil {
+		return nil, err
+	}
+	return json.Marshal(v)
+}
+
+// hexIDs replaces the base64 trace and span IDs found in v by their hex encoding. The attributes being lists of
+// key and value objects, an attribute named like an ID field is not replaced.
+func hexIDs(v any) error {
+	switch v := v.(type) {
+	case map[string]any:
+		for k, f := range v {
+			if s, ok := f.(string); ok && idFields[k] {
+				id, err := base64.StdEncoding.DecodeString(s)
+				if err != nil {
+					return fmt.Errorf("invalid %s %s: %w", k, s, err)
+				}
+				v[k] = hex.EncodeToString(id)
+				continue
+			}
+			if err := hexIDs(f); err != nil {
+				return err
+			}
+		}
+	case []any:
+		for _, f := range v {
+			if err := hexIDs(f); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,332 @@
+package otlpHttp
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	sdkMetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	"go.opentelemetry.io/otel/sdk/resource"
+	colmetricpb "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	metricpb "go.opentelemetry.io/proto/otlp/metrics/v1"
+	resourcepb "go.opentelemetry.io/proto/otlp/resource/v1"
+)
+
+// metricExporter is the sdkMetric.Exporter that sends metrics encoded as JSON.
+type metricExporter struct {
+	client *client
+
+	mu       sync.Mutex
+	shutdown bool
+}
+
+func (e *metricExporter) Temporality(k sdkMetric.InstrumentKind) metricdata.Temporality {
+	return sdkMetric.DefaultTemporalitySelector(k)
+}
+
+func (e *metricExporter) Aggregation(k sdkMetric.InstrumentKind) sdkMetric.Aggregation {
+	return sdkMetric.DefaultAggregationSelector(k)
+}
+
+func (e *metricExporter) Export(ctx context.Context, rm *metricdata.ResourceMetrics) error {
+	e.mu.Lock()
+	shutdown := e.shutdown
+	e.mu.Unlock()
+	if shutdown {
+		return sdkMetric.ErrExporterShutdown
+	}
+
+	pb, err := resourceMetrics(rm)
+	if err != nil {
+		return err
+	}
+	return e.client.send(ctx, &colmetricpb.ExportMetricsServiceRequest{ResourceMetrics: []*metricpb.ResourceMetrics{pb}})
+}
+
+func (e *metricExporter) ForceFlush(ctx context.Context) error {
+	return ctx.Err()
+}
+
+func (e *metricExporter) Shutdown(ctx context.Context) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if !e.shutdown {
+		e.shutdown = true
+		e.client.close()
+	}
+	return ctx.Err()
+}
+
+// resourceMetrics converts the metrics collected by the SDK to their OTLP representation.
+func resourceMetrics(rm *metricdata.ResourceMetrics) (*metricpb.ResourceMetrics, error) {
+	pb := &metricpb.ResourceMetrics{
+		Resource:     resourceProto(rm.Resource),
+		ScopeMetrics: make([]*metricpb.ScopeMetrics, 0, len(rm.ScopeMetrics)),
+	}
+	if rm.Resource != nil {
+		pb.SchemaUrl = rm.Resource.SchemaURL()
+	}
+
+	for _, sm := range rm.ScopeMetrics {
+		spb := &metricpb.ScopeMetrics{
+			Scope: &commonpb.InstrumentationScope{
+				Name:    sm.Scope.Name,
+				Version: sm.Scope.Version,
+			},
+			SchemaUrl: sm.Scope.SchemaURL,
+			Metrics:   make([]*metricpb.Metric, 0, len(sm.Metrics)),
+		}
+		for _, m := range sm.Metrics {
+			mpb, err := metricProto(m)
+			if err != nil {
+				return nil, err
+			}
+			spb.Metrics = append(spb.Metrics, mpb)
+		}
+		pb.ScopeMetrics = append(pb.ScopeMetrics, spb)
+	}
+	return pb, nil
+}
+
+func metricProto(m metricdata.Metrics) (*metricpb.Metric, error) {
+	pb := &metricpb.Metric{Name: m.Name, Description: m.Description, Unit: m.Unit}
+
+	switch a := m.Data.(type) {
+	case metricdata.Gauge[int64]:
+		pb.Data = &metricpb.Metric_Gauge{Gauge: &metricpb.Gauge{DataPoints: dataPoints(a.DataPoints)}}
+	case metricdata.Gauge[float64]:
+		pb.Data = &metricpb.Metric_Gauge{Gauge: &metricpb.Gauge{DataPoints: dataPoints(a.DataPoints)}}
+	case metricdata.Sum[int64]:
+		pb.Data = &metricpb.Metric_Sum{Sum: &metricpb.Sum{
+			AggregationTemporality: temporality(a.Temporality),
+			IsMonotonic:            a.IsMonotonic,
+			DataPoints:             dataPoints(a.DataPoints),
+		}}
+	case metricdata.Sum[float64]:
+		pb.Data = &metricpb.Metric_Sum{Sum: &metricpb.Sum{
+			AggregationTemporality: temporality(a.Temporality),
+			IsMonotonic:            a.IsMonotonic,
+			DataPoints:             dataPoints(a.DataPoints),
+		}}
+	case metricdata.Histogram[int64]:
+		pb.Data = &metricpb.Metric_Histogram{Histogram: &metricpb.Histogram{
+			AggregationTemporality: temporality(a.Temporality),
+			DataPoints:             histogramDataPoints(a.DataPoints),
+		}}
+	case metricdata.Histogram[float64]:
+		pb.Data = &metricpb.Metric_Histogram{Histogram: &metricpb.Histogram{
+			AggregationTemporality: temporality(a.Temporality),
+			DataPoints:             histogramDataPoints(a.DataPoints),
+		}}
+	case metricdata.ExponentialHistogram[int64]:
+		pb.Data = &metricpb.Metric_ExponentialHistogram{ExponentialHistogram: &metricpb.ExponentialHistogram{
+			AggregationTemporality: temporality(a.Temporality),
+			DataPoints:             exponentialHistogramDataPoints(a.DataPoints),
+		}}
+	case metricdata.ExponentialHistogram[float64]:
+		pb.Data = &metricpb.Metric_ExponentialHistogram{ExponentialHistogram: &metricpb.ExponentialHistogram{
+			AggregationTemporality: temporality(a.Temporality),
+			DataPoints:             exponentialHistogramDataPoints(a.DataPoints),
+		}}
+	case metricdata.Summary:
+		pb.Data = &metricpb.Metric_Summary{Summary: &metricpb.Summary{DataPoints: summaryDataPoints(a.DataPoints)}}
+	default:
+		return nil, fmt.Errorf("unsupported aggregation %T of metric %s", m.Data, m.Name)
+	}
+	return pb, nil
+}
+
+func dataPoints[N int64 | float64](dps []metricdata.DataPoint[N]) []*metricpb.NumberDataPoint {
+	out := make([]*metricpb.NumberDataPoint, 0, len(dps))
+	for _, dp := range dps {
+		pb := &metricpb.NumberDataPoint{
+			Attributes:        attributes(dp.Attributes.ToSlice()),
+			StartTimeUnixNano: unixNano(dp.StartTime),
+			TimeUnixNano:      unixNano(dp.Time),
+			Exemplars:         exemplars(dp.Exemplars),
+		}
+		switch v := any(dp.Value).(type) {
+		case int64:
+			pb.Value = &metricpb.NumberDataPoint_AsInt{AsInt: v}
+		case float64:
+			pb.Value = &metricpb.NumberDataPoint_AsDouble{AsDouble: v}
+		}
+		out = append(out, pb)
+	}
+	return out
+}
+
+func histogramDataPoints[N int64 | float64](dps []metricdata.HistogramDataPoint[N]) []*metricpb.HistogramDataPoint {
+	out := make([]*metricpb.HistogramDataPoint, 0, len(dps))
+	for _, dp := range dps {
+		sum := float64(dp.Sum)
+		pb := &metricpb.HistogramDataPoint{
+			Attributes:        attributes(dp.Attributes.ToSlice()),
+			StartTimeUnixNano: unixNano(dp.StartTime),
+			TimeUnixNano:      unixNano(dp.Time),
+			Count:             dp.Count,
+			Sum:               &sum,
+			BucketCounts:      dp.BucketCounts,
+			ExplicitBounds:    dp.Bounds,
+			Exemplars:         exemplars(dp.Exemplars),
+		}
+		if v, ok := dp.Min.Value(); ok {
+			vf := float64(v)
+			pb.Min = &vf
+		}
+		if v, ok := dp.Max.Value(); ok {
+			vf := float64(v)
+			pb.Max = &vf
+		}
+		out = append(out, pb)
+	}
+	return out
+}
+
+func exponentialHistogramDataPoints[N int64 | float64](dps []metricdata.ExponentialHistogramDataPoint[N]) []*metricpb.ExponentialHistogramDataPoint {
+	out := make([]*metricpb.ExponentialHistogramDataPoint, 0, len(dps))
+	for _, dp := range dps {
+		sum := float64(dp.Sum)
+		pb := &metricpb.ExponentialHistogramDataPoint{
+			Attributes:        attributes(dp.Attributes.ToSlice()),
+			StartTimeUnixNano: unixNano(dp.StartTime),
+			TimeUnixNano:      unixNano(dp.Time),
+			Count:             dp.Count,
+			Sum:               &sum,
+			Scale:             dp.Scale,
+			ZeroCount:         dp.ZeroCount,
+			ZeroThreshold:     dp.ZeroThreshold,
+			Positive: &metricpb.ExponentialHistogramDataPoint_Buckets{
+				Offset:       dp.PositiveBucket.Offset,
+				BucketCounts: dp.PositiveBucket.Counts,
+			},
+			Negative: &metricpb.ExponentialHistogramDataPoint_Buckets{
+				Offset:       dp.NegativeBucket.Offset,
+				BucketCounts: dp.NegativeBucket.Counts,
+			},
+			Exemplars: exemplars(dp.Exemplars),
+		}
+		if v, ok := dp.Min.Value(); ok {
+			vf := float64(v)
+			pb.Min = &vf
+		}
+		if v, ok := dp.Max.Value(); ok {
+			vf := float64(v)
+			pb.Max = &vf
+		}
+		out = append(out, pb)
+	}
+	return out
+}
+
+func summaryDataPoints(dps []metricdata.SummaryDataPoint) []*metricpb.SummaryDataPoint {
+	out := make([]*metricpb.SummaryDataPoint, 0, len(dps))
+	for _, dp := range dps {
+		pb := &metricpb.SummaryDataPoint{
+			Attributes:        attributes(dp.Attributes.ToSlice()),
+			StartTimeUnixNano: unixNano(dp.StartTime),
+			TimeUnixNano:      unixNano(dp.Time),
+			Count:             dp.Count,
+			Sum:               dp.Sum,
+		}
+		for _, q := range dp.QuantileValues {
+			pb.QuantileValues = append(pb.QuantileValues, &metricpb.SummaryDataPoint_ValueAtQuantile{
+				Quantile: q.Quantile,
+				Value:    q.Value,
+			})
+		}
+		out = append(out, pb)
+	}
+	return out
+}
+
+func exemplars[N int64 | float64](exs []metricdata.Exemplar[N]) []*metricpb.Exemplar {
+	if len(exs) == 0 {
+		return nil
+	}
+	out := make([]*metricpb.Exemplar, 0, len(exs))
+	for _, ex := range exs {
+		pb := &metricpb.Exemplar{
+			FilteredAttributes: attributes(ex.FilteredAttributes),
+			TimeUnixNano:       unixNano(ex.Time),
+			SpanId:             ex.SpanID,
+			TraceId:            ex.TraceID,
+		}
+		switch v := any(ex.Value).(type) {
+		case int64:
+			pb.Value = &metricpb.Exemplar_AsInt{AsInt: v}
+		case float64:
+			pb.Value = &metricpb.Exemplar_AsDouble{AsDouble: v}
+		}
+		out = append(out, pb)
+	}
+	return out
+}
+
+func temporality(t metricdata.Temporality) metricpb.AggregationTemporality {
+	switch t {
+	case metricdata.DeltaTemporality:
+		return metricpb.AggregationTemporality_AGGREGATION_TEMPORALITY_DELTA
+	case metricdata.CumulativeTemporality:
+		return metricpb.AggregationTemporality_AGGREGATION_TEMPORALITY_CUMULATIVE
+	default:
+		return metricpb.AggregationTemporality_AGGREGATION_TEMPORALITY_UNSPECIFIED
+	}
+}
+
+func resourceProto(r *resource.Resource) *resourcepb.Resource {
+	if r == nil {
+		return &resourcepb.Resource{}
+	}
+	return &resourcepb.Resource{Attributes: attributes(r.Attributes())}
+}
+
+func attributes(kvs []attribute.KeyValue) []*commonpb.KeyValue {
+	out := make([]*commonpb.KeyValue, 0, len(kvs))
+	for _, kv := range kvs {
+		out = append(out, &commonpb.KeyValue{Key: string(kv.Key), Value: anyValue(kv.Value)})
+	}
+	return out
+}
+
+func anyValue(v attribute.Value) *commonpb.AnyValue {
+	switch v.Type() {
+	case attribute.BOOL:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_BoolValue{BoolValue: v.AsBool()}}
+	case attribute.INT64:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_IntValue{IntValue: v.AsInt64()}}
+	case attribute.FLOAT64:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_DoubleValue{DoubleValue: v.AsFloat64()}}
+	case attribute.STRING:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: v.AsString()}}
+	case attribute.BOOLSLICE:
+		return arrayValue(v.AsBoolSlice(), attribute.BoolValue)
+	case attribute.INT64SLICE:
+		return arrayValue(v.AsInt64Slice(), attribute.Int64Value)
+	case attribute.FLOAT64SLICE:
+		return arrayValue(v.AsFloat64Slice(), attribute.Float64Value)
+	case attribute.STRINGSLICE:
+		return arrayValue(v.AsStringSlice(), attribute.StringValue)
+	default:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: v.Emit()}}
+	}
+}
+
+func arrayValue[T any](vs []T, value func(T) attribute.Value) *commonpb.AnyValue {
+	values := make([]*commonpb.AnyValue, 0, len(vs))
+	for _, v := range vs {
+		values = append(values, anyValue(value(v)))
+	}
+	return &commonpb.AnyValue{Value: &commonpb.AnyValue_ArrayValue{ArrayValue: &commonpb.ArrayValue{Values: values}}}
+}
+
+func unixNano(t time.Time) uint64 {
+	if t.IsZero() {
+		return 0
+	}
+	return uint64(t.UnixNano())
+}
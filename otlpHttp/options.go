@@ -0,0 +1,171 @@
+// Package otlpHttp provides the exporters used to send traces and metrics to an OpenTelemetry collector using
+// OTLP over HTTP, encoded either as protobuf or as JSON. It is intended for environments where gRPC cannot be used,
+// for example where only HTTPS egress through a proxy is allowed. The proxy is taken from the `HTTPS_PROXY`,
+// `HTTP_PROXY` and `NO_PROXY` environment variables.
+package otlpHttp
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/twistingmercury/observability/logger"
+	"github.com/twistingmercury/observability/observeCfg"
+)
+
+const (
+	TracesPath  = "/v1/traces"
+	MetricsPath = "/v1/metrics"
+
+	defaultTimeout = 10 * time.Second
+)
+
+// Options are the options of the OTLP/HTTP exporters.
+type Options struct {
+	// Endpoint is either the host and port of the collector, e.g. `collector:4318`, or its URL, e.g.
+	// `https://collector:4318`. If the URL has a path, it is used instead of the default path of the signal.
+	Endpoint string
+	// Protocol is either observeCfg.HttpProtobufProtocol, the default, or observeCfg.HttpJsonProtocol.
+	Protocol string
+	// Headers are sent with every export request, e.g. the API key of the collector.
+	Headers map[string]string
+	// Credentials, if set, are resolved for every export request and their headers added to Headers, so that a
+	// rotated API key is sent without restarting the service. The protobuf exporters are then created again when
+	// the resolved headers change. Credentials requiring transport security are not sent using plain HTTP.
+	Credentials Credentials
+	// Compression is either observeCfg.GzipCompression or observeCfg.NoCompression, the default.
+	Compression string
+	// Timeout is the maximum time an export request may take. Defaults to 10 seconds.
+	Timeout time.Duration
+	// Insecure sends the requests using plain HTTP. It is implied by an `http://` endpoint.
+	Insecure bool
+	// TLSConfig is used for HTTPS requests. If nil, the system defaults are used.
+	TLSConfig *tls.Config
+	// ExportResult, if set, is called with the outcome of every export, nil if it succeeded, e.g. to report whether
+	// the collector accepts the telemetry.
+	ExportResult func(err error)
+}
+
+// Credentials returns the headers of an export request; *observeCfg.Credentials implements it.
+type Credentials interface {
+	GetRequestMetadata(ctx context.Context, uri ...string) (map[string]string, error)
+	// RequireTransportSecurity returns true if the headers must not be sent using plain HTTP.
+	RequireTransportSecurity() bool
+}
+
+// FromConfig returns the Options for the given endpoint that are defined by observeCfg. The exporter headers are not
+// sent to an `http://` endpoint, so that their secrets are not sent in plaintext, unless `OTEL_EXPORTER_OTLP_INSECURE`
+// is true.
+func FromConfig(endpoint string) (opts Options, err error) {
+	opts = Options{
+		Endpoint:    endpoint,
+		Protocol:    observeCfg.ExporterProtocol(),
+		Headers:     observeCfg.ExporterHeaders(),
+		Compression: observeCfg.ExporterCompression(),
+		Timeout:     observeCfg.ExporterTimeout(),
+		Insecure:    observeCfg.ExporterInsecure(),
+	}
+	// headers read from files are resolved for every request, the files changing when the secrets are rotated.
+	if creds := observeCfg.ExporterCredentials(); creds != nil && creds.FromFiles() {
+		opts.Credentials = creds
+	}
+	if t, tErr := parseEndpoint(opts, TracesPath); tErr == nil && t.insecure && !opts.Insecure && len(opts.Headers) > 0 {
+		logger.Warn("the exporter headers are not sent using plain HTTP; set OTEL_EXPORTER_OTLP_INSECURE to true to "+
+			"send them in plaintext", logger.Attribute{Key: "endpoint", Value: endpoint})
+		opts.Headers, opts.Credentials = nil, nil
+	}
+
+	ca, cert, key := observeCfg.ExporterCertificate(), observeCfg.ExporterClientCertificate(), observeCfg.ExporterClientKey()
+	if len(ca) == 0 && len(cert) == 0 {
+		return opts, nil
+	}
+	opts.TLSConfig = &tls.Config{MinVersion: tls.VersionTLS12}
+	if len(ca) > 0 {
+		pem, err := os.ReadFile(ca)
+		if err != nil {
+			return opts, fmt.Errorf("failed to read the exporter certificate: %w", err)
+		}
+		opts.TLSConfig.RootCAs = x509.NewCertPool()
+		if !opts.TLSConfig.RootCAs.AppendCertsFromPEM(pem) {
+			return opts, fmt.Errorf("no certificates found in %s", ca)
+		}
+	}
+	if len(cert) > 0 {
+		// the client certificate is presented for mutual TLS.
+		c, err := tls.LoadX509KeyPair(cert, key)
+		if err != nil {
+			return opts, fmt.Errorf("failed to load the exporter client certificate: %w", err)
+		}
+		opts.TLSConfig.Certificates = []tls.Certificate{c}
+	}
+	return opts, nil
+}
+
+// target is the parsed endpoint of a signal.
+type target struct {
+	host     string
+	path     string
+	insecure bool
+}
+
+// url returns the URL export requests are sent to.
+func (t target) url() string {
+	scheme := "https"
+	if t.insecure {
+		scheme = "http"
+	}
+	return fmt.Sprintf("%s://%s%s", scheme, t.host, t.path)
+}
+
+// parseEndpoint splits the endpoint into the host, the path, defaulting to defaultPath, and whether the
+// requests are sent using plain HTTP.
+func parseEndpoint(opts Options, defaultPath string) (target, error) {
+	if len(opts.Endpoint) == 0 {
+		return target{}, errors.New("the endpoint is empty")
+	}
+
+	t := target{host: opts.Endpoint, path: defaultPath, insecure: opts.Insecure}
+	if !strings.Contains(opts.Endpoint, "://") {
+		return t, nil
+	}
+
+	u, err := url.Parse(opts.Endpoint)
+	if err != nil {
+		return target{}, fmt.Errorf("invalid endpoint %s: %w", opts.Endpoint, err)
+	}
+	switch u.Scheme {
+	case "http":
+		t.insecure = true
+	case "https":
+	default:
+		return target{}, fmt.Errorf("invalid endpoint %s: the scheme must be http or https", opts.Endpoint)
+	}
+	t.host = u.Host
+	if len(strings.Trim(u.Path, "/")) > 0 {
+		t.path = u.Path
+	}
+	return t, nil
+}
+
+// secured returns opts without the Credentials if they require transport security and t is sent using plain HTTP.
+func (opts Options) secured(t target) Options {
+	if t.insecure && opts.Credentials != nil && opts.Credentials.RequireTransportSecurity() {
+		logger.Warn("the exporter credentials require transport security and are not sent using plain HTTP",
+			logger.Attribute{Key: "endpoint", Value: opts.Endpoint})
+		opts.Credentials = nil
+	}
+	return opts
+}
+
+func (opts Options) timeout() time.Duration {
+	if opts.Timeout <= 0 {
+		return defaultTimeout
+	}
+	return opts.Timeout
+}
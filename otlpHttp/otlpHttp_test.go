@@ -0,0 +1,412 @@
+package otlpHttp_test
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/twistingmercury/observability/observeCfg"
+	"github.com/twistingmercury/observability/otlpHttp"
+	"github.com/twistingmercury/observability/testTools"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	sdkMetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/exemplar"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	colmetricpb "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+var (
+	traceID = trace.TraceID{0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x09, 0x0a, 0x0b, 0x0c, 0x0d, 0x0e, 0x0f, 0x10}
+	spanID  = trace.SpanID{0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08}
+	spanCtx = trace.NewSpanContext(trace.SpanContextConfig{TraceID: traceID, SpanID: spanID, TraceFlags: trace.FlagsSampled})
+)
+
+// exportSpan exports a single server span, whose parent is spanCtx, using the trace exporter created with opts.
+func exportSpan(t *testing.T, opts otlpHttp.Options) error {
+	ctx := context.Background()
+	exp, err := otlpHttp.NewTraceExporter(ctx, opts)
+	require.NoError(t, err)
+
+	defer func() { _ = exp.Shutdown(ctx) }()
+	stub := tracetest.SpanStub{
+		Name:        "http-span",
+		SpanKind:    trace.SpanKindServer,
+		Parent:      spanCtx,
+		SpanContext: spanCtx.WithSpanID(trace.SpanID{0x0a, 0x0b, 0x0c, 0x0d, 0x0e, 0x0f, 0x10, 0x11}),
+	}
+	return exp.ExportSpans(ctx, tracetest.SpanStubs{stub}.Snapshots())
+}
+
+// exportMetrics records a counter and a histogram and exports them using the metrics exporter created with opts.
+func exportMetrics(t *testing.T, opts otlpHttp.Options) error {
+	ctx := context.Background()
+	exp, err := otlpHttp.NewMetricExporter(ctx, opts)
+	require.NoError(t, err)
+
+	mp := sdkMetric.NewMeterProvider(sdkMetric.WithReader(sdkMetric.NewPeriodicReader(exp)))
+	meter := mp.Meter("unit-test")
+	c, err := meter.Int64Counter("http.counter")
+	require.NoError(t, err)
+	h, err := meter.Float64Histogram("http.histogram")
+	require.NoError(t, err)
+
+	attrs := metric.WithAttributes(attribute.String("key", "value"))
+	c.Add(ctx, 2, attrs)
+	h.Record(ctx, 0.5, attrs)
+	return mp.Shutdown(ctx)
+}
+
+func TestTraceExporter(t *testing.T) {
+	tests := []struct {
+		name        string
+		protocol    string
+		compression string
+		contentType string
+		unmarshal   func([]byte, proto.Message) error
+	}{
+		{"protobuf", observeCfg.HttpProtobufProtocol, observeCfg.NoCompression, "application/x-protobuf", proto.Unmarshal},
+		{"protobuf_gzip", observeCfg.HttpProtobufProtocol, observeCfg.GzipCompression, "application/x-protobuf", proto.Unmarshal},
+		{"json", observeCfg.HttpJsonProtocol, observeCfg.NoCompression, "application/json", protojson.Unmarshal},
+		{"json_gzip", observeCfg.HttpJsonProtocol, observeCfg.GzipCompression, "application/json", protojson.Unmarshal},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := testTools.StartHttpReceiver()
+			defer r.Close()
+
+			err := exportSpan(t, otlpHttp.Options{
+				Endpoint:    r.URL,
+				Protocol:    tt.protocol,
+				Headers:     map[string]string{"api-key": "secret"},
+				Compression: tt.compression,
+			})
+			assert.NoError(t, err)
+
+			reqs := r.RequestsTo(otlpHttp.TracesPath)
+			require.Len(t, reqs, 1)
+			assert.Equal(t, tt.contentType, reqs[0].Header.Get("Content-Type"))
+			assert.Equal(t, "secret", reqs[0].Header.Get("api-key"))
+			if tt.compression == observeCfg.GzipCompression {
+				assert.Equal(t, "gzip", reqs[0].Header.Get("Content-Encoding"))
+			}
+
+			var msg coltracepb.ExportTraceServiceRequest
+			require.NoError(t, tt.unmarshal(reqs[0].Body, &msg))
+			require.Len(t, msg.ResourceSpans, 1)
+			require.Len(t, msg.ResourceSpans[0].ScopeSpans, 1)
+			require.Len(t, msg.ResourceSpans[0].ScopeSpans[0].Spans, 1)
+			assert.Equal(t, "http-span", msg.ResourceSpans[0].ScopeSpans[0].Spans[0].Name)
+
+			if tt.protocol == observeCfg.HttpJsonProtocol {
+				// OTLP/JSON encodes the IDs as hex strings and the enums as integers.
+				body := string(reqs[0].Body)
+				assert.Contains(t, body, `"traceId":"0102030405060708090a0b0c0d0e0f10"`)
+				assert.Contains(t, body, `"spanId":"0a0b0c0d0e0f1011"`)
+				assert.Contains(t, body, `"parentSpanId":"0102030405060708"`)
+				assert.Contains(t, body, `"kind":2`)
+			}
+		})
+	}
+}
+
+func TestMetricExporter(t *testing.T) {
+	tests := []struct {
+		name        string
+		protocol    string
+		compression string
+		contentType string
+		unmarshal   func([]byte, proto.Message) error
+	}{
+		{"protobuf", observeCfg.HttpProtobufProtocol, observeCfg.GzipCompression, "application/x-protobuf", proto.Unmarshal},
+		{"json", observeCfg.HttpJsonProtocol, observeCfg.NoCompression, "application/json", protojson.Unmarshal},
+		{"json_gzip", observeCfg.HttpJsonProtocol, observeCfg.GzipCompression, "application/json", protojson.Unmarshal},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := testTools.StartHttpReceiver()
+			defer r.Close()
+
+			err := exportMetrics(t, otlpHttp.Options{
+				Endpoint:    r.URL,
+				Protocol:    tt.protocol,
+				Headers:     map[string]string{"api-key": "secret"},
+				Compression: tt.compression,
+			})
+			assert.NoError(t, err)
+
+			reqs := r.RequestsTo(otlpHttp.MetricsPath)
+			require.NotEmpty(t, reqs)
+			assert.Equal(t, tt.contentType, reqs[0].Header.Get("Content-Type"))
+			assert.Equal(t, "secret", reqs[0].Header.Get("api-key"))
+
+			var msg colmetricpb.ExportMetricsServiceRequest
+			require.NoError(t, tt.unmarshal(reqs[0].Body, &msg))
+			require.Len(t, msg.ResourceMetrics, 1)
+			require.Len(t, msg.ResourceMetrics[0].ScopeMetrics, 1)
+
+			if tt.protocol == observeCfg.HttpJsonProtocol {
+				assert.Contains(t, string(reqs[0].Body), `"aggregationTemporality":2`)
+			}
+
+			metrics := msg.ResourceMetrics[0].ScopeMetrics[0].Metrics
+			require.Len(t, metrics, 2)
+			for _, m := range metrics {
+				switch m.Name {
+				case "http.counter":
+					dps := m.GetSum().GetDataPoints()
+					require.Len(t, dps, 1)
+					assert.Equal(t, int64(2), dps[0].GetAsInt())
+					assert.Equal(t, "key", dps[0].Attributes[0].Key)
+					assert.Equal(t, "value", dps[0].Attributes[0].Value.GetStringValue())
+				case "http.histogram":
+					dps := m.GetHistogram().GetDataPoints()
+					require.Len(t, dps, 1)
+					assert.Equal(t, uint64(1), dps[0].Count)
+					assert.Equal(t, 0.5, dps[0].GetSum())
+				default:
+					t.Errorf("unexpected metric %s", m.Name)
+				}
+			}
+		})
+	}
+}
+
+func TestMetricExporter_ExponentialHistogram(t *testing.T) {
+	r := testTools.StartHttpReceiver()
+	defer r.Close()
+
+	ctx := context.Background()
+	exp, err := otlpHttp.NewMetricExporter(ctx, otlpHttp.Options{Endpoint: r.URL, Protocol: observeCfg.HttpJsonProtocol})
+	require.NoError(t, err)
+
+	view := sdkMetric.NewView(sdkMetric.Instrument{Kind: sdkMetric.InstrumentKindHistogram},
+		sdkMetric.Stream{Aggregation: sdkMetric.AggregationBase2ExponentialHistogram{MaxSize: 160, MaxScale: 20}})
+	mp := sdkMetric.NewMeterProvider(sdkMetric.WithReader(sdkMetric.NewPeriodicReader(exp)), sdkMetric.WithView(view))
+	h, err := mp.Meter("unit-test").Float64Histogram("http.histogram")
+	require.NoError(t, err)
+	h.Record(ctx, 0.5)
+	h.Record(ctx, 2)
+	require.NoError(t, mp.Shutdown(ctx))
+
+	reqs := r.RequestsTo(otlpHttp.MetricsPath)
+	require.NotEmpty(t, reqs)
+	var msg colmetricpb.ExportMetricsServiceRequest
+	require.NoError(t, protojson.Unmarshal(reqs[0].Body, &msg))
+
+	dps := msg.ResourceMetrics[0].ScopeMetrics[0].Metrics[0].GetExponentialHistogram().GetDataPoints()
+	require.Len(t, dps, 1)
+	assert.Equal(t, uint64(2), dps[0].Count)
+	assert.Equal(t, 2.5, dps[0].GetSum())
+	assert.Equal(t, 0.5, dps[0].GetMin())
+	assert.Equal(t, 2.0, dps[0].GetMax())
+	assert.NotEmpty(t, dps[0].GetPositive().GetBucketCounts())
+}
+
+func TestMetricExporter_Exemplars(t *testing.T) {
+	r := testTools.StartHttpReceiver()
+	defer r.Close()
+
+	ctx := context.Background()
+	exp, err := otlpHttp.NewMetricExporter(ctx, otlpHttp.Options{Endpoint: r.URL, Protocol: observeCfg.HttpJsonProtocol})
+	require.NoError(t, err)
+
+	mp := sdkMetric.NewMeterProvider(sdkMetric.WithReader(sdkMetric.NewPeriodicReader(exp)),
+		sdkMetric.WithExemplarFilter(exemplar.AlwaysOnFilter))
+	c, err := mp.Meter("unit-test").Int64Counter("http.counter")
+	require.NoError(t, err)
+	c.Add(trace.ContextWithSpanContext(ctx, spanCtx), 3)
+	require.NoError(t, mp.Shutdown(ctx))
+
+	reqs := r.RequestsTo(otlpHttp.MetricsPath)
+	require.NotEmpty(t, reqs)
+	var msg colmetricpb.ExportMetricsServiceRequest
+	require.NoError(t, protojson.Unmarshal(reqs[0].Body, &msg))
+
+	dps := msg.ResourceMetrics[0].ScopeMetrics[0].Metrics[0].GetSum().GetDataPoints()
+	require.Len(t, dps, 1)
+	require.Len(t, dps[0].Exemplars, 1)
+	assert.Equal(t, int64(3), dps[0].Exemplars[0].GetAsInt())
+
+	// the exemplar refers to the span the measurement was recorded in, using hex IDs.
+	body := string(reqs[0].Body)
+	assert.Contains(t, body, `"traceId":"0102030405060708090a0b0c0d0e0f10"`)
+	assert.Contains(t, body, `"spanId":"0102030405060708"`)
+}
+
+func TestExporter_TLS(t *testing.T) {
+	r := testTools.StartHttpsReceiver()
+	defer r.Close()
+
+	pool := x509.NewCertPool()
+	pool.AddCert(r.Certificate())
+	for _, protocol := range []string{observeCfg.HttpProtobufProtocol, observeCfg.HttpJsonProtocol} {
+		opts := otlpHttp.Options{
+			Endpoint:  r.Listener.Addr().String(),
+			Protocol:  protocol,
+			TLSConfig: &tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12},
+		}
+		assert.NoError(t, exportSpan(t, opts), protocol)
+		assert.NoError(t, exportMetrics(t, opts), protocol)
+	}
+	assert.Len(t, r.RequestsTo(otlpHttp.TracesPath), 2)
+	assert.NotEmpty(t, r.RequestsTo(otlpHttp.MetricsPath))
+
+	// without the receiver's certificate, the requests are rejected.
+	err := exportSpan(t, otlpHttp.Options{Endpoint: r.URL, Protocol: observeCfg.HttpJsonProtocol})
+	assert.Error(t, err)
+}
+
+func TestExporter_Credentials(t *testing.T) {
+	tests := []struct {
+		name        string
+		protocol    string
+		contentType string
+		// official is true if the requests are sent by the otlp*http exporters, whose user agents start with `OTel`.
+		official bool
+	}{
+		{"protobuf", observeCfg.HttpProtobufProtocol, "application/x-protobuf", true},
+		{"json", observeCfg.HttpJsonProtocol, "application/json", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := testTools.StartHttpReceiver()
+			defer r.Close()
+
+			secret := filepath.Join(t.TempDir(), "api-key")
+			require.NoError(t, os.WriteFile(secret, []byte("secret\n"), 0o600))
+			opts := otlpHttp.Options{
+				Endpoint:    r.URL,
+				Protocol:    tt.protocol,
+				Headers:     map[string]string{"x-tenant": "a"},
+				Credentials: observeCfg.NewInsecureCredentials(map[string]string{"api-key": "${file:" + secret + "}"}),
+			}
+			require.NoError(t, exportSpan(t, opts))
+
+			// the secret is rotated; the next request sends the new key.
+			require.NoError(t, os.WriteFile(secret, []byte("rotated-secret\n"), 0o600))
+			require.NoError(t, exportSpan(t, opts))
+
+			reqs := r.RequestsTo(otlpHttp.TracesPath)
+			require.Len(t, reqs, 2)
+			assert.Equal(t, tt.contentType, reqs[0].Header.Get("Content-Type"))
+			assert.Equal(t, "a", reqs[0].Header.Get("x-tenant"))
+			assert.Equal(t, "secret", reqs[0].Header.Get("api-key"))
+			assert.Equal(t, "rotated-secret", reqs[1].Header.Get("api-key"))
+			assert.Equal(t, tt.official, strings.HasPrefix(reqs[1].Header.Get("User-Agent"), "OTel"))
+
+			var msg coltracepb.ExportTraceServiceRequest
+			if tt.protocol == observeCfg.HttpJsonProtocol {
+				require.NoError(t, protojson.Unmarshal(reqs[1].Body, &msg))
+			} else {
+				require.NoError(t, proto.Unmarshal(reqs[1].Body, &msg))
+			}
+			require.Len(t, msg.ResourceSpans, 1)
+
+			require.NoError(t, exportMetrics(t, opts))
+			mReqs := r.RequestsTo(otlpHttp.MetricsPath)
+			require.NotEmpty(t, mReqs)
+			assert.Equal(t, "rotated-secret", mReqs[0].Header.Get("api-key"))
+			assert.Equal(t, tt.official, strings.HasPrefix(mReqs[0].Header.Get("User-Agent"), "OTel"))
+		})
+	}
+}
+
+func TestExporter_CredentialsRequireTransportSecurity(t *testing.T) {
+	for _, protocol := range []string{observeCfg.HttpProtobufProtocol, observeCfg.HttpJsonProtocol} {
+		t.Run(protocol, func(t *testing.T) {
+			r := testTools.StartHttpReceiver()
+			defer r.Close()
+
+			opts := otlpHttp.Options{
+				Endpoint:    r.URL,
+				Protocol:    protocol,
+				Credentials: observeCfg.NewCredentials(map[string]string{"api-key": "secret"}),
+			}
+			require.NoError(t, exportSpan(t, opts))
+			require.NoError(t, exportMetrics(t, opts))
+
+			reqs := append(r.RequestsTo(otlpHttp.TracesPath), r.RequestsTo(otlpHttp.MetricsPath)...)
+			require.NotEmpty(t, reqs)
+			for _, req := range reqs {
+				assert.Empty(t, req.Header.Get("api-key"), "the credentials are not sent using plain HTTP")
+			}
+		})
+	}
+}
+
+func TestFromConfig_InsecureEndpoint(t *testing.T) {
+	t.Setenv(observeCfg.LogLevelEnvVar, "debug")
+	t.Setenv(observeCfg.EnvironEnvVar, "localhost")
+	t.Setenv(observeCfg.TraceEndpointEnvVar, "http://localhost:4318")
+	t.Setenv(observeCfg.MetricsEndpointEnvVar, "http://localhost:4318")
+	t.Setenv(observeCfg.ExporterProtocolEnvVar, observeCfg.HttpJsonProtocol)
+	t.Setenv(observeCfg.ExporterHeadersEnvVar, "api-key=secret")
+	require.NoError(t, observeCfg.Initialize("unit-tests", "2023-01-01T00:00:00.000", "0.0.0", "abcd0123"))
+
+	opts, err := otlpHttp.FromConfig("http://localhost:4318")
+	require.NoError(t, err)
+	assert.Empty(t, opts.Headers, "the headers are not sent in plaintext unless it is explicit")
+	opts, err = otlpHttp.FromConfig("https://localhost:4318")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"api-key": "secret"}, opts.Headers)
+
+	t.Setenv(observeCfg.ExporterInsecureEnvVar, "true")
+	require.NoError(t, observeCfg.Initialize("unit-tests", "2023-01-01T00:00:00.000", "0.0.0", "abcd0123"))
+	opts, err = otlpHttp.FromConfig("http://localhost:4318")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"api-key": "secret"}, opts.Headers)
+}
+
+func TestExporter_URLPath(t *testing.T) {
+	r := testTools.StartHttpReceiver()
+	defer r.Close()
+
+	opts := otlpHttp.Options{Endpoint: r.URL + "/custom/traces", Protocol: observeCfg.HttpJsonProtocol}
+	assert.NoError(t, exportSpan(t, opts))
+	assert.Len(t, r.RequestsTo("/custom/traces"), 1)
+}
+
+func TestExporter_Timeout(t *testing.T) {
+	r := testTools.StartHttpReceiver()
+	defer r.Close()
+	r.SetDelay(time.Second)
+
+	opts := otlpHttp.Options{Endpoint: r.URL, Protocol: observeCfg.HttpJsonProtocol, Timeout: 50 * time.Millisecond}
+	assert.Error(t, exportSpan(t, opts))
+	assert.Error(t, exportMetrics(t, opts))
+}
+
+func TestExporter_InvalidOptions(t *testing.T) {
+	ctx := context.Background()
+	tests := []struct {
+		name string
+		opts otlpHttp.Options
+	}{
+		{"empty_endpoint", otlpHttp.Options{Protocol: observeCfg.HttpJsonProtocol}},
+		{"invalid_scheme", otlpHttp.Options{Endpoint: "ftp://localhost:4318", Protocol: observeCfg.HttpJsonProtocol}},
+		{"grpc_protocol", otlpHttp.Options{Endpoint: "localhost:4318", Protocol: observeCfg.GrpcProtocol}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := otlpHttp.NewTraceExporter(ctx, tt.opts)
+			assert.Error(t, err)
+			_, err = otlpHttp.NewMetricExporter(ctx, tt.opts)
+			assert.Error(t, err)
+		})
+	}
+}
@@ -0,0 +1,29 @@
+package otlpHttp
+
+import (
+	"context"
+
+	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
+)
+
+// traceClient is the otlptrace.Client that uploads spans encoded as JSON.
+type traceClient struct {
+	client *client
+}
+
+func (tc *traceClient) Start(context.Context) error {
+	return nil
+}
+
+func (tc *traceClient) Stop(context.Context) error {
+	tc.client.close()
+	return nil
+}
+
+func (tc *traceClient) UploadTraces(ctx context.Context, protoSpans []*tracepb.ResourceSpans) error {
+	if len(protoSpans) == 0 {
+		return nil
+	}
+	return tc.client.send(ctx, &coltracepb.ExportTraceServiceRequest{ResourceSpans: protoSpans})
+}
@@ -0,0 +1,79 @@
+package resources
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+)
+
+const (
+	cgroupPath    = "/proc/self/cgroup"
+	mountinfoPath = "/proc/self/mountinfo"
+)
+
+var (
+	// cgroup v1 paths end with the container id, optionally wrapped by the runtime, e.g.
+	// `/docker/<id>`, `/kubepods/burstable/pod<uid>/<id>` or `/system.slice/cri-containerd-<id>.scope`.
+	cgroupIDRegex = regexp.MustCompile(`(?:^|[/-])([0-9a-f]{64})(?:\.scope)?$`)
+
+	// cgroup v2 does not expose the container id in /proc/self/cgroup, however the runtimes bind mount files,
+	// such as /etc/hostname, from a directory named after the container id. containerd mounts them from the
+	// directory of the pod sandbox instead, whose id is that of the pause container, so it is not matched.
+	mountinfoIDRegex = regexp.MustCompile(`/(?:containers|overlay-containers)/([0-9a-f]{64})/`)
+)
+
+// ContainerID returns the id of the container the process is running in, or an empty string if the process is
+// not running in a container or the id could not be determined.
+func ContainerID() string {
+	if id := fromFile(cgroupPath, parseCgroup); len(id) > 0 {
+		return id
+	}
+	return fromFile(mountinfoPath, parseMountinfo)
+}
+
+func fromFile(path string, parse func(io.Reader) string) string {
+	f, err := os.Open(path)
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+	return parse(f)
+}
+
+// parseCgroup returns the container id found in the contents of /proc/<pid>/cgroup.
+func parseCgroup(r io.Reader) string {
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		// hierarchy-ID:controller-list:cgroup-path
+		parts := strings.SplitN(s.Text(), ":", 3)
+		if len(parts) != 3 {
+			continue
+		}
+		if m := cgroupIDRegex.FindStringSubmatch(strings.TrimSpace(parts[2])); m != nil {
+			return m[1]
+		}
+	}
+	return ""
+}
+
+// parseMountinfo returns the container id found in the contents of /proc/<pid>/mountinfo. The root of the cgroup
+// mount is preferred; it is the cgroup path of the container when the cgroup namespace is not private.
+func parseMountinfo(r io.Reader) string {
+	var id string
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		// mount-ID parent-ID major:minor root mount-point options ...
+		fields := strings.Fields(s.Text())
+		if len(fields) > 4 && fields[4] == "/sys/fs/cgroup" {
+			if m := cgroupIDRegex.FindStringSubmatch(fields[3]); m != nil {
+				return m[1]
+			}
+		}
+		if m := mountinfoIDRegex.FindStringSubmatch(s.Text()); m != nil && len(id) == 0 {
+			id = m[1]
+		}
+	}
+	return id
+}
@@ -0,0 +1,90 @@
+package resources_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/twistingmercury/observability/resources"
+)
+
+const (
+	containerID = "8ff2a6a1d0dbd3e1c1e6e8a2a0f4e3a1b6c5d4e3f2a1b0c9d8e7f6a5b4c3d2e1"
+	sandboxID   = "0a1b2c3d4e5f60718293a4b5c6d7e8f90a1b2c3d4e5f60718293a4b5c6d7e8f9"
+)
+
+func TestParseCgroup(t *testing.T) {
+	tests := []struct {
+		name     string
+		contents string
+		expected string
+	}{
+		{"docker", "12:memory:/docker/" + containerID + "\n", containerID},
+		{"kubepods", "11:cpu,cpuacct:/kubepods/burstable/pod0b8c1e4c-9b1a-4a4e-8f51-0e1f7c1a2b3c/" + containerID, containerID},
+		{"containerd_scope", "1:name=systemd:/system.slice/cri-containerd-" + containerID + ".scope", containerID},
+		{"crio_scope", "0::/kubepods.slice/kubepods-pod1.slice/crio-" + containerID + ".scope", containerID},
+		{"multiple_lines", "13:pids:/\n12:memory:/docker/" + containerID, containerID},
+		{"cgroup_v2", "0::/\n", ""},
+		{"not_a_container", "12:memory:/user.slice/user-1000.slice/session-2.scope", ""},
+		{"malformed", "garbage", ""},
+		{"empty", "", ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.expected, resources.ParseCgroup(strings.NewReader(test.contents)))
+		})
+	}
+}
+
+func TestParseMountinfo(t *testing.T) {
+	tests := []struct {
+		name     string
+		contents string
+		expected string
+	}{
+		{
+			"docker",
+			"1090 1080 254:1 /var/lib/docker/containers/" + containerID + "/hostname /etc/hostname rw,relatime - ext4 /dev/vda1 rw",
+			containerID,
+		},
+		{
+			"containerd_sandbox",
+			"1590 1572 0:26 /var/lib/containerd/io.containerd.grpc.v1.cri/sandboxes/" + sandboxID + "/hostname /etc/hostname rw - ext4 /dev/vda1 rw",
+			"",
+		},
+		{
+			"containerd_cgroup",
+			"1590 1572 0:26 /var/lib/containerd/io.containerd.grpc.v1.cri/sandboxes/" + sandboxID + "/hostname /etc/hostname rw - ext4 /dev/vda1 rw\n" +
+				"1601 1589 0:27 /kubepods.slice/kubepods-pod1.slice/cri-containerd-" + containerID + ".scope /sys/fs/cgroup ro - cgroup2 cgroup rw",
+			containerID,
+		},
+		{
+			"cgroup_preferred",
+			"1090 1080 254:1 /var/lib/docker/containers/" + sandboxID + "/hostname /etc/hostname rw,relatime - ext4 /dev/vda1 rw\n" +
+				"1101 1089 0:27 /docker/" + containerID + " /sys/fs/cgroup ro - cgroup2 cgroup rw",
+			containerID,
+		},
+		{
+			"private_cgroup_namespace",
+			"1101 1089 0:27 / /sys/fs/cgroup ro - cgroup2 cgroup rw",
+			"",
+		},
+		{
+			"podman",
+			"645 640 0:45 /containers/storage/overlay-containers/" + containerID + "/userdata/hostname /etc/hostname rw - tmpfs tmpfs rw",
+			containerID,
+		},
+		{
+			"not_a_container",
+			"22 1 8:1 / / rw,relatime shared:1 - ext4 /dev/sda1 rw",
+			"",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.expected, resources.ParseMountinfo(strings.NewReader(test.contents)))
+		})
+	}
+}
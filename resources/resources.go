@@ -0,0 +1,179 @@
+// Package resources detects the attributes that describe the entity producing telemetry: the service, the host,
+// the container, the Kubernetes pod, the process and the operating system. The detected attributes are shared by
+// the tracer, the metrics and the log hooks, so every signal describes the service in the same way.
+package resources
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/twistingmercury/observability/observeCfg"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/resource"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+)
+
+// The Kubernetes downward API does not define standard environment variable names, so these are the names the
+// pod spec is expected to use, e.g.:
+//
+//	env:
+//	  - name: K8S_POD_NAME
+//	    valueFrom:
+//	      fieldRef:
+//	        fieldPath: metadata.name
+const (
+	PodNameEnvVar   = "K8S_POD_NAME"
+	PodUIDEnvVar    = "K8S_POD_UID"
+	NamespaceEnvVar = "K8S_NAMESPACE_NAME"
+	NodeNameEnvVar  = "K8S_NODE_NAME"
+
+	// ResourceAttributesEnvVar holds additional resource attributes as comma separated key=value pairs. The
+	// attributes it contains override the detected attributes.
+	ResourceAttributesEnvVar = "OTEL_RESOURCE_ATTRIBUTES"
+)
+
+const (
+	BuildDateKey = attribute.Key("service.build_date")
+	CommitKey    = attribute.Key("service.commit")
+	// VCSDirtyKey is true if the service was built from a checkout with uncommitted changes.
+	VCSDirtyKey = attribute.Key("service.vcs.dirty")
+)
+
+// The keys of the service attributes before they followed the semantic conventions. They are still set, alongside
+// the semantic conventions keys, so that dashboards and alerts built on them keep working.
+//
+// Deprecated: use the semantic conventions keys, e.g. `service.name` rather than `service`.
+const (
+	LegacyServiceKey            = attribute.Key("service")
+	LegacyHostKey               = attribute.Key("host")
+	LegacyContainerIDKey        = attribute.Key("container_id")
+	LegacyEnvironmentKey        = attribute.Key("env")
+	LegacyServiceEnvironmentKey = attribute.Key("service.environment")
+	LegacyServiceVersionKey     = attribute.Key("service_version")
+	LegacyBuildDateKey          = attribute.Key("build_date")
+	LegacyCommitHashKey         = attribute.Key("commit_hash")
+)
+
+// Platform describes where the service is running. Fields that could not be detected are empty.
+type Platform struct {
+	ContainerID string
+	PodName     string
+	PodUID      string
+	Namespace   string
+	NodeName    string
+}
+
+var (
+	containerIDOnce sync.Once
+	containerID     string
+)
+
+// Detected returns the container and Kubernetes information for the running process. The container id is
+// detected once and cached.
+func Detected() Platform {
+	containerIDOnce.Do(func() {
+		containerID = ContainerID()
+	})
+	return Platform{
+		ContainerID: containerID,
+		PodName:     os.Getenv(PodNameEnvVar),
+		PodUID:      os.Getenv(PodUIDEnvVar),
+		Namespace:   os.Getenv(NamespaceEnvVar),
+		NodeName:    os.Getenv(NodeNameEnvVar),
+	}
+}
+
+// New returns the resource describing the service. It combines the build information from observeCfg with the
+// detected host, container, Kubernetes, process and OS attributes. Attributes set with `OTEL_RESOURCE_ATTRIBUTES`
+// take precedence over all others.
+func New(ctx context.Context) (*resource.Resource, error) {
+	res, err := resource.New(ctx,
+		resource.WithAttributes(serviceAttributes()...),
+		resource.WithDetectors(platformDetector{}),
+		resource.WithProcessPID(),
+		resource.WithProcessExecutableName(),
+		resource.WithProcessExecutablePath(),
+		resource.WithProcessRuntimeName(),
+		resource.WithProcessRuntimeVersion(),
+		resource.WithProcessRuntimeDescription(),
+		resource.WithOS(),
+		resource.WithFromEnv(),
+	)
+	if err != nil && !errors.Is(err, resource.ErrPartialResource) {
+		return nil, fmt.Errorf("failed to create resource: %w", err)
+	}
+	return res, nil
+}
+
+// Attributes returns the attributes of the resource returned by New.
+func Attributes(ctx context.Context) ([]attribute.KeyValue, error) {
+	res, err := New(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return res.Attributes(), nil
+}
+
+func serviceAttributes() []attribute.KeyValue {
+	return append([]attribute.KeyValue{
+		semconv.ServiceName(observeCfg.ServiceName()),
+		semconv.ServiceVersion(observeCfg.Version()),
+		semconv.DeploymentEnvironment(observeCfg.Environment()),
+		semconv.HostName(observeCfg.HostName()),
+		BuildDateKey.String(observeCfg.BuildDate()),
+		CommitKey.String(observeCfg.CommitHash()),
+		VCSDirtyKey.Bool(observeCfg.VCSDirty()),
+	}, LegacyAttributes()...)
+}
+
+// LegacyAttributes returns the service attributes under their legacy keys. The tracer and the metrics set them on
+// their resource, and the metrics set them on their instrumentation scope as well, as they did before the resources
+// package. `container_id` holds the detected container id, like `container.id`, and is omitted when the service is
+// not running in a container. It returns nil if observeCfg.ResourceLegacyAttributes is false.
+//
+// Deprecated: the legacy keys will be removed; use the semantic conventions keys.
+func LegacyAttributes() []attribute.KeyValue {
+	if !observeCfg.ResourceLegacyAttributes() {
+		return nil
+	}
+	attrs := []attribute.KeyValue{
+		LegacyServiceKey.String(observeCfg.ServiceName()),
+		LegacyHostKey.String(observeCfg.HostName()),
+		LegacyEnvironmentKey.String(observeCfg.Environment()),
+		LegacyServiceEnvironmentKey.String(observeCfg.Environment()),
+		LegacyServiceVersionKey.String(observeCfg.Version()),
+		LegacyBuildDateKey.String(observeCfg.BuildDate()),
+		LegacyCommitHashKey.String(observeCfg.CommitHash()),
+	}
+	if id := Detected().ContainerID; len(id) > 0 {
+		attrs = append(attrs, LegacyContainerIDKey.String(id))
+	}
+	return attrs
+}
+
+// platformDetector is a resource.Detector for the container and Kubernetes attributes.
+type platformDetector struct{}
+
+func (platformDetector) Detect(context.Context) (*resource.Resource, error) {
+	p := Detected()
+	attrs := make([]attribute.KeyValue, 0, 5)
+	if len(p.ContainerID) > 0 {
+		attrs = append(attrs, semconv.ContainerID(p.ContainerID))
+	}
+	if len(p.PodName) > 0 {
+		attrs = append(attrs, semconv.K8SPodName(p.PodName))
+	}
+	if len(p.PodUID) > 0 {
+		attrs = append(attrs, semconv.K8SPodUID(p.PodUID))
+	}
+	if len(p.Namespace) > 0 {
+		attrs = append(attrs, semconv.K8SNamespaceName(p.Namespace))
+	}
+	if len(p.NodeName) > 0 {
+		attrs = append(attrs, semconv.K8SNodeName(p.NodeName))
+	}
+	return resource.NewSchemaless(attrs...), nil
+}
@@ -0,0 +1,19 @@
+package resources
+
+import "sync"
+
+var (
+	ParseCgroup    = parseCgroup
+	ParseMountinfo = parseMountinfo
+)
+
+// SetContainerID sets the detected container id to id.
+func SetContainerID(id string) {
+	containerIDOnce.Do(func() {})
+	containerID = id
+}
+
+func ResetDetected() {
+	containerIDOnce = sync.Once{}
+	containerID = ""
+}
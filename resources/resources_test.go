@@ -0,0 +1,145 @@
+package resources_test
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/twistingmercury/observability/observeCfg"
+	"github.com/twistingmercury/observability/resources"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+func setup(t *testing.T) {
+	os.Setenv(observeCfg.LogLevelEnvVar, "debug")
+	os.Setenv(observeCfg.TraceEndpointEnvVar, "localhost:4317")
+	os.Setenv(observeCfg.MetricsEndpointEnvVar, "localhost:4317")
+	os.Setenv(observeCfg.EnvironEnvVar, "localhost")
+	os.Setenv(resources.PodNameEnvVar, "unit-test-pod")
+	os.Setenv(resources.NamespaceEnvVar, "unit-test-ns")
+	os.Setenv(resources.NodeNameEnvVar, "unit-test-node")
+	assert.NoError(t, observeCfg.Initialize("unit-tests", "2023-01-01T00:00:00.000", "0.0.0", "abcd0123"))
+	resources.ResetDetected()
+}
+
+func tearDown() {
+	os.Unsetenv(observeCfg.LogLevelEnvVar)
+	os.Unsetenv(observeCfg.TraceEndpointEnvVar)
+	os.Unsetenv(observeCfg.MetricsEndpointEnvVar)
+	os.Unsetenv(observeCfg.EnvironEnvVar)
+	os.Unsetenv(resources.PodNameEnvVar)
+	os.Unsetenv(resources.NamespaceEnvVar)
+	os.Unsetenv(resources.NodeNameEnvVar)
+	os.Unsetenv(resources.ResourceAttributesEnvVar)
+	os.Unsetenv(observeCfg.ResourceLegacyAttributesEnvVar)
+	resources.ResetDetected()
+}
+
+func TestNew(t *testing.T) {
+	setup(t)
+	defer tearDown()
+
+	res, err := resources.New(context.Background())
+	assert.NoError(t, err)
+
+	attrs := res.Set()
+	expected := map[attribute.Key]string{
+		"service.name":           "unit-tests",
+		"service.version":        "0.0.0",
+		"deployment.environment": "localhost",
+		"host.name":              observeCfg.HostName(),
+		"service.build_date":     "2023-01-01T00:00:00.000",
+		"service.commit":         "abcd0123",
+		"k8s.pod.name":           "unit-test-pod",
+		"k8s.namespace.name":     "unit-test-ns",
+		"k8s.node.name":          "unit-test-node",
+		"process.runtime.name":   "go",
+	}
+	for k, v := range expected {
+		actual, ok := attrs.Value(k)
+		assert.True(t, ok, "missing attribute %s", k)
+		assert.Equal(t, v, actual.AsString(), "attribute %s", k)
+	}
+
+	legacy := map[attribute.Key]string{
+		resources.LegacyServiceKey:            "unit-tests",
+		resources.LegacyHostKey:               observeCfg.HostName(),
+		resources.LegacyEnvironmentKey:        "localhost",
+		resources.LegacyServiceEnvironmentKey: "localhost",
+		resources.LegacyServiceVersionKey:     "0.0.0",
+		resources.LegacyBuildDateKey:          "2023-01-01T00:00:00.000",
+		resources.LegacyCommitHashKey:         "abcd0123",
+	}
+	for k, v := range legacy {
+		actual, ok := attrs.Value(k)
+		assert.True(t, ok, "missing legacy attribute %s", k)
+		assert.Equal(t, v, actual.AsString(), "legacy attribute %s", k)
+	}
+
+	_, ok := attrs.Value(resources.LegacyContainerIDKey)
+	assert.Equal(t, len(resources.Detected().ContainerID) > 0, ok, "container_id is only set in a container")
+
+	dirty, ok := attrs.Value(resources.VCSDirtyKey)
+	assert.True(t, ok)
+	assert.False(t, dirty.AsBool())
+
+	pid, ok := attrs.Value("process.pid")
+	assert.True(t, ok)
+	assert.Equal(t, int64(os.Getpid()), pid.AsInt64())
+
+	_, ok = attrs.Value("os.type")
+	assert.True(t, ok)
+}
+
+func TestLegacyAttributes_ContainerID(t *testing.T) {
+	setup(t)
+	defer tearDown()
+
+	resources.SetContainerID("8ff2a6a1d0dbd3e1")
+	assert.Contains(t, resources.LegacyAttributes(), resources.LegacyContainerIDKey.String("8ff2a6a1d0dbd3e1"))
+
+	resources.SetContainerID("")
+	for _, kv := range resources.LegacyAttributes() {
+		assert.NotEqual(t, resources.LegacyContainerIDKey, kv.Key, "container_id is omitted outside a container")
+	}
+}
+
+func TestNew_WithoutLegacyAttributes(t *testing.T) {
+	setup(t)
+	defer tearDown()
+	os.Setenv(observeCfg.ResourceLegacyAttributesEnvVar, "false")
+	assert.NoError(t, observeCfg.Initialize("unit-tests", "2023-01-01T00:00:00.000", "0.0.0", "abcd0123"))
+
+	res, err := resources.New(context.Background())
+	assert.NoError(t, err)
+	_, ok := res.Set().Value(resources.LegacyServiceKey)
+	assert.False(t, ok)
+	_, ok = res.Set().Value("service.name")
+	assert.True(t, ok)
+	assert.Nil(t, resources.LegacyAttributes())
+}
+
+func TestNew_ResourceAttributesEnvVar(t *testing.T) {
+	setup(t)
+	defer tearDown()
+	os.Setenv(resources.ResourceAttributesEnvVar, "team=observability,k8s.pod.name=overridden")
+
+	attrs, err := resources.Attributes(context.Background())
+	assert.NoError(t, err)
+	assert.Contains(t, attrs, attribute.String("team", "observability"))
+	assert.Contains(t, attrs, attribute.String("k8s.pod.name", "overridden"))
+	assert.Contains(t, attrs, attribute.String("service.name", "unit-tests"))
+}
+
+func TestDetected(t *testing.T) {
+	setup(t)
+	defer tearDown()
+
+	p := resources.Detected()
+	assert.Equal(t, "unit-test-pod", p.PodName)
+	assert.Equal(t, "unit-test-ns", p.Namespace)
+	assert.Equal(t, "unit-test-node", p.NodeName)
+	assert.Empty(t, p.PodUID)
+	assert.Equal(t, resources.ContainerID(), p.ContainerID)
+}
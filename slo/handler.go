@@ -0,0 +1,81 @@
+package slo
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/twistingmercury/observability/observeCfg"
+)
+
+// ObjectiveStatus is the status of an objective over its rolling window.
+type ObjectiveStatus struct {
+	Name      string  `json:"name"`
+	Route     string  `json:"route,omitempty"`
+	Method    string  `json:"method,omitempty"`
+	Operation string  `json:"operation,omitempty"`
+	Target    float64 `json:"target"`
+	// LatencyMs is the latency threshold of the objective in milliseconds, or zero if it has none.
+	LatencyMs float64 `json:"latency_ms,omitempty"`
+	Window    string  `json:"window"`
+
+	Good  int64 `json:"good"`
+	Total int64 `json:"total"`
+	// SLI is the ratio of good events, or 1 if there were no events.
+	SLI float64 `json:"sli"`
+	// BurnRate is the rate the error budget is consumed at: 1 consumes exactly the budget over the window.
+	BurnRate float64 `json:"burn_rate"`
+	// ErrorBudgetRemaining is the ratio of the error budget that remains; it is negative once the budget is exceeded.
+	ErrorBudgetRemaining float64 `json:"error_budget_remaining"`
+	// Met is true if the SLI meets the target.
+	Met bool `json:"met"`
+}
+
+// ServiceStatus is the status of the objectives of the service.
+type ServiceStatus struct {
+	Service    string            `json:"service"`
+	Met        bool              `json:"met"`
+	Objectives []ObjectiveStatus `json:"objectives"`
+}
+
+// Status evaluates the registered objectives over their rolling window.
+func Status() ServiceStatus {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	t := now()
+	s := ServiceStatus{Service: observeCfg.ServiceName(), Met: true, Objectives: make([]ObjectiveStatus, 0, len(objectives))}
+	for _, o := range objectives {
+		good, total := o.window.counts(t)
+		st := ObjectiveStatus{
+			Name:                 o.Name,
+			Route:                o.Route,
+			Method:               o.Method,
+			Operation:            o.Operation,
+			Target:               o.Target,
+			LatencyMs:            float64(o.Latency.Microseconds()) / 1000,
+			Window:               o.Window.String(),
+			Good:                 good,
+			Total:                total,
+			SLI:                  1,
+			ErrorBudgetRemaining: 1,
+		}
+		if total > 0 {
+			errorRate := float64(total-good) / float64(total)
+			st.SLI = 1 - errorRate
+			st.BurnRate = errorRate / (1 - o.Target)
+			st.ErrorBudgetRemaining = 1 - st.BurnRate
+		}
+		st.Met = st.SLI >= o.Target
+		s.Met = s.Met && st.Met
+		s.Objectives = append(s.Objectives, st)
+	}
+	return s
+}
+
+// Handler returns a gin handler that serves the Status of the objectives as JSON, typically registered as
+// `router.GET("/slo", slo.Handler())`.
+func Handler() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		ctx.JSON(http.StatusOK, Status())
+	}
+}
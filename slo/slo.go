@@ -0,0 +1,198 @@
+// Package slo provides service level objectives on top of the metrics package. Objectives are declared per route or
+// per operation, e.g. "99.9% of GET /orders are served in less than 300ms". Each request or operation is an event,
+// which is good if it succeeded within the latency threshold of the objective. The good and total events are counted
+// by the `slo.events.good` and `slo.events.total` counters, from which the collector rules compute the error budget
+// burn, and are evaluated in process over a rolling window, served by Handler.
+package slo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/twistingmercury/observability/metrics"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/metric/noop"
+)
+
+const (
+	// NameKey is the attribute key of the name of the objective on the event counters.
+	NameKey = "slo.name"
+	// TargetKey is the attribute key of the target of the objective on the event counters.
+	TargetKey = "slo.target"
+
+	// DefaultWindow is the rolling window objectives are evaluated over, unless they set their own.
+	DefaultWindow = time.Hour
+)
+
+// Objective is a service level objective.
+type Objective struct {
+	// Name identifies the objective, e.g. `orders-latency`. It must be unique.
+	Name string
+	// Route is the route template of the requests the objective applies to, e.g. `/orders/:id`. Either Route or
+	// Operation must be set.
+	Route string
+	// Method is the HTTP method of the requests the objective applies to. If empty, it applies to every method.
+	Method string
+	// Operation is the name of the operation the objective applies to, as passed to Record.
+	Operation string
+	// Target is the ratio of good events to meet, e.g. 0.999.
+	Target float64
+	// Latency is the duration a good event must complete within. If zero, only the errors are bad events.
+	Latency time.Duration
+	// Window is the rolling window the objective is evaluated over. Defaults to DefaultWindow.
+	Window time.Duration
+}
+
+// matches returns true if the event of a route or an operation applies to o.
+func (o Objective) matches(route, method, operation string) bool {
+	if len(o.Operation) > 0 {
+		return o.Operation == operation
+	}
+	return len(route) > 0 && o.Route == route && (len(o.Method) == 0 || o.Method == method)
+}
+
+func (o Objective) good(d time.Duration, failed bool) bool {
+	return !failed && (o.Latency == 0 || d <= o.Latency)
+}
+
+func (o Objective) validate() error {
+	switch {
+	case len(o.Name) == 0:
+		return errors.New("the objective name is empty")
+	case len(o.Route) == 0 && len(o.Operation) == 0:
+		return fmt.Errorf("objective %s: either the route or the operation must be set", o.Name)
+	case len(o.Route) > 0 && len(o.Operation) > 0:
+		return fmt.Errorf("objective %s: only one of the route and the operation can be set", o.Name)
+	case o.Target <= 0 || o.Target >= 1:
+		return fmt.Errorf("objective %s: the target must be between 0 and 1, exclusive: %v", o.Name, o.Target)
+	case o.Latency < 0:
+		return fmt.Errorf("objective %s: the latency cannot be negative: %v", o.Name, o.Latency)
+	case o.Window < 0:
+		return fmt.Errorf("objective %s: the window cannot be negative: %v", o.Name, o.Window)
+	}
+	return nil
+}
+
+// objective is a registered Objective, with its rolling window and the attributes of its events.
+type objective struct {
+	Objective
+	window *window
+	attrs  metric.MeasurementOption
+}
+
+var (
+	mu         sync.RWMutex
+	objectives []*objective
+
+	goodEvents  metric.Int64Counter = noop.Int64Counter{}
+	totalEvents metric.Int64Counter = noop.Int64Counter{}
+
+	// now returns the current time; it is replaced by tests.
+	now = time.Now
+)
+
+func reset() {
+	mu.Lock()
+	defer mu.Unlock()
+	objectives = nil
+	goodEvents = noop.Int64Counter{}
+	totalEvents = noop.Int64Counter{}
+	now = time.Now
+}
+
+// Register adds the objectives, and creates the event counters. metrics.Initialize() must be invoked first,
+// otherwise the events are only evaluated in process. If any objective is invalid, none is added.
+func Register(objs ...Objective) error {
+	mu.Lock()
+	defer mu.Unlock()
+
+	names := make(map[string]bool, len(objectives)+len(objs))
+	for _, o := range objectives {
+		names[o.Name] = true
+	}
+	var errs []error
+	for _, o := range objs {
+		if err := o.validate(); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if names[o.Name] {
+			errs = append(errs, fmt.Errorf("objective %s is already registered", o.Name))
+		}
+		names[o.Name] = true
+	}
+	if err := errors.Join(errs...); err != nil {
+		return fmt.Errorf("failed to register the objectives: %w", err)
+	}
+
+	if len(objectives) == 0 {
+		ge, err := metrics.NewInt64Counter("slo.events.good", metrics.InstrumentOptions{
+			Unit: "{event}", Description: "The number of events that met their service level objective.",
+		})
+		if err != nil {
+			return fmt.Errorf("failed to create events.good counter: %w", err)
+		}
+		te, err := metrics.NewInt64Counter("slo.events.total", metrics.InstrumentOptions{
+			Unit: "{event}", Description: "The number of events evaluated against a service level objective.",
+		})
+		if err != nil {
+			return fmt.Errorf("failed to create events.total counter: %w", err)
+		}
+		goodEvents = ge
+		totalEvents = te
+	}
+
+	for _, o := range objs {
+		if o.Window == 0 {
+			o.Window = DefaultWindow
+		}
+		objectives = append(objectives, &objective{
+			Objective: o,
+			window:    newWindow(o.Window),
+			attrs: metric.WithAttributes(
+				attribute.String(NameKey, o.Name),
+				attribute.String(TargetKey, strconv.FormatFloat(o.Target, 'f', -1, 64)),
+			),
+		})
+	}
+	return nil
+}
+
+// Record evaluates an operation against the objectives registered for it. The operation failed if err is not nil.
+func Record(ctx context.Context, operation string, d time.Duration, err error) {
+	record(ctx, "", "", operation, d, err != nil)
+}
+
+// Middleware evaluates each request against the objectives registered for its route and method. A request failed if
+// its status code is 500 or more.
+func Middleware() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		start := now()
+		ctx.Next()
+		record(ctx.Request.Context(), ctx.FullPath(), ctx.Request.Method, "", now().Sub(start), ctx.Writer.Status() >= 500)
+	}
+}
+
+func record(ctx context.Context, route, method, operation string, d time.Duration, failed bool) {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	t := now()
+	for _, o := range objectives {
+		if !o.matches(route, method, operation) {
+			continue
+		}
+		good := o.good(d, failed)
+		o.window.add(t, good)
+		totalEvents.Add(ctx, 1, o.attrs)
+		if good {
+			goodEvents.Add(ctx, 1, o.attrs)
+		}
+	}
+}
@@ -0,0 +1,12 @@
+package slo
+
+import "time"
+
+var Reset = reset
+
+// SetNow replaces the clock of the package.
+func SetNow(f func() time.Time) {
+	mu.Lock()
+	defer mu.Unlock()
+	now = f
+}
@@ -0,0 +1,190 @@
+package slo_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/twistingmercury/observability/logger"
+	"github.com/twistingmercury/observability/metrics"
+	"github.com/twistingmercury/observability/observeCfg"
+	"github.com/twistingmercury/observability/slo"
+	"go.opentelemetry.io/otel/attribute"
+	sdkMetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+// clock is a fake clock advanced by the tests.
+type clock struct{ t time.Time }
+
+func (c *clock) now() time.Time { return c.t }
+
+func setup(t *testing.T) (*clock, *sdkMetric.ManualReader) {
+	logger.Initialize(&bytes.Buffer{}, logrus.DebugLevel)
+	os.Setenv(observeCfg.LogLevelEnvVar, "debug")
+	os.Setenv(observeCfg.TraceEndpointEnvVar, "localhost:4317")
+	os.Setenv(observeCfg.MetricsEndpointEnvVar, "localhost:4317")
+	os.Setenv(observeCfg.EnvironEnvVar, "localhost")
+	require.NoError(t, observeCfg.Initialize("slo-tests", "2023-01-01T00:00:00.000", "0.0.0", "abcd0123"))
+
+	reader := sdkMetric.NewManualReader()
+	shutdown, err := metrics.InitializeConsole("unit.test", io.Discard, metrics.WithReader(reader))
+	require.NoError(t, err)
+
+	c := &clock{t: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	slo.SetNow(c.now)
+	t.Cleanup(func() {
+		_ = shutdown(context.Background())
+		slo.Reset()
+		os.Unsetenv(observeCfg.LogLevelEnvVar)
+		os.Unsetenv(observeCfg.TraceEndpointEnvVar)
+		os.Unsetenv(observeCfg.MetricsEndpointEnvVar)
+		os.Unsetenv(observeCfg.EnvironEnvVar)
+	})
+	return c, reader
+}
+
+func TestRegister_Invalid(t *testing.T) {
+	setup(t)
+	for name, o := range map[string]slo.Objective{
+		"no name":      {Route: "/orders", Target: 0.99},
+		"no target":    {Name: "a", Route: "/orders"},
+		"target of 1":  {Name: "a", Route: "/orders", Target: 1},
+		"no route":     {Name: "a", Target: 0.99},
+		"route and op": {Name: "a", Route: "/orders", Operation: "sync", Target: 0.99},
+		"negative":     {Name: "a", Route: "/orders", Target: 0.99, Latency: -time.Second},
+	} {
+		assert.Error(t, slo.Register(o), name)
+	}
+
+	o := slo.Objective{Name: "a", Route: "/orders", Target: 0.99}
+	require.NoError(t, slo.Register(o))
+	assert.Error(t, slo.Register(o), "the names are unique")
+	assert.Len(t, slo.Status().Objectives, 1)
+}
+
+func TestMiddleware(t *testing.T) {
+	c, reader := setup(t)
+	require.NoError(t, slo.Register(
+		slo.Objective{Name: "orders-latency", Method: http.MethodGet, Route: "/orders/:id", Target: 0.9, Latency: 300 * time.Millisecond},
+		slo.Objective{Name: "orders-availability", Route: "/orders/:id", Target: 0.5},
+	))
+
+	_, e := gin.CreateTestContext(httptest.NewRecorder())
+	e.Use(slo.Middleware())
+	e.GET("/orders/:id", func(ctx *gin.Context) {
+		switch ctx.Param("id") {
+		case "slow":
+			c.t = c.t.Add(time.Second)
+		case "fail":
+			ctx.Status(http.StatusInternalServerError)
+			return
+		}
+		ctx.Status(http.StatusOK)
+	})
+	e.POST("/orders/:id", func(ctx *gin.Context) { ctx.Status(http.StatusCreated) })
+	for _, r := range []struct{ method, path string }{
+		{http.MethodGet, "/orders/1"}, {http.MethodGet, "/orders/2"}, {http.MethodGet, "/orders/slow"},
+		{http.MethodGet, "/orders/fail"}, {http.MethodPost, "/orders/3"}, {http.MethodGet, "/health"},
+	} {
+		e.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(r.method, r.path, nil))
+	}
+
+	s := slo.Status()
+	assert.Equal(t, "slo-tests", s.Service)
+	assert.False(t, s.Met)
+	latency, availability := s.Objectives[0], s.Objectives[1]
+
+	assert.Equal(t, int64(2), latency.Good, "slow and failed requests are bad events")
+	assert.Equal(t, int64(4), latency.Total, "only GET requests apply")
+	assert.Equal(t, 0.5, latency.SLI)
+	assert.InDelta(t, 5, latency.BurnRate, 1e-9)
+	assert.InDelta(t, -4, latency.ErrorBudgetRemaining, 1e-9)
+	assert.False(t, latency.Met)
+	assert.Equal(t, 300.0, latency.LatencyMs)
+
+	assert.Equal(t, int64(4), availability.Good, "only failed requests are bad events")
+	assert.Equal(t, int64(5), availability.Total)
+	assert.True(t, availability.Met)
+
+	rm := metricdata.ResourceMetrics{}
+	require.NoError(t, reader.Collect(context.Background(), &rm))
+	counts := map[string]int64{}
+	for _, m := range rm.ScopeMetrics[0].Metrics {
+		sum, ok := m.Data.(metricdata.Sum[int64])
+		if !ok {
+			continue
+		}
+		for _, dp := range sum.DataPoints {
+			name, _ := dp.Attributes.Value(attribute.Key(slo.NameKey))
+			target, _ := dp.Attributes.Value(attribute.Key(slo.TargetKey))
+			counts[m.Name+"/"+name.AsString()+"/"+target.AsString()] = dp.Value
+		}
+	}
+	assert.Equal(t, map[string]int64{
+		"unit.test.slo-tests.slo.events.good/orders-latency/0.9":       2,
+		"unit.test.slo-tests.slo.events.total/orders-latency/0.9":      4,
+		"unit.test.slo-tests.slo.events.good/orders-availability/0.5":  4,
+		"unit.test.slo-tests.slo.events.total/orders-availability/0.5": 5,
+	}, counts)
+}
+
+func TestRecord_RollingWindow(t *testing.T) {
+	c, _ := setup(t)
+	require.NoError(t, slo.Register(slo.Objective{Name: "sync", Operation: "sync", Target: 0.99, Window: time.Hour}))
+
+	ctx := context.Background()
+	slo.Record(ctx, "sync", time.Second, errors.New("failed"))
+	c.t = c.t.Add(30 * time.Minute)
+	slo.Record(ctx, "sync", time.Second, nil)
+	slo.Record(ctx, "other", time.Second, errors.New("failed"))
+
+	st := slo.Status().Objectives[0]
+	assert.Equal(t, int64(1), st.Good)
+	assert.Equal(t, int64(2), st.Total)
+
+	c.t = c.t.Add(31 * time.Minute)
+	st = slo.Status().Objectives[0]
+	assert.Equal(t, int64(1), st.Total, "the events older than the window expire")
+	assert.True(t, st.Met)
+
+	c.t = c.t.Add(time.Hour)
+	st = slo.Status().Objectives[0]
+	assert.Equal(t, int64(0), st.Total)
+	assert.Equal(t, 1.0, st.SLI, "an objective without events is met")
+	assert.True(t, st.Met)
+}
+
+func TestHandler(t *testing.T) {
+	setup(t)
+	require.NoError(t, slo.Register(slo.Objective{Name: "sync", Operation: "sync", Target: 0.99}))
+	slo.Record(context.Background(), "sync", time.Millisecond, nil)
+
+	w := httptest.NewRecorder()
+	_, e := gin.CreateTestContext(w)
+	e.GET("/slo", slo.Handler())
+	e.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/slo", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var s slo.ServiceStatus
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &s))
+	assert.True(t, s.Met)
+	require.Len(t, s.Objectives, 1)
+	assert.Equal(t, "sync", s.Objectives[0].Name)
+	assert.Equal(t, "1h0m0s", s.Objectives[0].Window)
+	assert.Equal(t, int64(1), s.Objectives[0].Total)
+	// the keys are snake case, like those of observability.Status.
+	assert.Contains(t, w.Body.String(), `"burn_rate":0`)
+	assert.Contains(t, w.Body.String(), `"error_budget_remaining":1`)
+}
@@ -0,0 +1,63 @@
+package slo
+
+import (
+	"sync"
+	"time"
+)
+
+// windowBuckets is the number of buckets of a rolling window: the events expire one bucket, 1/60 of the window, at a
+// time.
+const windowBuckets = 60
+
+// window counts the good and total events of the last duration.
+type window struct {
+	mu       sync.Mutex
+	duration time.Duration
+	width    time.Duration
+	buckets  [windowBuckets]bucket
+}
+
+type bucket struct {
+	// slot is the index of the bucket since the epoch, used to detect the buckets that expired.
+	slot  int64
+	good  int64
+	total int64
+}
+
+func newWindow(d time.Duration) *window {
+	width := d / windowBuckets
+	if width <= 0 {
+		width = 1
+	}
+	return &window{duration: d, width: width}
+}
+
+func (w *window) add(t time.Time, good bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	slot := t.UnixNano() / int64(w.width)
+	b := &w.buckets[slot%windowBuckets]
+	if b.slot != slot {
+		*b = bucket{slot: slot}
+	}
+	b.total++
+	if good {
+		b.good++
+	}
+}
+
+// counts returns the good and total events of the window ending at t.
+func (w *window) counts(t time.Time) (good, total int64) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	slot := t.UnixNano() / int64(w.width)
+	for _, b := range w.buckets {
+		if b.slot > slot-windowBuckets && b.slot <= slot {
+			good += b.good
+			total += b.total
+		}
+	}
+	return good, total
+}
@@ -0,0 +1,335 @@
+package observability
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/twistingmercury/observability/localExport"
+	"github.com/twistingmercury/observability/logger"
+	"github.com/twistingmercury/observability/logger/hooks"
+	"github.com/twistingmercury/observability/metrics"
+	"github.com/twistingmercury/observability/observeCfg"
+	"github.com/twistingmercury/observability/otlpHttp"
+	"github.com/twistingmercury/observability/tracer"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+)
+
+// StartOptions are the options used by Start to initialize all the observability packages.
+type StartOptions struct {
+	// ServiceName, BuildDate, Version and Commit are passed to observeCfg.Initialize.
+	ServiceName string
+	BuildDate   string
+	Version     string
+	Commit      string
+
+	// MetricsNamespace is the namespace passed to metrics.Initialize.
+	MetricsNamespace string
+
+	// LogWriter is where the logs are written. If nil, os.Stdout is used.
+	LogWriter io.Writer
+	// LogHooks are added to the logger after the standard fields and trace hooks.
+	LogHooks []logrus.Hook
+	// ConsoleWriter is where the `console` exporters print spans and metrics. If nil, os.Stdout is used.
+	ConsoleWriter io.Writer
+	// RuntimeMetrics publishes the Go runtime and process metrics, see metrics.StartRuntimeMetrics.
+	RuntimeMetrics bool
+
+	// TransportCreds are used to connect to the trace and metrics endpoints when the exporter protocol is `grpc`.
+	// If nil, the credentials are those of GrpcConnectionOptionsFromConfig: TLS if a certificate is set or the
+	// endpoint is an `https://` URL, insecure otherwise.
+	TransportCreds credentials.TransportCredentials
+	// WaitForConnect blocks Start until the connections are established, or WaitTimeout elapses.
+	WaitForConnect bool
+	// WaitTimeout is the maximum time a connection is waited for. It defaults to WaitTimeSeconds, or else 10
+	// seconds.
+	WaitTimeout time.Duration
+	// WaitTimeSeconds is the maximum time a connection is waited for, in seconds, e.g. 10 rather than
+	// 10 * time.Second.
+	//
+	// Deprecated: use WaitTimeout, a time.Duration that is not multiplied by a second.
+	WaitTimeSeconds time.Duration
+}
+
+// Start initializes observeCfg, the logger, the tracer and the metrics, including the metrics middleware, and
+// connects to the trace and metrics endpoints using the exporter protocol set by observeCfg. The returned func
+// flushes and shuts down the metrics and the tracer and then closes the connections; the errors encountered along
+// the way are joined.
+//
+// If settings are read from a config file, the file is watched and the log level, the traces sampler, the redacted
+// log keys and the excluded HTTP routes follow its changes, see observeCfg.WatchConfigFile.
+//
+// If `OTEL_SDK_DISABLED` is true, only the logger is initialized: the tracer and the metrics remain no-ops and are
+// reported as disabled by Status.
+//
+// Start only returns an error if the configuration is invalid. If a collector cannot be reached, the connection
+// is retried in the background, and if a signal cannot be started at all its providers remain no-ops. Use
+// Status to find out whether telemetry is being delivered.
+func Start(ctx context.Context, opts StartOptions) (shutdown func(context.Context) error, err error) {
+	if err = observeCfg.Initialize(opts.ServiceName, opts.BuildDate, opts.Version, opts.Commit); err != nil {
+		return nil, fmt.Errorf("failed to initialize the configuration: %w", err)
+	}
+
+	out := opts.LogWriter
+	if out == nil {
+		out = os.Stdout
+	}
+	logHooks := append([]logrus.Hook{hooks.NewStdFieldsHook(), hooks.NewTraceHook()}, opts.LogHooks...)
+	// the fields added by the other hooks are redacted too.
+	logHooks = append(logHooks, hooks.NewRedactionHook())
+	logger.Initialize(out, observeCfg.LogLevel(), logHooks...)
+	logger.SetExcludedRoutes(observeCfg.HttpExcludedRoutes()...)
+	if len(observeCfg.ConfigFile()) > 0 {
+		if wErr := observeCfg.WatchConfigFile(); wErr != nil {
+			logger.Error(wErr, "the config file will not be reloaded")
+		}
+	}
+	unsubscribe := observeCfg.Subscribe(func(c observeCfg.Change) {
+		if c.Has(observeCfg.LogLevelEnvVar) {
+			logger.SetLevel(observeCfg.LogLevel())
+		}
+		if c.Has(observeCfg.HttpExcludedRoutesEnvVar) {
+			logger.SetExcludedRoutes(observeCfg.HttpExcludedRoutes()...)
+		}
+		if c.Has(observeCfg.TracesSamplerEnvVar) || c.Has(observeCfg.TracesSamplerArgEnvVar) {
+			sampler, sErr := tracer.NewSampler(observeCfg.TracesSampler(), observeCfg.TracesSamplerArg())
+			if sErr != nil {
+				logger.Error(sErr, "the traces sampler was not changed")
+				return
+			}
+			tracer.SetSampler(sampler)
+		}
+	})
+
+	creds := opts.TransportCreds
+
+	registerErrorHandler()
+
+	// the providers are flushed and shut down, metrics first, before their connections are closed.
+	var providers, conns []func(context.Context) error
+	shutdown = func(ctx context.Context) error {
+		unsubscribe()
+		var errs []error
+		for _, s := range append(providers, conns...) {
+			errs = append(errs, s(ctx))
+		}
+		return errors.Join(errs...)
+	}
+
+	if observeCfg.SDKDisabled() {
+		disableSignal(&traceSignal)
+		disableSignal(&metricsSignal)
+		logger.Info("observability started; the OpenTelemetry SDK is disabled",
+			logger.Attribute{Key: "health", Value: string(Status().Health)})
+		return shutdown, nil
+	}
+
+	shutdownTracer, closeTraces := startTracer(ctx, creds, opts)
+	shutdownMetrics, closeMetrics := startMetrics(ctx, creds, opts)
+	for _, f := range []func(context.Context) error{shutdownMetrics, shutdownTracer} {
+		if f != nil {
+			providers = append(providers, f)
+		}
+	}
+	for _, f := range []func(context.Context) error{closeTraces, closeMetrics} {
+		if f != nil {
+			conns = append(conns, f)
+		}
+	}
+
+	if mErr := metrics.InitializeMetrics(); mErr != nil {
+		logger.Error(mErr, "failed to initialize the metrics middleware; request metrics will not be recorded")
+	}
+	if opts.RuntimeMetrics && metrics.IsInitialized() {
+		stop, rErr := metrics.StartRuntimeMetrics()
+		if rErr != nil {
+			logger.Error(rErr, "runtime metrics will not be recorded")
+		} else {
+			providers = append([]func(context.Context) error{stop}, providers...)
+		}
+	}
+
+	logger.Info("observability started", logger.Attribute{Key: "health", Value: string(Status().Health)})
+	return shutdown, nil
+}
+
+// connect dials the endpoint of a signal, using the options set by observeCfg, and records the outcome. If a
+// blocking dial fails, the connection is dialed again without blocking so that it is retried in the background. If
+// no connection can be created, nil is returned and the signal is disabled.
+func connect(ctx context.Context, s *signal, name, endpoint string, creds credentials.TransportCredentials, opts StartOptions) *grpc.ClientConn {
+	gOpts := grpcConnectionOptionsFromConfig(endpoint, creds)
+	gOpts.WaitForConnect = opts.WaitForConnect
+	gOpts.WaitTimeout = opts.WaitTimeout
+	gOpts.WaitTimeSeconds = opts.WaitTimeSeconds
+	exported := &exports{}
+	gOpts.interceptors = append(gOpts.interceptors, exportedInterceptor(exported))
+
+	conn, err := newGrpcConnection(ctx, gOpts)
+	if err != nil && gOpts.WaitForConnect {
+		logger.Warn(fmt.Sprintf("failed to connect to the %s endpoint; retrying in the background", name),
+			logger.Attribute{Key: "endpoint", Value: endpoint},
+			logger.Attribute{Key: "error", Value: err.Error()})
+		gOpts.WaitForConnect = false
+		var rErr error
+		if conn, rErr = newGrpcConnection(ctx, gOpts); rErr != nil {
+			err = errors.Join(err, rErr)
+		}
+	}
+	if conn == nil {
+		logger.Error(err, fmt.Sprintf("failed to create grpc connection for %s; %s will not be exported", name, name),
+			logger.Attribute{Key: "endpoint", Value: endpoint})
+	}
+	setSignal(s, endpoint, conn, exported, err)
+	return conn
+}
+
+// grpcTarget returns the gRPC target of an endpoint. The OpenTelemetry endpoints are URLs, e.g.
+// `http://collector:4317`, whereas gRPC dials `collector:4317`.
+func grpcTarget(endpoint string) string {
+	for _, scheme := range []string{"http://", "https://"} {
+		if t, ok := strings.CutPrefix(endpoint, scheme); ok {
+			return strings.TrimSuffix(t, "/")
+		}
+	}
+	return endpoint
+}
+
+// startTracer initializes the tracer using the exporter protocol set by observeCfg. It returns the shutdown func
+// of the tracer provider, nil if the tracer could not be initialized, and the func that closes its connection, if
+// any.
+func startTracer(ctx context.Context, creds credentials.TransportCredentials, opts StartOptions) (shutdown, closeConn func(context.Context) error) {
+	ep := observeCfg.TraceEndpoint()
+	switch {
+	case observeCfg.TracesExporter() == observeCfg.ConsoleExporter:
+		shutdown, err := tracer.InitializeConsole(opts.ConsoleWriter)
+		return startedLocally(&traceSignal, observeCfg.ConsoleExporter, "", shutdown, err)
+	case observeCfg.TracesExporter() == observeCfg.FileExporter:
+		fOpts := fileOptions("traces.jsonl")
+		shutdown, err := tracer.InitializeFile(fOpts)
+		return startedLocally(&traceSignal, observeCfg.FileExporter, fOpts.Path, shutdown, err)
+	case observeCfg.ExporterProtocol() == observeCfg.GrpcProtocol:
+		conn := connect(ctx, &traceSignal, "traces", ep, creds, opts)
+		if conn == nil {
+			return nil, nil
+		}
+		// the headers are sent by the per-RPC credentials of the connection, resolved, rather than by the exporter.
+		shutdown, err := tracer.Initialize(conn, tracer.WithExporterHeaders(map[string]string{}))
+		if err != nil {
+			setSignal(&traceSignal, ep, nil, nil, err)
+			logger.Error(err, "failed to initialize the tracer; spans will not be exported")
+		}
+		return shutdown, closeFunc(conn)
+	}
+
+	exported := &exports{}
+	hOpts, err := otlpHttp.FromConfig(ep)
+	if err == nil {
+		hOpts.ExportResult = exported.record
+		shutdown, err = tracer.InitializeHttp(hOpts)
+	}
+	if err != nil {
+		logger.Error(err, "failed to initialize the tracer; spans will not be exported")
+	}
+	setStartedSignal(&traceSignal, observeCfg.ExporterProtocol(), ep, exported, err)
+	return shutdown, nil
+}
+
+// startMetrics initializes the metrics using the exporters and the exporter protocol set by observeCfg. It returns
+// the shutdown func of the meter provider, nil if the metrics could not be initialized, and the func that closes
+// its connection, if any. There is no connection when the metrics are sent using OTLP over HTTP or only pulled by
+// Prometheus; a collector that cannot be reached is then reported by the export errors.
+func startMetrics(ctx context.Context, creds credentials.TransportCredentials, opts StartOptions) (shutdown, closeConn func(context.Context) error) {
+	var mOpts []metrics.Option
+	if observeCfg.MetricsExporterEnabled(observeCfg.PrometheusExporter) {
+		mOpts = append(mOpts, metrics.WithPrometheus())
+	}
+	if b := observeCfg.MetricsLatencyBuckets(); len(b) > 0 {
+		mOpts = append(mOpts, metrics.WithLatencyBuckets(b...))
+	}
+	if observeCfg.MetricsHistogramAggregation() == observeCfg.ExponentialBucketHistogram {
+		mOpts = append(mOpts, metrics.WithExponentialHistograms())
+	}
+	mOpts = append(mOpts, metrics.WithCardinalityLimit(observeCfg.MetricsCardinalityLimit()))
+	if filter, err := metrics.ExemplarFilter(observeCfg.MetricsExemplarFilter()); err == nil {
+		mOpts = append(mOpts, metrics.WithExemplarFilter(filter))
+	}
+	mOpts = append(mOpts,
+		metrics.WithExportInterval(observeCfg.MetricsExportInterval()),
+		metrics.WithExportTimeout(observeCfg.MetricsExportTimeout()),
+		metrics.WithViews(observeCfg.MetricsViews()...),
+	)
+	if selector, err := metrics.TemporalitySelector(observeCfg.MetricsTemporality(), observeCfg.MetricsTemporalityOverrides()); err == nil {
+		mOpts = append(mOpts, metrics.WithTemporality(selector))
+	}
+
+	ep := observeCfg.MetricsEndpoint()
+	var err error
+	switch {
+	case observeCfg.MetricsExporterEnabled(observeCfg.ConsoleExporter):
+		shutdown, err = metrics.InitializeConsole(opts.MetricsNamespace, opts.ConsoleWriter, mOpts...)
+		return startedLocally(&metricsSignal, observeCfg.ConsoleExporter, "", shutdown, err)
+	case observeCfg.MetricsExporterEnabled(observeCfg.FileExporter):
+		fOpts := fileOptions("metrics.jsonl")
+		shutdown, err = metrics.InitializeFile(opts.MetricsNamespace, fOpts, mOpts...)
+		return startedLocally(&metricsSignal, observeCfg.FileExporter, fOpts.Path, shutdown, err)
+	case !observeCfg.MetricsExporterEnabled(observeCfg.OtlpExporter):
+		shutdown, err = metrics.InitializePrometheus(opts.MetricsNamespace, mOpts...)
+		return startedLocally(&metricsSignal, observeCfg.PrometheusExporter, "", shutdown, err)
+	case observeCfg.ExporterProtocol() == observeCfg.GrpcProtocol:
+		conn := connect(ctx, &metricsSignal, "metrics", ep, creds, opts)
+		if conn == nil {
+			return nil, nil
+		}
+		closeConn = closeFunc(conn)
+		// the headers are sent by the per-RPC credentials of the connection, resolved, rather than by the exporter.
+		mOpts = append(mOpts, metrics.WithExporterHeaders(map[string]string{}))
+		if shutdown, err = metrics.Initialize(opts.MetricsNamespace, conn, mOpts...); err != nil {
+			setSignal(&metricsSignal, ep, nil, nil, err)
+			logger.Error(err, "failed to initialize the metrics; metrics will not be exported")
+		}
+	default:
+		exported := &exports{}
+		hOpts, hErr := otlpHttp.FromConfig(ep)
+		if err = hErr; err == nil {
+			hOpts.ExportResult = exported.record
+			shutdown, err = metrics.InitializeHttp(opts.MetricsNamespace, hOpts, mOpts...)
+		}
+		if err != nil {
+			logger.Error(err, "failed to initialize the metrics; metrics will not be exported")
+		}
+		setStartedSignal(&metricsSignal, observeCfg.ExporterProtocol(), ep, exported, err)
+	}
+	return shutdown, closeConn
+}
+
+// startedLocally records the outcome of starting a signal that does not export to a collector.
+func startedLocally(s *signal, exporter, path string, shutdown func(context.Context) error, err error) (func(context.Context) error, func(context.Context) error) {
+	if err != nil {
+		logger.Error(err, fmt.Sprintf("failed to initialize the %s exporter", exporter))
+		shutdown = nil
+	}
+	setStartedSignal(s, exporter, path, nil, err)
+	return shutdown, nil
+}
+
+// fileOptions returns the options of the file exporter writing to name in the directory set by observeCfg.
+func fileOptions(name string) localExport.FileOptions {
+	return localExport.FileOptions{
+		Path:       filepath.Join(observeCfg.FileDir(), name),
+		MaxSizeMB:  observeCfg.FileMaxSizeMB(),
+		MaxBackups: observeCfg.FileMaxBackups(),
+	}
+}
+
+func closeFunc(conn *grpc.ClientConn) func(context.Context) error {
+	return func(context.Context) error {
+		return conn.Close()
+	}
+}
@@ -0,0 +1,401 @@
+package observability_test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/twistingmercury/observability"
+	"github.com/twistingmercury/observability/metrics"
+	"github.com/twistingmercury/observability/observeCfg"
+	"github.com/twistingmercury/observability/otlpHttp"
+	"github.com/twistingmercury/observability/testTools"
+	"github.com/twistingmercury/observability/tracer"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func setupStart(traceEndpoint, metricsEndpoint string) {
+	os.Setenv(observeCfg.LogLevelEnvVar, "debug")
+	os.Setenv(observeCfg.TraceEndpointEnvVar, traceEndpoint)
+	os.Setenv(observeCfg.MetricsEndpointEnvVar, metricsEndpoint)
+	os.Setenv(observeCfg.EnvironEnvVar, "localhost")
+}
+
+func tearDownStart() {
+	os.Unsetenv(observeCfg.LogLevelEnvVar)
+	os.Unsetenv(observeCfg.TraceEndpointEnvVar)
+	os.Unsetenv(observeCfg.MetricsEndpointEnvVar)
+	os.Unsetenv(observeCfg.EnvironEnvVar)
+	os.Unsetenv(observeCfg.ExporterProtocolEnvVar)
+	os.Unsetenv(observeCfg.MetricsExporterEnvVar)
+	os.Unsetenv(observeCfg.TracesExporterEnvVar)
+	os.Unsetenv(observeCfg.FileDirEnvVar)
+	os.Unsetenv(observeCfg.ConfigFileEnvVar)
+	os.Unsetenv(observeCfg.SDKDisabledEnvVar)
+	os.Unsetenv(observeCfg.ExporterHeadersEnvVar)
+	os.Unsetenv(observeCfg.ExporterCompressionEnvVar)
+	os.Unsetenv(observeCfg.ExporterCertificateEnvVar)
+	os.Unsetenv(observeCfg.ExporterInsecureEnvVar)
+	os.Unsetenv(observeCfg.GrpcServerNameEnvVar)
+	os.Unsetenv(observeCfg.GrpcKeepaliveTimeEnvVar)
+	os.Unsetenv(observeCfg.GrpcRetryMaxAttemptsEnvVar)
+	os.Unsetenv(observeCfg.GrpcLBPolicyEnvVar)
+	os.Unsetenv(observeCfg.GrpcProxyEnvVar)
+}
+
+func startOptions(logBuf *bytes.Buffer) observability.StartOptions {
+	return observability.StartOptions{
+		ServiceName:      "unit-tests",
+		BuildDate:        "2023-01-01T00:00:00.000",
+		Version:          "0.0.0",
+		Commit:           "abcd0123",
+		MetricsNamespace: "unit.test",
+		LogWriter:        logBuf,
+		WaitForConnect:   true,
+		WaitTimeSeconds:  5,
+	}
+}
+
+func TestStart(t *testing.T) {
+	collector, err := testTools.StartCollector()
+	assert.NoError(t, err)
+	defer collector.Stop()
+
+	setupStart(collector.Addr(), collector.Addr())
+	defer tearDownStart()
+
+	logBuf := &bytes.Buffer{}
+	ctx := context.Background()
+	shutdown, err := observability.Start(ctx, startOptions(logBuf))
+	assert.NoError(t, err)
+	assert.NotNil(t, shutdown)
+	assert.True(t, tracer.IsInitialized())
+	assert.True(t, metrics.IsInitialized())
+	assert.Contains(t, logBuf.String(), "observability started")
+	assert.Equal(t, observability.Healthy, observability.Status().Health)
+
+	_, span := tracer.New(ctx, "start-test", trace.SpanKindInternal)
+	tracer.EndOK(span)
+
+	sCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	assert.NoError(t, shutdown(sCtx))
+	assert.Equal(t, 1, collector.Spans(), "pending spans should be flushed on shutdown")
+	assert.Positive(t, collector.MetricsExports(), "pending metrics should be flushed on shutdown")
+	assert.Equal(t, observability.Disabled, observability.Status().Health)
+}
+
+func TestStart_Credentials(t *testing.T) {
+	collector, err := testTools.StartCollector()
+	assert.NoError(t, err)
+	defer collector.Stop()
+
+	setupStart(collector.Addr(), collector.Addr())
+	defer tearDownStart()
+	secret := filepath.Join(t.TempDir(), "api-key")
+	assert.NoError(t, os.WriteFile(secret, []byte("secret\n"), 0o600))
+	os.Setenv(observeCfg.ExporterHeadersEnvVar, "api-key=${file:"+secret+"}")
+	// the collector is insecure; the credentials are sent in plaintext only if it is explicit.
+	os.Setenv(observeCfg.ExporterInsecureEnvVar, "true")
+
+	ctx := context.Background()
+	shutdown, err := observability.Start(ctx, startOptions(&bytes.Buffer{}))
+	assert.NoError(t, err)
+
+	// the secret is rotated before the spans are exported.
+	assert.NoError(t, os.WriteFile(secret, []byte("rotated-secret\n"), 0o600))
+	_, span := tracer.New(ctx, "credentials-test", trace.SpanKindInternal)
+	tracer.EndOK(span)
+
+	sCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	assert.NoError(t, shutdown(sCtx))
+	assert.Equal(t, []string{"rotated-secret"}, collector.Metadata("api-key"))
+}
+
+func TestStart_InvalidConfig(t *testing.T) {
+	setupStart("localhost:4317", "localhost:4317")
+	defer tearDownStart()
+	os.Setenv(observeCfg.LogLevelEnvVar, "invalid")
+
+	shutdown, err := observability.Start(context.Background(), startOptions(&bytes.Buffer{}))
+	assert.Error(t, err)
+	assert.Nil(t, shutdown)
+}
+
+func TestStart_CollectorUnavailable(t *testing.T) {
+	setupStart("localhost:10101", "localhost:10101")
+	defer tearDownStart()
+
+	logBuf := &bytes.Buffer{}
+	opts := startOptions(logBuf)
+	opts.WaitTimeSeconds = 1
+	ctx := context.Background()
+	shutdown, err := observability.Start(ctx, opts)
+	assert.NoError(t, err, "an unavailable collector must not prevent the service from starting")
+	assert.NotNil(t, shutdown)
+	assert.Contains(t, logBuf.String(), "retrying in the background")
+
+	status := observability.Status()
+	assert.Equal(t, observability.Degraded, status.Health)
+	assert.Equal(t, observability.Degraded, status.Traces.Health)
+	assert.Equal(t, observability.Degraded, status.Metrics.Health)
+	assert.Equal(t, "localhost:10101", status.Traces.Endpoint)
+	assert.NotEmpty(t, status.Traces.LastError)
+
+	// telemetry can still be produced while the collector is unavailable.
+	_, span := tracer.New(ctx, "degraded", trace.SpanKindInternal)
+	tracer.EndOK(span)
+
+	sCtx, cancel := context.WithTimeout(ctx, time.Second)
+	defer cancel()
+	_ = shutdown(sCtx)
+}
+
+func TestStart_IdleConnection(t *testing.T) {
+	tests := []struct {
+		name     string
+		export   bool
+		expected observability.Health
+	}{
+		{"no_export", false, observability.Unknown},
+		{"exported", true, observability.Healthy},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			collector, err := testTools.StartCollector()
+			assert.NoError(t, err)
+			defer collector.Stop()
+
+			setupStart(collector.Addr(), collector.Addr())
+			defer tearDownStart()
+
+			ctx := context.Background()
+			shutdown, err := observability.Start(ctx, startOptions(&bytes.Buffer{}))
+			assert.NoError(t, err)
+			defer func() {
+				sCtx, cancel := context.WithTimeout(ctx, 100*time.Millisecond)
+				defer cancel()
+				_ = shutdown(sCtx)
+			}()
+
+			if tt.export {
+				_, span := tracer.New(ctx, "idle-test", trace.SpanKindInternal)
+				tracer.EndOK(span)
+				assert.NoError(t, otel.GetTracerProvider().(*sdktrace.TracerProvider).ForceFlush(ctx))
+				assert.Equal(t, 1, collector.Spans())
+			}
+
+			// the connection goes idle when the collector closes it.
+			collector.Stop()
+			assert.Eventually(t, func() bool {
+				return observability.Status().Traces.State == "IDLE"
+			}, 5*time.Second, 10*time.Millisecond)
+			assert.Equal(t, tt.expected, observability.Status().Traces.Health)
+		})
+	}
+}
+
+func TestStart_ExportErrorLog(t *testing.T) {
+	collector, err := testTools.StartCollector()
+	assert.NoError(t, err)
+	defer collector.Stop()
+
+	setupStart(collector.Addr(), collector.Addr())
+	defer tearDownStart()
+
+	logBuf := &bytes.Buffer{}
+	ctx := context.Background()
+	shutdown, err := observability.Start(ctx, startOptions(logBuf))
+	assert.NoError(t, err)
+	defer func() { _ = shutdown(ctx) }()
+
+	for i := 0; i < 10; i++ {
+		otel.Handle(fmt.Errorf("export error %d", i))
+	}
+	assert.LessOrEqual(t, strings.Count(logBuf.String(), "failed to export telemetry"), 1,
+		"repeated export errors are logged at most once per interval")
+	assert.Equal(t, "export error 9", observability.Status().LastExportError)
+}
+
+func TestStart_Http(t *testing.T) {
+	receiver := testTools.StartHttpReceiver()
+	defer receiver.Close()
+
+	setupStart(receiver.URL, receiver.URL)
+	defer tearDownStart()
+	os.Setenv(observeCfg.ExporterProtocolEnvVar, observeCfg.HttpJsonProtocol)
+
+	logBuf := &bytes.Buffer{}
+	ctx := context.Background()
+	shutdown, err := observability.Start(ctx, startOptions(logBuf))
+	assert.NoError(t, err)
+	assert.True(t, tracer.IsInitialized())
+	assert.True(t, metrics.IsInitialized())
+
+	status := observability.Status()
+	assert.Equal(t, observability.Healthy, status.Health)
+	assert.Equal(t, observeCfg.HttpJsonProtocol, status.Traces.Protocol)
+	assert.Equal(t, receiver.URL, status.Metrics.Endpoint)
+
+	_, span := tracer.New(ctx, "start-http-test", trace.SpanKindInternal)
+	tracer.EndOK(span)
+
+	sCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	assert.NoError(t, shutdown(sCtx))
+	assert.Len(t, receiver.RequestsTo(otlpHttp.TracesPath), 1, "pending spans should be flushed on shutdown")
+	assert.NotEmpty(t, receiver.RequestsTo(otlpHttp.MetricsPath), "pending metrics should be flushed on shutdown")
+}
+
+func TestStart_HttpExportFailure(t *testing.T) {
+	for _, protocol := range []string{observeCfg.HttpJsonProtocol, observeCfg.HttpProtobufProtocol} {
+		t.Run(protocol, func(t *testing.T) {
+			receiver := testTools.StartHttpReceiver()
+			defer receiver.Close()
+			receiver.SetStatus(http.StatusUnauthorized)
+
+			setupStart(receiver.URL, receiver.URL)
+			defer tearDownStart()
+			os.Setenv(observeCfg.ExporterProtocolEnvVar, protocol)
+
+			ctx := context.Background()
+			shutdown, err := observability.Start(ctx, startOptions(&bytes.Buffer{}))
+			assert.NoError(t, err)
+			assert.Equal(t, observability.Healthy, observability.Status().Health)
+
+			exportSpan := func() {
+				_, span := tracer.New(ctx, "export-failure-test", trace.SpanKindInternal)
+				tracer.EndOK(span)
+				_ = otel.GetTracerProvider().(*sdktrace.TracerProvider).ForceFlush(ctx)
+			}
+
+			// the collector rejects the exports.
+			exportSpan()
+			status := observability.Status()
+			assert.Equal(t, observability.Degraded, status.Health)
+			assert.Equal(t, observability.Degraded, status.Traces.Health)
+			assert.Contains(t, status.Traces.LastError, "401")
+
+			// the signal is healthy again once an export succeeds.
+			receiver.SetStatus(http.StatusOK)
+			exportSpan()
+			assert.Equal(t, observability.Healthy, observability.Status().Traces.Health)
+
+			receiver.SetStatus(http.StatusUnauthorized)
+			sCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+			defer cancel()
+			assert.Error(t, shutdown(sCtx), "the pending metrics are rejected")
+			assert.Equal(t, observability.Degraded, observability.Status().Metrics.Health)
+		})
+	}
+}
+
+func TestStart_Prometheus(t *testing.T) {
+	collector, err := testTools.StartCollector()
+	assert.NoError(t, err)
+	defer collector.Stop()
+
+	setupStart(collector.Addr(), "")
+	defer tearDownStart()
+	os.Setenv(observeCfg.MetricsExporterEnvVar, observeCfg.PrometheusExporter)
+
+	ctx := context.Background()
+	shutdown, err := observability.Start(ctx, startOptions(&bytes.Buffer{}))
+	assert.NoError(t, err)
+	defer func() { _ = shutdown(ctx) }()
+
+	status := observability.Status()
+	assert.Equal(t, observability.Healthy, status.Health)
+	assert.Equal(t, observeCfg.PrometheusExporter, status.Metrics.Protocol)
+
+	w := httptest.NewRecorder()
+	_, e := gin.CreateTestContext(w)
+	e.Use(metrics.Middleware())
+	e.GET("/metrics", metrics.PrometheusHandler())
+	e.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `unit_test_unit_tests_http_active_requests{http_method="GET",http_route="/metrics"} 1`,
+		"the scrape is served while it is active")
+}
+
+func TestStart_LocalExporters(t *testing.T) {
+	setupStart("", "")
+	defer tearDownStart()
+	dir := t.TempDir()
+	os.Setenv(observeCfg.TracesExporterEnvVar, observeCfg.FileExporter)
+	os.Setenv(observeCfg.MetricsExporterEnvVar, observeCfg.ConsoleExporter)
+	os.Setenv(observeCfg.FileDirEnvVar, dir)
+
+	console := &bytes.Buffer{}
+	opts := startOptions(&bytes.Buffer{})
+	opts.ConsoleWriter = console
+	ctx := context.Background()
+	shutdown, err := observability.Start(ctx, opts)
+	assert.NoError(t, err, "no collector endpoint is required")
+
+	status := observability.Status()
+	assert.Equal(t, observability.Healthy, status.Health)
+	assert.Equal(t, observeCfg.FileExporter, status.Traces.Protocol)
+	assert.Equal(t, filepath.Join(dir, "traces.jsonl"), status.Traces.Endpoint)
+	assert.Equal(t, observeCfg.ConsoleExporter, status.Metrics.Protocol)
+
+	_, span := tracer.New(ctx, "start-local-test", trace.SpanKindInternal)
+	tracer.EndOK(span)
+	c, err := metrics.NewCounter("local.counter", "a counter printed to the console")
+	assert.NoError(t, err)
+	c.Add(ctx, 1)
+	assert.NoError(t, shutdown(ctx))
+
+	spans, err := os.ReadFile(filepath.Join(dir, "traces.jsonl"))
+	assert.NoError(t, err)
+	assert.Contains(t, string(spans), `"Name":"start-local-test"`)
+	assert.Contains(t, console.String(), `"Name": "unit.test.unit-tests.local.counter"`)
+}
+
+func TestStart_ConfigFileReload(t *testing.T) {
+	setupStart("", "")
+	defer tearDownStart()
+	os.Unsetenv(observeCfg.LogLevelEnvVar)
+	path := filepath.Join(t.TempDir(), "observability.yaml")
+	config := "log:\n  level: %s\ntraces:\n  exporter: console\nmetrics:\n  exporters: console\n"
+	assert.NoError(t, os.WriteFile(path, []byte(fmt.Sprintf(config, "info")), 0o600))
+	os.Setenv(observeCfg.ConfigFileEnvVar, path)
+
+	opts := startOptions(&bytes.Buffer{})
+	opts.ConsoleWriter = &bytes.Buffer{}
+	ctx := context.Background()
+	shutdown, err := observability.Start(ctx, opts)
+	assert.NoError(t, err)
+	defer func() { _ = shutdown(ctx) }()
+	assert.Equal(t, logrus.InfoLevel, logrus.GetLevel())
+
+	assert.NoError(t, os.WriteFile(path, []byte(fmt.Sprintf(config, "error")), 0o600))
+	assert.Eventually(t, func() bool { return logrus.GetLevel() == logrus.ErrorLevel }, 5*time.Second, 10*time.Millisecond,
+		"the log level follows the config file")
+}
+
+func TestStart_SDKDisabled(t *testing.T) {
+	setupStart("", "")
+	defer tearDownStart()
+	os.Setenv(observeCfg.SDKDisabledEnvVar, "true")
+
+	ctx := context.Background()
+	shutdown, err := observability.Start(ctx, startOptions(&bytes.Buffer{}))
+	assert.NoError(t, err)
+	assert.Equal(t, observability.Disabled, observability.Status().Health)
+	assert.Equal(t, observability.Disabled, observability.Status().Traces.Health)
+	assert.NoError(t, shutdown(ctx))
+}
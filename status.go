@@ -0,0 +1,251 @@
+package observability
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/twistingmercury/observability/logger"
+	"github.com/twistingmercury/observability/observeCfg"
+	"go.opentelemetry.io/otel"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/connectivity"
+)
+
+// Health describes whether telemetry is being delivered.
+type Health string
+
+const (
+	// Healthy means the connection to the collector is established, or idle after telemetry was exported, and
+	// the most recent export, if any, succeeded.
+	Healthy Health = "healthy"
+	// Unknown means the connection to the collector is idle and no telemetry has been exported yet, so whether the
+	// collector is reachable is not known.
+	Unknown Health = "unknown"
+	// Degraded means the connection to the collector is being retried in the background, or the most recent export
+	// failed, e.g. because the collector rejected the credentials. Telemetry produced in the meantime may be lost.
+	Degraded Health = "degraded"
+	// Disabled means the signal is not exported; its providers are no-ops.
+	Disabled Health = "disabled"
+)
+
+// SignalStatus is the status of a single signal, such as traces or metrics.
+type SignalStatus struct {
+	Health    Health    `json:"health"`
+	Protocol  string    `json:"protocol,omitempty"`
+	Endpoint  string    `json:"endpoint,omitempty"`
+	State     string    `json:"state,omitempty"`
+	LastError string    `json:"last_error,omitempty"`
+	ErrorTime time.Time `json:"error_time,omitempty"`
+}
+
+// StatusReport is the status of all the signals started by Start.
+type StatusReport struct {
+	Health          Health       `json:"health"`
+	Traces          SignalStatus `json:"traces"`
+	Metrics         SignalStatus `json:"metrics"`
+	LastExportError string       `json:"last_export_error,omitempty"`
+	ExportErrorTime time.Time    `json:"export_error_time,omitempty"`
+}
+
+// signal tracks the state of a signal started by Start. Signals exported using OTLP over HTTP, or only pulled by
+// Prometheus, have no connection; they are healthy once started, unless their most recent export failed.
+type signal struct {
+	protocol string
+	endpoint string
+	conn     *grpc.ClientConn
+	exports  *exports
+	started  bool
+	err      error
+	errTime  time.Time
+}
+
+// exports records the outcome of the exports of a signal, passed by the interceptor returned by
+// exportedInterceptor or by the otlpHttp.Options.ExportResult of its exporters.
+type exports struct {
+	mu        sync.Mutex
+	succeeded bool
+	err       error
+	errTime   time.Time
+}
+
+// record records the outcome of an export; err is nil if it succeeded.
+func (e *exports) record(err error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if err == nil {
+		e.succeeded, e.err = true, nil
+		return
+	}
+	e.err, e.errTime = err, time.Now()
+}
+
+// last returns whether an export ever succeeded, and the error of the most recent export, if it failed, and when.
+// A nil e has exported nothing.
+func (e *exports) last() (succeeded bool, failed error, failedTime time.Time) {
+	if e == nil {
+		return false, nil, time.Time{}
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.succeeded, e.err, e.errTime
+}
+
+// exportErrorLogInterval is the minimum time between two logs of the export errors; the errors in between are
+// counted, and the count is logged with the next one.
+const exportErrorLogInterval = time.Minute
+
+var (
+	statusMu         sync.RWMutex
+	traceSignal      signal
+	metricsSignal    signal
+	exportErr        error
+	exportErrTime    time.Time
+	errorHandlerOnce sync.Once
+
+	exportErrLogTime    time.Time
+	exportErrSuppressed int
+)
+
+// Status reports whether the traces and metrics started by Start are being delivered to the collector.
+func Status() StatusReport {
+	statusMu.RLock()
+	defer statusMu.RUnlock()
+
+	r := StatusReport{
+		Traces:  traceSignal.status(),
+		Metrics: metricsSignal.status(),
+	}
+	if exportErr != nil {
+		r.LastExportError = exportErr.Error()
+		r.ExportErrorTime = exportErrTime
+	}
+
+	switch {
+	case r.Traces.Health == Healthy && r.Metrics.Health == Healthy:
+		r.Health = Healthy
+	case r.Traces.Health == Disabled && r.Metrics.Health == Disabled:
+		r.Health = Disabled
+	case r.Traces.Health == Degraded || r.Metrics.Health == Degraded:
+		r.Health = Degraded
+	case r.Traces.Health == Unknown || r.Metrics.Health == Unknown:
+		r.Health = Unknown
+	default:
+		r.Health = Degraded
+	}
+	return r
+}
+
+func (s signal) status() SignalStatus {
+	ss := SignalStatus{Health: Disabled, Protocol: s.protocol, Endpoint: s.endpoint}
+	if s.err != nil {
+		ss.LastError = s.err.Error()
+		ss.ErrorTime = s.errTime
+	}
+	exported, exportErr, exportErrTime := s.exports.last()
+	if exportErr != nil {
+		ss.LastError = exportErr.Error()
+		ss.ErrorTime = exportErrTime
+	}
+	if s.started {
+		ss.Health = Healthy
+		if exportErr != nil {
+			ss.Health = Degraded
+		}
+		return ss
+	}
+	if s.conn == nil {
+		return ss
+	}
+
+	state := s.conn.GetState()
+	ss.State = state.String()
+	switch state {
+	case connectivity.Ready:
+		// a connection may be established while the collector rejects the exports, e.g. their credentials.
+		ss.Health = Healthy
+		if exportErr != nil {
+			ss.Health = Degraded
+		}
+	case connectivity.Idle:
+		// an idle connection is healthy once telemetry was exported through it, unless it was created after a
+		// failed attempt to connect or the most recent export failed.
+		switch {
+		case s.err != nil || exportErr != nil:
+			ss.Health = Degraded
+		case exported:
+			ss.Health = Healthy
+		default:
+			ss.Health = Unknown
+		}
+	case connectivity.Shutdown:
+		ss.Health = Disabled
+	default:
+		ss.Health = Degraded
+	}
+	return ss
+}
+
+// setSignal records the connection, or the error that prevented it, of a signal. e records the exports through the
+// connection, passed by the interceptor returned by exportedInterceptor.
+func setSignal(s *signal, endpoint string, conn *grpc.ClientConn, e *exports, err error) {
+	statusMu.Lock()
+	defer statusMu.Unlock()
+	*s = signal{protocol: observeCfg.GrpcProtocol, endpoint: endpoint, conn: conn, exports: e}
+	if err != nil {
+		s.err = err
+		s.errTime = time.Now()
+	}
+}
+
+// setStartedSignal records whether a signal that has no connection to the collector was started. e records its
+// exports, if it exports to a collector.
+func setStartedSignal(s *signal, protocol, endpoint string, e *exports, err error) {
+	statusMu.Lock()
+	defer statusMu.Unlock()
+	*s = signal{protocol: protocol, endpoint: endpoint, exports: e, started: err == nil}
+	if err != nil {
+		s.err = err
+		s.errTime = time.Now()
+	}
+}
+
+// exportedInterceptor returns the interceptor that records the outcome of every call, i.e. an export, in e.
+func exportedInterceptor(e *exports) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		err := invoker(ctx, method, req, reply, cc, opts...)
+		e.record(err)
+		return err
+	}
+}
+
+// disableSignal records that a signal is not exported.
+func disableSignal(s *signal) {
+	statusMu.Lock()
+	defer statusMu.Unlock()
+	*s = signal{}
+}
+
+// registerErrorHandler records, and logs, the errors the OpenTelemetry SDK encounters while exporting. An
+// unavailable collector fails every export, so the errors are logged at most once per exportErrorLogInterval.
+func registerErrorHandler() {
+	errorHandlerOnce.Do(func() {
+		otel.SetErrorHandler(otel.ErrorHandlerFunc(func(err error) {
+			statusMu.Lock()
+			exportErr = err
+			exportErrTime = time.Now()
+			if exportErrTime.Sub(exportErrLogTime) < exportErrorLogInterval {
+				exportErrSuppressed++
+				statusMu.Unlock()
+				return
+			}
+			suppressed := exportErrSuppressed
+			exportErrLogTime = exportErrTime
+			exportErrSuppressed = 0
+			statusMu.Unlock()
+			logger.Warn("failed to export telemetry",
+				logger.Attribute{Key: "error", Value: err.Error()},
+				logger.Attribute{Key: "suppressed_errors", Value: suppressed})
+		}))
+	})
+}
@@ -0,0 +1,113 @@
+package testTools
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// TLSServerName is the name the certificate of the TLS collector is issued for.
+const TLSServerName = "collector.test"
+
+// TLSFiles are the paths of the PEM files of a TLS collector: the CA that issued the certificates, and the client
+// certificate and key the collector requires.
+type TLSFiles struct {
+	CAFile   string
+	CertFile string
+	KeyFile  string
+}
+
+// writeCertificates writes a CA, and the client certificate and key it issued, to dir. It returns their paths, and
+// the server TLS config, whose certificate is issued for TLSServerName, requiring a client certificate of the CA.
+func writeCertificates(dir string) (TLSFiles, *tls.Config, error) {
+	files := TLSFiles{
+		CAFile:   filepath.Join(dir, "ca.pem"),
+		CertFile: filepath.Join(dir, "client.pem"),
+		KeyFile:  filepath.Join(dir, "client-key.pem"),
+	}
+
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return files, nil, err
+	}
+	ca := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, ca, ca, &caKey.PublicKey, caKey)
+	if err != nil {
+		return files, nil, err
+	}
+	if ca, err = x509.ParseCertificate(caDER); err != nil {
+		return files, nil, err
+	}
+
+	issue := func(serial int64, usage x509.ExtKeyUsage, dnsNames ...string) (tls.Certificate, []byte, []byte, error) {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			return tls.Certificate{}, nil, nil, err
+		}
+		tmpl := &x509.Certificate{
+			SerialNumber: big.NewInt(serial),
+			Subject:      pkix.Name{CommonName: "test"},
+			DNSNames:     dnsNames,
+			NotBefore:    time.Now().Add(-time.Hour),
+			NotAfter:     time.Now().Add(time.Hour),
+			KeyUsage:     x509.KeyUsageDigitalSignature,
+			ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+		}
+		der, err := x509.CreateCertificate(rand.Reader, tmpl, ca, &key.PublicKey, caKey)
+		if err != nil {
+			return tls.Certificate{}, nil, nil, err
+		}
+		keyDER, err := x509.MarshalECPrivateKey(key)
+		if err != nil {
+			return tls.Certificate{}, nil, nil, err
+		}
+		certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+		keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+		cert, err := tls.X509KeyPair(certPEM, keyPEM)
+		return cert, certPEM, keyPEM, err
+	}
+
+	serverCert, _, _, err := issue(2, x509.ExtKeyUsageServerAuth, TLSServerName)
+	if err != nil {
+		return files, nil, err
+	}
+	_, clientPEM, clientKeyPEM, err := issue(3, x509.ExtKeyUsageClientAuth)
+	if err != nil {
+		return files, nil, err
+	}
+
+	for path, b := range map[string][]byte{
+		files.CAFile:   pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: caDER}),
+		files.CertFile: clientPEM,
+		files.KeyFile:  clientKeyPEM,
+	} {
+		if err := os.WriteFile(path, b, 0o600); err != nil {
+			return files, nil, err
+		}
+	}
+
+	pool := x509.NewCertPool()
+	pool.AddCert(ca)
+	return files, &tls.Config{
+		Certificates: []tls.Certificate{serverCert},
+		ClientCAs:    pool,
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		MinVersion:   tls.VersionTLS12,
+	}, nil
+}
@@ -0,0 +1,120 @@
+package testTools
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net"
+	"sync"
+
+	collectorMetrics "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	collectorTrace "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+)
+
+// Collector is a minimal OpenTelemetry collector that accepts traces and metrics over gRPC and counts what it
+// receives.
+type Collector struct {
+	collectorTrace.UnimplementedTraceServiceServer
+
+	mu             sync.Mutex
+	spans          int
+	metricsExports int
+	md             metadata.MD
+
+	lis net.Listener
+	svr *grpc.Server
+}
+
+// StartCollector starts a Collector listening on a random local TCP port.
+func StartCollector() (*Collector, error) {
+	return startCollector()
+}
+
+// StartTLSCollector starts a Collector listening on a random local TCP port using mutual TLS. The certificates are
+// written to dir; the certificate of the collector is issued for TLSServerName.
+func StartTLSCollector(dir string) (*Collector, TLSFiles, error) {
+	files, cfg, err := writeCertificates(dir)
+	if err != nil {
+		return nil, files, err
+	}
+	c, err := startCollector(grpc.Creds(credentials.NewTLS(cfg)))
+	return c, files, err
+}
+
+func startCollector(opts ...grpc.ServerOption) (*Collector, error) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+
+	c := &Collector{lis: l, svr: grpc.NewServer(opts...)}
+	collectorTrace.RegisterTraceServiceServer(c.svr, c)
+	collectorMetrics.RegisterMetricsServiceServer(c.svr, metricsService{c: c})
+	go func() {
+		if err := c.svr.Serve(l); err != nil && !errors.Is(err, grpc.ErrServerStopped) {
+			log.Fatalf("Collector exited with error: %v", err)
+		}
+	}()
+	return c, nil
+}
+
+// Addr returns the host and port the collector is listening on.
+func (c *Collector) Addr() string {
+	return c.lis.Addr().String()
+}
+
+// Stop stops the collector.
+func (c *Collector) Stop() {
+	c.svr.Stop()
+}
+
+// Spans returns the number of spans received.
+func (c *Collector) Spans() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.spans
+}
+
+// MetricsExports returns the number of metrics export requests received.
+func (c *Collector) MetricsExports() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.metricsExports
+}
+
+// Metadata returns the values of the metadata key sent with the last traces export request.
+func (c *Collector) Metadata(key string) []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.md.Get(key)
+}
+
+// Export satisfies collectorTrace.TraceServiceServer.
+func (c *Collector) Export(ctx context.Context, req *collectorTrace.ExportTraceServiceRequest) (*collectorTrace.ExportTraceServiceResponse, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.md, _ = metadata.FromIncomingContext(ctx)
+	for _, rs := range req.ResourceSpans {
+		for _, ss := range rs.ScopeSpans {
+			c.spans += len(ss.Spans)
+		}
+	}
+	return &collectorTrace.ExportTraceServiceResponse{}, nil
+}
+
+// metricsService adapts the Collector to collectorMetrics.MetricsServiceServer, whose Export method conflicts
+// with the trace service's.
+type metricsService struct {
+	collectorMetrics.UnimplementedMetricsServiceServer
+	c *Collector
+}
+
+func (m metricsService) Export(context.Context, *collectorMetrics.ExportMetricsServiceRequest) (*collectorMetrics.ExportMetricsServiceResponse, error) {
+	m.c.mu.Lock()
+	defer m.c.mu.Unlock()
+	m.c.metricsExports++
+	return &collectorMetrics.ExportMetricsServiceResponse{}, nil
+}
@@ -0,0 +1,115 @@
+package testTools
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"time"
+)
+
+// HttpRequest is an export request received by an HttpReceiver. Body is decompressed if the request was gzipped.
+type HttpRequest struct {
+	Path   string
+	Header http.Header
+	Body   []byte
+}
+
+// HttpReceiver is an in-process OTLP/HTTP receiver that records the export requests it receives.
+type HttpReceiver struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	requests []HttpRequest
+	delay    time.Duration
+	status   int
+}
+
+// StartHttpReceiver starts an OTLP/HTTP receiver listening on a random port using plain HTTP.
+func StartHttpReceiver() *HttpReceiver {
+	r := &HttpReceiver{}
+	r.Server = httptest.NewServer(http.HandlerFunc(r.handle))
+	return r
+}
+
+// StartHttpsReceiver starts an OTLP/HTTP receiver listening on a random port using HTTPS. Its self-signed
+// certificate is returned by Certificate.
+func StartHttpsReceiver() *HttpReceiver {
+	r := &HttpReceiver{}
+	r.Server = httptest.NewTLSServer(http.HandlerFunc(r.handle))
+	return r
+}
+
+// SetDelay delays every response of the receiver by d.
+func (r *HttpReceiver) SetDelay(d time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.delay = d
+}
+
+// SetStatus responds to every request of the receiver with the given status code, e.g. http.StatusUnauthorized to
+// reject the exports. The requests are still recorded.
+func (r *HttpReceiver) SetStatus(code int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.status = code
+}
+
+// Requests returns the export requests received so far.
+func (r *HttpReceiver) Requests() []HttpRequest {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]HttpRequest(nil), r.requests...)
+}
+
+// RequestsTo returns the export requests received so far for the given path.
+func (r *HttpReceiver) RequestsTo(path string) (reqs []HttpRequest) {
+	for _, req := range r.Requests() {
+		if req.Path == path {
+			reqs = append(reqs, req)
+		}
+	}
+	return reqs
+}
+
+func (r *HttpReceiver) handle(w http.ResponseWriter, req *http.Request) {
+	r.mu.Lock()
+	delay := r.delay
+	r.mu.Unlock()
+	if delay > 0 {
+		select {
+		case <-time.After(delay):
+		case <-req.Context().Done():
+			return
+		}
+	}
+
+	var body io.Reader = req.Body
+	if req.Header.Get("Content-Encoding") == "gzip" {
+		gz, err := gzip.NewReader(req.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		defer gz.Close()
+		body = gz
+	}
+	b, err := io.ReadAll(body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	r.mu.Lock()
+	r.requests = append(r.requests, HttpRequest{Path: req.URL.Path, Header: req.Header.Clone(), Body: bytes.Clone(b)})
+	status := r.status
+	r.mu.Unlock()
+	if status == 0 {
+		status = http.StatusOK
+	}
+
+	w.Header().Set("Content-Type", req.Header.Get("Content-Type"))
+	w.WriteHeader(status)
+}
@@ -3,6 +3,7 @@ package testTools
 
 import (
 	"context"
+	"errors"
 	"go.opentelemetry.io/otel/trace"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials/insecure"
@@ -22,10 +23,10 @@ var (
 
 // DialContext returns a grpc.ClientConn connected to a bufconn.Listener
 func DialContext(ctx context.Context) (*grpc.ClientConn, error) {
-	setupTestSvr()
+	l := setupTestSvr()
 	return grpc.DialContext(ctx,
 		"bufnet",
-		grpc.WithContextDialer(bufDialer),
+		grpc.WithContextDialer(bufDialer(l)),
 		grpc.WithTransportCredentials(insecure.NewCredentials()))
 }
 
@@ -45,11 +46,13 @@ func EmptySpanId() string {
 	return emptySpanId
 }
 
-func setupTestSvr() {
-	lis = bufconn.Listen(bufSize)
-	svr = grpc.NewServer()
+func setupTestSvr() *bufconn.Listener {
+	l := bufconn.Listen(bufSize)
+	s := grpc.NewServer()
+	lis = l
+	svr = s
 	go func() {
-		if err := svr.Serve(lis); err != nil {
+		if err := s.Serve(l); err != nil && !errors.Is(err, grpc.ErrServerStopped) {
 			log.Fatalf("Server exited with error: %v", err)
 		}
 	}()
@@ -57,8 +60,11 @@ func setupTestSvr() {
 	ts := new(trace.SpanContext)
 	emptyTraceId = ts.TraceID().String()
 	emptySpanId = ts.SpanID().String()
+	return l
 }
 
-func bufDialer(context.Context, string) (net.Conn, error) {
-	return lis.Dial()
+func bufDialer(l *bufconn.Listener) func(context.Context, string) (net.Conn, error) {
+	return func(context.Context, string) (net.Conn, error) {
+		return l.Dial()
+	}
 }
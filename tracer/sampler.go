@@ -0,0 +1,67 @@
+package tracer
+
+import (
+	"fmt"
+	"sync/atomic"
+
+	"github.com/twistingmercury/observability/observeCfg"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// NewSampler returns the sampler named by the OpenTelemetry specification, e.g. `parentbased_traceidratio`. The
+// ratio is used by the `traceidratio` samplers. An empty name is `always_on`, the default sampler, so that every span
+// is sampled as before the sampler could be configured.
+func NewSampler(name string, ratio float64) (sdktrace.Sampler, error) {
+	switch name {
+	case observeCfg.AlwaysOnSampler, "":
+		return sdktrace.AlwaysSample(), nil
+	case observeCfg.AlwaysOffSampler:
+		return sdktrace.NeverSample(), nil
+	case observeCfg.TraceIDRatioSampler:
+		return sdktrace.TraceIDRatioBased(ratio), nil
+	case observeCfg.ParentBasedAlwaysOnSampler:
+		return sdktrace.ParentBased(sdktrace.AlwaysSample()), nil
+	case observeCfg.ParentBasedAlwaysOffSampler:
+		return sdktrace.ParentBased(sdktrace.NeverSample()), nil
+	case observeCfg.ParentBasedTraceIDRatioSampler:
+		return sdktrace.ParentBased(sdktrace.TraceIDRatioBased(ratio)), nil
+	}
+	return nil, fmt.Errorf("invalid sampler: %s", name)
+}
+
+// SetSampler replaces the sampler of the tracer, e.g. when the sampling ratio is reloaded from the config file. It
+// is safe to invoke concurrently with New.
+func SetSampler(s sdktrace.Sampler) {
+	sampler.set(s)
+}
+
+// sampler is the sampler of the tracer provider. It delegates to the sampler set by SetSampler, which the tracer
+// provider does not allow to change.
+var sampler = &swappableSampler{}
+
+type samplerHolder struct {
+	sdktrace.Sampler
+}
+
+type swappableSampler struct {
+	current atomic.Pointer[samplerHolder]
+}
+
+func (s *swappableSampler) set(sampler sdktrace.Sampler) {
+	s.current.Store(&samplerHolder{sampler})
+}
+
+func (s *swappableSampler) get() sdktrace.Sampler {
+	if h := s.current.Load(); h != nil {
+		return h.Sampler
+	}
+	return sdktrace.AlwaysSample()
+}
+
+func (s *swappableSampler) ShouldSample(p sdktrace.SamplingParameters) sdktrace.SamplingResult {
+	return s.get().ShouldSample(p)
+}
+
+func (s *swappableSampler) Description() string {
+	return s.get().Description()
+}
@@ -0,0 +1,54 @@
+package tracer_test
+
+import (
+	"context"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/twistingmercury/observability/observeCfg"
+	"github.com/twistingmercury/observability/tracer"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestNewSampler(t *testing.T) {
+	tests := map[string]string{
+		observeCfg.AlwaysOnSampler:                "AlwaysOnSampler",
+		observeCfg.AlwaysOffSampler:               "AlwaysOffSampler",
+		observeCfg.TraceIDRatioSampler:            "TraceIDRatioBased{0.25}",
+		observeCfg.ParentBasedAlwaysOnSampler:     "ParentBased{root:AlwaysOnSampler",
+		observeCfg.ParentBasedAlwaysOffSampler:    "ParentBased{root:AlwaysOffSampler",
+		observeCfg.ParentBasedTraceIDRatioSampler: "ParentBased{root:TraceIDRatioBased{0.25}",
+		"": "AlwaysOnSampler",
+	}
+	for name, description := range tests {
+		s, err := tracer.NewSampler(name, 0.25)
+		require.NoError(t, err, name)
+		assert.Contains(t, s.Description(), description, name)
+	}
+
+	_, err := tracer.NewSampler("jaeger_remote", 1)
+	assert.Error(t, err)
+}
+
+func TestSetSampler(t *testing.T) {
+	ctx := context.Background()
+	shutdown, err := tracer.InitializeConsole(io.Discard)
+	require.NoError(t, err)
+	defer func() {
+		_ = shutdown(ctx)
+		tracer.Reset()
+	}()
+
+	_, span := tracer.New(ctx, "sampled", trace.SpanKindInternal)
+	tracer.EndOK(span)
+	assert.True(t, span.SpanContext().IsSampled())
+
+	tracer.SetSampler(sdktrace.NeverSample())
+	defer tracer.SetSampler(sdktrace.AlwaysSample())
+	_, span = tracer.New(ctx, "dropped", trace.SpanKindInternal)
+	tracer.EndOK(span)
+	assert.False(t, span.SpanContext().IsSampled(), "the sampler is changed without initializing the tracer again")
+}
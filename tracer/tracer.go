@@ -5,25 +5,36 @@ import (
 	"context"
 	"fmt"
 	"github.com/twistingmercury/observability/logger"
+	"io"
+	"sync"
 
+	"github.com/twistingmercury/observability/localExport"
 	"github.com/twistingmercury/observability/observeCfg"
+	"github.com/twistingmercury/observability/otlpHttp"
+	"github.com/twistingmercury/observability/resources"
 	"go.opentelemetry.io/otel/attribute"
 	otelCodes "go.opentelemetry.io/otel/codes"
 	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
 
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
 	"go.opentelemetry.io/otel/propagation"
-	"go.opentelemetry.io/otel/sdk/resource"
 	sdktrace "go.opentelemetry.io/otel/sdk/trace"
-	semconv "go.opentelemetry.io/otel/semconv/v1.4.0"
 	"google.golang.org/grpc"
 )
 
 var (
 	isInitialized  bool
 	tracerProvider *sdktrace.TracerProvider
-	tracer         trace.Tracer
+
+	// tracer is a no-op tracer until Initialize succeeds, so spans can always be started.
+	tracer = noopTracer()
+
+	// defaultAttrs are added to every span started by New. Service level attributes are not span attributes;
+	// they are set on the resource by Initialize; see the resources package.
+	defaultAttrsMu sync.RWMutex
+	defaultAttrs   []attribute.KeyValue
 )
 
 // IsInitialized returns true if the tracer has been successfully initialized.
@@ -37,37 +48,105 @@ func reset() {
 	}
 	_ = tracerProvider.Shutdown(context.Background())
 	tracerProvider = nil
-	tracer = nil
+	tracer = noopTracer()
 	isInitialized = false
 	logger.Debug("tracer reset")
 }
 
-// Initialize initializes the OpenTelemetry tracing library.
-func Initialize(conn *grpc.ClientConn) (func(context.Context) error, error) {
+func noopTracer() trace.Tracer {
+	return noop.NewTracerProvider().Tracer("")
+}
+
+// Option configures the exporter created by Initialize.
+type Option func(*config)
+
+type config struct {
+	// headers is nil unless set by WithExporterHeaders.
+	headers map[string]string
+}
+
+// WithExporterHeaders sets the headers sent by the exporter created by Initialize, instead of those of the
+// environment variable `OTEL_EXPORTER_OTLP_HEADERS`. An empty map sends no header, e.g. when they are sent by the
+// per-RPC credentials of the connection, see observeCfg.ExporterCredentials.
+func WithExporterHeaders(headers map[string]string) Option {
+	return func(c *config) {
+		c.headers = headers
+	}
+}
+
+// Initialize initializes the OpenTelemetry tracing library. Until it succeeds, spans started by New are no-ops.
+func Initialize(conn *grpc.ClientConn, opts ...Option) (func(context.Context) error, error) {
 	isInitialized = false
 	ctx := context.Background()
+	cfg := &config{}
+	for _, opt := range opts {
+		opt(cfg)
+	}
 
-	res, err := resource.New(ctx,
-		resource.WithAttributes(
-			semconv.ServiceNameKey.String(observeCfg.ServiceName()),
-			semconv.ServiceVersionKey.String(observeCfg.Version()),
-			attribute.String("service.build_date", observeCfg.BuildDate()),
-			attribute.String("service.commit", observeCfg.CommitHash()),
-		),
-	)
+	// Set up a trace exporter
+	eOpts := []otlptracegrpc.Option{otlptracegrpc.WithGRPCConn(conn)}
+	if cfg.headers != nil {
+		eOpts = append(eOpts, otlptracegrpc.WithHeaders(cfg.headers))
+	}
+	traceExporter, err := otlptracegrpc.New(ctx, eOpts...)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create resource: %w", err)
+		return nil, fmt.Errorf("failed to create trace exporter: %w", err)
 	}
+	return initialize(ctx, traceExporter)
+}
 
-	// Set up a trace exporter
-	traceExporter, err := otlptracegrpc.New(ctx, otlptracegrpc.WithGRPCConn(conn))
+// InitializeHttp initializes the OpenTelemetry tracing library using OTLP over HTTP, encoded as either protobuf or
+// JSON, instead of gRPC. Until it succeeds, spans started by New are no-ops.
+func InitializeHttp(opts otlpHttp.Options) (func(context.Context) error, error) {
+	isInitialized = false
+	ctx := context.Background()
+
+	traceExporter, err := otlpHttp.NewTraceExporter(ctx, opts)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create trace exporter: %w", err)
 	}
+	return initialize(ctx, traceExporter)
+}
+
+// InitializeConsole initializes the OpenTelemetry tracing library to pretty-print spans to w, or to os.Stdout if w
+// is nil. It is intended for local development, when no collector is running.
+func InitializeConsole(w io.Writer) (func(context.Context) error, error) {
+	isInitialized = false
+	traceExporter, err := localExport.NewConsoleTraceExporter(w)
+	if err != nil {
+		return nil, err
+	}
+	return initialize(context.Background(), traceExporter)
+}
+
+// InitializeFile initializes the OpenTelemetry tracing library to write each span as a JSON line to a rotating
+// file. It is intended for local development, when no collector is running.
+func InitializeFile(opts localExport.FileOptions) (func(context.Context) error, error) {
+	isInitialized = false
+	traceExporter, err := localExport.NewFileTraceExporter(opts)
+	if err != nil {
+		return nil, err
+	}
+	return initialize(context.Background(), traceExporter)
+}
+
+func initialize(ctx context.Context, traceExporter sdktrace.SpanExporter) (func(context.Context) error, error) {
+	res, err := resources.New(ctx)
+	if err != nil {
+		_ = traceExporter.Shutdown(ctx)
+		return nil, err
+	}
+
+	s, err := NewSampler(observeCfg.TracesSampler(), observeCfg.TracesSamplerArg())
+	if err != nil {
+		_ = traceExporter.Shutdown(ctx)
+		return nil, err
+	}
+	sampler.set(s)
 
 	bsp := sdktrace.NewBatchSpanProcessor(traceExporter)
 	tracerProvider = sdktrace.NewTracerProvider(
-		sdktrace.WithSampler(sdktrace.AlwaysSample()),
+		sdktrace.WithSampler(sampler),
 		sdktrace.WithResource(res),
 		sdktrace.WithSpanProcessor(bsp),
 	)
@@ -81,35 +160,50 @@ func Initialize(conn *grpc.ClientConn) (func(context.Context) error, error) {
 	return tracerProvider.Shutdown, nil
 }
 
-// Attributes represent additional key-value descriptors that can be bound
-// to a metric observer or recorder.
-var commonAttrs = []attribute.KeyValue{
-	semconv.ServiceNameKey.String(observeCfg.ServiceName()),
-	semconv.ServiceVersionKey.String(observeCfg.Version()),
-	attribute.String("service.build_date", observeCfg.BuildDate()),
-	attribute.String("service.commit", observeCfg.CommitHash()),
-	attribute.String("service.environment", observeCfg.Environment()),
-	attribute.String("host", observeCfg.HostName()),
-	attribute.String("container_id", observeCfg.HostName()),
+// SetDefaultAttributes replaces the attributes that are added to every span started by New. It is safe to
+// invoke concurrently with New.
+func SetDefaultAttributes(attributes ...attribute.KeyValue) {
+	defaultAttrsMu.Lock()
+	defer defaultAttrsMu.Unlock()
+	defaultAttrs = append([]attribute.KeyValue(nil), attributes...)
+}
+
+// AddDefaultAttributes appends attributes to those that are added to every span started by New. It is safe to
+// invoke concurrently with New.
+func AddDefaultAttributes(attributes ...attribute.KeyValue) {
+	defaultAttrsMu.Lock()
+	defer defaultAttrsMu.Unlock()
+	defaultAttrs = append(defaultAttrs, attributes...)
+}
+
+// DefaultAttributes returns a copy of the attributes that are added to every span started by New.
+func DefaultAttributes() []attribute.KeyValue {
+	defaultAttrsMu.RLock()
+	defer defaultAttrsMu.RUnlock()
+	return append([]attribute.KeyValue(nil), defaultAttrs...)
 }
 
 // New starts a new span with the given name and returns the context and span.
 // If spanCtx is nil, context.Background() is used.
 // The arg kind is used to set the span kind. The constant trace.SpanKind is defined here: https://pkg.go.dev/go.opentelemetry.io/otel/trace@v1.15.1#SpanKind
+// The span carries the default attributes followed by the given attributes; the given attributes apply only
+// to this span.
 func New(spanCtx context.Context, spanName string, kind trace.SpanKind, attributes ...attribute.KeyValue) (ctx context.Context, span trace.Span) {
 	if spanCtx == nil {
 		spanCtx = context.Background()
 	}
 
-	if len(attributes) > 0 {
-		commonAttrs = append(commonAttrs, attributes...)
-	}
+	defaultAttrsMu.RLock()
+	attrs := make([]attribute.KeyValue, 0, len(defaultAttrs)+len(attributes))
+	attrs = append(attrs, defaultAttrs...)
+	defaultAttrsMu.RUnlock()
+	attrs = append(attrs, attributes...)
 
 	ctx, span = tracer.Start(
 		spanCtx,
 		spanName,
 		trace.WithSpanKind(kind),
-		trace.WithAttributes(commonAttrs...))
+		trace.WithAttributes(attrs...))
 
 	return
 }
@@ -4,13 +4,20 @@ import (
 	"bytes"
 	"context"
 	"errors"
+	"fmt"
 	"github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"github.com/twistingmercury/observability/logger"
+	"github.com/twistingmercury/observability/observeCfg"
 	"github.com/twistingmercury/observability/testTools"
 	"github.com/twistingmercury/observability/tracer"
 	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
 	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"sync"
 	"testing"
 )
 
@@ -52,3 +59,99 @@ func TestTracing(t *testing.T) {
 	assert.NotEqual(t, testTools.EmptySpanId(), span.SpanContext().SpanID().String())
 	defer tracer.EndError(span, errors.New("test error"))
 }
+
+func TestInitialize_ExporterHeaders(t *testing.T) {
+	collector, err := testTools.StartCollector()
+	require.NoError(t, err)
+	defer collector.Stop()
+	t.Setenv(observeCfg.ExporterHeadersEnvVar, "api-key=secret")
+
+	ctx := context.Background()
+	export := func(opts ...tracer.Option) {
+		conn, err := grpc.NewClient(collector.Addr(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+		require.NoError(t, err)
+		defer conn.Close()
+		shutdown, err := tracer.Initialize(conn, opts...)
+		require.NoError(t, err)
+		_, span := tracer.New(ctx, "headers", trace.SpanKindInternal)
+		tracer.EndOK(span)
+		require.NoError(t, shutdown(ctx))
+	}
+
+	export()
+	assert.Equal(t, []string{"secret"}, collector.Metadata("api-key"), "the exporter sends `OTEL_EXPORTER_OTLP_HEADERS`")
+	export(tracer.WithExporterHeaders(map[string]string{}))
+	assert.Empty(t, collector.Metadata("api-key"))
+}
+
+func TestNew_AttributesDoNotLeak(t *testing.T) {
+	ctx := context.Background()
+	conn, err := testTools.DialContext(ctx)
+	assert.NoError(t, err)
+
+	shutdown, err := tracer.Initialize(conn)
+	assert.NoError(t, err)
+	defer func() {
+		_ = shutdown(ctx)
+		testTools.Reset(ctx)
+	}()
+
+	tracer.SetDefaultAttributes(attribute.String("default", "value"))
+	defer tracer.SetDefaultAttributes()
+
+	_, first := tracer.New(ctx, "first", trace.SpanKindInternal, attribute.String("first", "value"))
+	defer tracer.EndOK(first)
+	_, second := tracer.New(ctx, "second", trace.SpanKindInternal)
+	defer tracer.EndOK(second)
+
+	assert.ElementsMatch(t,
+		[]attribute.KeyValue{attribute.String("default", "value"), attribute.String("first", "value")},
+		first.(sdktrace.ReadOnlySpan).Attributes())
+	assert.ElementsMatch(t,
+		[]attribute.KeyValue{attribute.String("default", "value")},
+		second.(sdktrace.ReadOnlySpan).Attributes())
+	assert.Equal(t, []attribute.KeyValue{attribute.String("default", "value")}, tracer.DefaultAttributes())
+}
+
+// TestNew_Concurrent is intended to be run with the race detector: go test -race ./tracer/...
+func TestNew_Concurrent(t *testing.T) {
+	ctx := context.Background()
+	conn, err := testTools.DialContext(ctx)
+	assert.NoError(t, err)
+
+	shutdown, err := tracer.Initialize(conn)
+	assert.NoError(t, err)
+	defer func() {
+		_ = shutdown(ctx)
+		testTools.Reset(ctx)
+	}()
+	defer tracer.SetDefaultAttributes()
+
+	const goroutines = 50
+	var wg sync.WaitGroup
+	wg.Add(goroutines * 2)
+	for i := 0; i < goroutines; i++ {
+		go func(i int) {
+			defer wg.Done()
+			_, span := tracer.New(ctx, "concurrent", trace.SpanKindInternal, attribute.Int("goroutine", i))
+			defer tracer.EndOK(span)
+
+			n := 0
+			for _, kv := range span.(sdktrace.ReadOnlySpan).Attributes() {
+				if kv.Key == "goroutine" {
+					n++
+					assert.Equal(t, int64(i), kv.Value.AsInt64())
+				}
+			}
+			assert.Equal(t, 1, n)
+		}(i)
+		go func(i int) {
+			defer wg.Done()
+			tracer.AddDefaultAttributes(attribute.Int(fmt.Sprintf("default_%d", i), i))
+			_ = tracer.DefaultAttributes()
+		}(i)
+	}
+	wg.Wait()
+
+	assert.Len(t, tracer.DefaultAttributes(), goroutines)
+}
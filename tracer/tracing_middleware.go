@@ -2,15 +2,22 @@ package tracer
 
 import (
 	"github.com/gin-gonic/gin"
-	"github.com/sirupsen/logrus"
+	"github.com/twistingmercury/observability/logger"
+	"github.com/twistingmercury/observability/observeCfg"
 	"go.opentelemetry.io/otel/trace"
 )
 
+// TracingMiddleware starts a span for each inbound request, except the requests of the observeCfg.HttpExcludedRoutes.
+// If the tracer has not been initialized, the spans are no-ops and the request is served as usual.
 func TracingMiddleware() gin.HandlerFunc {
 	if !IsInitialized() {
-		logrus.Fatal("tracer.Initialize() must be invoked before using the tracing middleware")
+		logger.Warn("tracer.Initialize() has not been invoked; requests will not be traced")
 	}
 	return func(ctx *gin.Context) {
+		if observeCfg.HttpRouteExcluded(route(ctx)) {
+			ctx.Next()
+			return
+		}
 		rCtx, span := New(ctx.Request.Context(), "inbound-request", trace.SpanKindServer)
 
 		ctx.Request = ctx.Request.Clone(rCtx)
@@ -24,3 +31,11 @@ func TracingMiddleware() gin.HandlerFunc {
 		}
 	}
 }
+
+// route returns the route template matched by the request, or its path if it matches no route.
+func route(ctx *gin.Context) string {
+	if r := ctx.FullPath(); len(r) > 0 {
+		return r
+	}
+	return ctx.Request.URL.Path
+}
@@ -5,8 +5,11 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/twistingmercury/observability/observeCfg"
 	"github.com/twistingmercury/observability/testTools"
 	"github.com/twistingmercury/observability/tracer"
+	"go.opentelemetry.io/otel/trace"
 	"net/http"
 	"net/http/httptest"
 	"testing"
@@ -18,8 +21,7 @@ func TestTracingMiddleware_Initialize(t *testing.T) {
 	orgExitFunc := logrus.StandardLogger().ExitFunc
 	logrus.StandardLogger().ExitFunc = func(int) { fatal = true }
 	_ = tracer.TracingMiddleware()
-	assert.True(t, fatal)
-	fatal = false
+	assert.False(t, fatal, "an uninitialized tracer must not stop the service")
 	defer func() {
 		logrus.StandardLogger().ExitFunc = orgExitFunc
 	}()
@@ -45,3 +47,36 @@ func TestTracingMiddleware_Initialize(t *testing.T) {
 
 	assert.Equal(t, http.StatusNotFound, w.Code)
 }
+
+func TestTracingMiddleware_ExcludedRoutes(t *testing.T) {
+	t.Setenv(observeCfg.LogLevelEnvVar, "debug")
+	t.Setenv(observeCfg.TraceEndpointEnvVar, "localhost:4317")
+	t.Setenv(observeCfg.MetricsEndpointEnvVar, "localhost:4317")
+	t.Setenv(observeCfg.EnvironEnvVar, "localhost")
+	t.Setenv(observeCfg.HttpExcludedRoutesEnvVar, "/health,/missing")
+	require.NoError(t, observeCfg.Initialize("unit-tests", "2023-01-01T00:00:00.000", "0.0.0", "abcd0123"))
+
+	conn, err := testTools.DialContext(context.Background())
+	require.NoError(t, err)
+	_, err = tracer.Initialize(conn)
+	require.NoError(t, err)
+	defer tracer.Reset()
+
+	traced := map[string]bool{}
+	_, e := gin.CreateTestContext(httptest.NewRecorder())
+	e.Use(tracer.TracingMiddleware())
+	for _, path := range []string{"/health", "/orders/:id"} {
+		e.GET(path, func(c *gin.Context) {
+			traced[c.FullPath()] = trace.SpanFromContext(c.Request.Context()).SpanContext().IsValid()
+		})
+	}
+	e.NoRoute(func(c *gin.Context) {
+		traced[c.Request.URL.Path] = trace.SpanFromContext(c.Request.Context()).SpanContext().IsValid()
+	})
+	for _, path := range []string{"/health", "/orders/1", "/missing"} {
+		e.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	assert.Equal(t, map[string]bool{"/health": false, "/orders/:id": true, "/missing": false}, traced,
+		"the excluded routes, or paths of unmatched requests, are not traced")
+}